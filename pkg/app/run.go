@@ -127,11 +127,18 @@ func Run(args []string, env config.Environment, file config.File, configFilePath
 	serviceVersionLock := serviceversion.NewLockCommand(serviceVersionRoot.CmdClause, &globals)
 
 	computeRoot := compute.NewRootCommand(app, &globals)
-	computeInit := compute.NewInitCommand(computeRoot.CmdClause, &globals)
+	computeInit := compute.NewInitCommand(computeRoot.CmdClause, httpClient, &globals)
 	computeBuild := compute.NewBuildCommand(computeRoot.CmdClause, httpClient, &globals)
+	computePack := compute.NewPackCommand(computeRoot.CmdClause, &globals)
 	computeDeploy := compute.NewDeployCommand(computeRoot.CmdClause, httpClient, &globals)
 	computeUpdate := compute.NewUpdateCommand(computeRoot.CmdClause, httpClient, &globals)
 	computeValidate := compute.NewValidateCommand(computeRoot.CmdClause, &globals)
+	computeInspect := compute.NewInspectCommand(computeRoot.CmdClause, &globals)
+	computeSymbolicate := compute.NewSymbolicateCommand(computeRoot.CmdClause, &globals)
+	computeServe := compute.NewServeCommand(computeRoot.CmdClause, &globals, computeBuild)
+	computeTest := compute.NewTestCommand(computeRoot.CmdClause, &globals, computeBuild)
+	computeEnvRoot := compute.NewEnvRootCommand(computeRoot.CmdClause, &globals)
+	computeEnvList := compute.NewEnvListCommand(computeEnvRoot.CmdClause, &globals)
 
 	domainRoot := domain.NewRootCommand(app, &globals)
 	domainCreate := domain.NewCreateCommand(domainRoot.CmdClause, &globals)
@@ -346,9 +353,16 @@ func Run(args []string, env config.Environment, file config.File, configFilePath
 		computeRoot,
 		computeInit,
 		computeBuild,
+		computePack,
 		computeDeploy,
 		computeUpdate,
 		computeValidate,
+		computeInspect,
+		computeSymbolicate,
+		computeServe,
+		computeTest,
+		computeEnvRoot,
+		computeEnvList,
 
 		domainRoot,
 		domainCreate,
@@ -143,11 +143,13 @@ SUBCOMMANDS
     Show detailed information about a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
 
   service update [<flags>]
     Update a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
     -n, --name=NAME              Service name
         --comment=COMMENT        Human-readable comment
 
@@ -155,6 +157,7 @@ SUBCOMMANDS
     Delete a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
 
 `) + "\n\n"
 
@@ -186,9 +189,11 @@ COMMANDS
     Display version information for the Fastly CLI
 
 
-  update
+  update [<flags>]
     Update the CLI to the latest version
 
+    --json-events  Write progress as newline-delimited JSON events, for CI
+                   systems, instead of text
 
   service create --name=NAME [<flags>]
     Create a Fastly service
@@ -206,11 +211,13 @@ COMMANDS
     Show detailed information about a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
 
   service update [<flags>]
     Update a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
     -n, --name=NAME              Service name
         --comment=COMMENT        Human-readable comment
 
@@ -218,22 +225,26 @@ COMMANDS
     Delete a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
 
   service-version clone --version=VERSION [<flags>]
     Clone a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of version you wish to clone
 
   service-version list [<flags>]
     List Fastly service versions
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
 
   service-version update --version=VERSION --comment=COMMENT [<flags>]
     Update a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of version you wish to update
         --comment=COMMENT        Human-readable comment
 
@@ -241,63 +252,193 @@ COMMANDS
     Activate a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of version you wish to activate
 
   service-version deactivate --version=VERSION [<flags>]
     Deactivate a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of version you wish to deactivate
 
   service-version lock --version=VERSION [<flags>]
     Lock a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of version you wish to lock
 
   compute init [<flags>]
     Initialize a new Compute@Edge package locally
 
-    -s, --service-id=SERVICE-ID    Existing service ID to use. By default, this
-                                   command creates a new service
+    -s, --service-id=SERVICE-ID    Existing service ID to use. By default,
+                                   this command creates a new service
     -n, --name=NAME                Name of package, defaulting to directory name
                                    of the --path destination
     -d, --description=DESCRIPTION  Description of the package
     -a, --author=AUTHOR            Author of the package
-    -f, --from=FROM                Git repository containing package template
+    -l, --language=LANGUAGE        Language of the package
+    -f, --from=FROM                Package template: a Git repository URL,
+                                   local directory, .tar.gz archive,
+                                   or org/repo[/subdir]@tag on GitHub
     -p, --path=PATH                Destination to write the new package,
                                    defaulting to the current directory
         --domain=DOMAIN            The name of the domain associated to the
                                    package
         --backend=BACKEND          A hostname, IPv4, or IPv6 address for the
                                    package backend
+        --non-interactive          Don't prompt for input, using defaults for
+                                   values which aren't provided, except the
+                                   language or template
+        --accept-defaults          Don't prompt for input, using defaults for
+                                   every value which isn't provided
+        --local-only               Only create the package locally, deferring
+                                   creating the service until the package is
+                                   first deployed
+        --json-events              Write progress as newline-delimited JSON
+                                   events, for CI systems, instead of text,
+                                   without prompting for input
 
   compute build [<flags>]
     Build a Compute@Edge package locally
 
     --name=NAME          Package name
     --language=LANGUAGE  Language type
+    --bin=BIN            Name of the binary to build, for Rust packages with
+                         more than one
+    --package=PACKAGE    Name of the Cargo package to build, for Rust workspaces
+                         with more than one
     --include-source     Include source code in built package
     --force              Skip verification steps and force build
+    --offline            Skip checking remote sources for the latest versions of
+                         dependencies, using cached or locked versions instead
+    --watch              Rebuild the package whenever its source files change
+    --strip              Remove debug information and other custom sections from
+                         the Wasm binary before packaging it
+    --keep-debug         When stripping, keep the unstripped Wasm binary beside
+                         the package as pkg/<name>.debug.wasm
+    --[no-]cache         Skip building the package if the files and settings it
+                         is built from haven't changed since it was last built
+                         (use --no-cache to always build)
+    --json-events        Write progress as newline-delimited JSON events,
+                         for CI systems, instead of text
+    --list-files         Print the files which would be included in the package
+                         archive, without building it
+
+  compute pack --wasm-binary=WASM-BINARY [<flags>]
+    Package a prebuilt Wasm binary as a Compute@Edge package
+
+    --wasm-binary=WASM-BINARY  Path to the Wasm binary
+    --manifest="fastly.toml"   Path to the package manifest, which is generated
+                               from the package name if it doesn't exist
+    --name=NAME                Package name, which must match the name in the
+                               package manifest if it sets one
 
   compute deploy [<flags>]
     Deploy a package to a Fastly Compute@Edge service
 
-    -s, --service-id=SERVICE-ID  Service ID
-        --version=VERSION        Number of version to activate
-    -p, --path=PATH              Path to package
-
-  compute update --service-id=SERVICE-ID --version=VERSION --path=PATH
+    -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
+        --version=VERSION          Number of version to activate
+    -p, --path=PATH                Path to package
+        --force                    Upload and activate the package even if it is
+                                   unchanged, or if another deploy changed the
+                                   service at the same time
+        --max-package-size=52428800
+                                   Maximum size of the package archive in bytes
+        --upload-retries=3         Number of times to retry uploading the
+                                   package after a transient failure
+        --domain=DOMAIN            The domain of a service created by the
+                                   deploy, used when the package has no service
+                                   ID
+        --backend=BACKEND          A hostname, IPv4, or IPv6 address for the
+                                   backend of a service created by the deploy
+        --comment=COMMENT          Comment to set on the deployed version,
+                                   which must be neither locked nor active,
+                                   instead of one describing the package and its
+                                   git commit
+        --version-comment-template=VERSION-COMMENT-TEMPLATE
+                                   Go template of the comment set on the
+                                   deployed version, with the fields .Commit,
+                                   .Dirty, .Branch, .PackageHash, .CLIVersion
+                                   and .Timestamp
+        --json-events              Write progress as newline-delimited JSON
+                                   events, for CI systems, instead of text
+        --verify-url=VERIFY-URL    URL to request after activating the version,
+                                   rolling back to the previously active version
+                                   if verification fails
+        --verify-status=200        Expected HTTP status of responses from the
+                                   verification URL
+        --verify-body=VERIFY-BODY  Text expected in the body of responses from
+                                   the verification URL
+        --verify-duration=1m       How long to verify the deployment for
+        --verify-delay=5s          How long to wait after activating the version
+                                   before verifying the deployment
+        --verify-error-rate=0.05   Maximum ratio of 5xx responses reported by
+                                   realtime stats during verification
+
+  compute update --version=VERSION --path=PATH [<flags>]
     Update a package on a Fastly Compute@Edge service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -p, --path=PATH              Path to package
+        --max-package-size=52428800
+                                 Maximum size of the package archive in bytes
+        --upload-retries=3       Number of times to retry uploading the package
+                                 after a transient failure
 
-  compute validate --path=PATH
+  compute validate --path=PATH [<flags>]
     Validate a Compute@Edge package
 
-    -p, --path=PATH  Path to package
+    -p, --path=PATH                Path to package
+        --max-package-size=52428800
+                                   Maximum size of the package archive in bytes
+        --max-wasm-size=104857600  Maximum size of the Wasm binary in bytes
+        --format=FORMAT            Output format (json)
+        --strict                   Treat warnings, such as unknown manifest
+                                   keys, as problems which make the package
+                                   invalid
+
+  compute inspect [<flags>]
+    Report the size and host calls of a Compute@Edge package
+
+    -p, --path=PATH      Path to package
+        --top=10         Number of the largest functions to report
+        --format=FORMAT  Output format (json)
+
+  compute symbolicate [<flags>]
+    Map the frames of a Wasm backtrace to functions, source files and lines
+
+    --wasm-binary="bin/main.wasm"  Path to the Wasm binary the backtrace is from
+    --package=PACKAGE              Path to the package the backtrace is from,
+                                   instead of a Wasm binary
+    --file=FILE                    Path to the backtrace, which is otherwise
+                                   read from stdin
+
+  compute serve [<flags>]
+    Build and run a Compute@Edge package locally
+
+    --addr="127.0.0.1:7676"  The address to serve the package on
+    --file="bin/main.wasm"   The Wasm file to run
+    --skip-build             Skip building the package before serving it
+    --force                  Skip verification steps and force build
+
+  compute test [<flags>]
+    Build the tests of a Compute@Edge package and run them locally
+
+    --filter=FILTER    Only run tests whose name contains the filter
+    --format=text      The format of the test report
+    --output=OUTPUT    Path to write the test report to, instead of stdout
+    --package=PACKAGE  Name of the package in the cargo workspace to test
+    --force            Skip verification steps and force build
+    --timeout=1m       Fail each test which runs for longer than the timeout
+
+  compute env list
+    List the environments configured in the package manifest
+
 
   domain create --name=NAME --version=VERSION [<flags>]
     Create a domain on a Fastly service version
@@ -305,18 +446,21 @@ COMMANDS
     -n, --name=NAME              Domain name
         --comment=COMMENT        A descriptive note
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   domain list --version=VERSION [<flags>]
     List domains on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   domain describe --version=VERSION --name=NAME [<flags>]
     Show detailed information about a domain on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Name of domain
 
@@ -324,6 +468,7 @@ COMMANDS
     Update a domain on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Domain name
         --new-name=NEW-NAME      New domain name
@@ -334,6 +479,7 @@ COMMANDS
 
     -n, --name=NAME              Domain name
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   backend create --service-id=SERVICE-ID --version=VERSION --name=NAME --address=ADDRESS [<flags>]
@@ -481,6 +627,7 @@ COMMANDS
     Create a healthcheck on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Healthcheck name
         --comment=COMMENT        A descriptive note
@@ -506,12 +653,14 @@ COMMANDS
     List healthchecks on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   healthcheck describe --version=VERSION --name=NAME [<flags>]
     Show detailed information about a healthcheck on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Name of healthcheck
 
@@ -519,6 +668,7 @@ COMMANDS
     Update a healthcheck on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Healthcheck name
         --new-name=NEW-NAME      Healthcheck name
@@ -545,6 +695,7 @@ COMMANDS
     Delete a healthcheck on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              Healthcheck name
 
@@ -554,6 +705,7 @@ COMMANDS
     -n, --name=NAME              The name of the BigQuery logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --project-id=PROJECT-ID  Your Google Cloud Platform project ID
         --dataset=DATASET        Your BigQuery dataset
@@ -589,6 +741,7 @@ COMMANDS
     List BigQuery endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging bigquery describe --version=VERSION --name=NAME [<flags>]
@@ -596,6 +749,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the BigQuery logging object
 
@@ -603,6 +757,7 @@ COMMANDS
     Update a BigQuery logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the BigQuery logging object
         --new-name=NEW-NAME      New name of the BigQuery logging object
@@ -640,6 +795,7 @@ COMMANDS
     Delete a BigQuery logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the BigQuery logging object
 
@@ -649,6 +805,7 @@ COMMANDS
     -n, --name=NAME              The name of the S3 logging object. Used as a
                                  primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --bucket=BUCKET          Your S3 bucket name
         --access-key=ACCESS-KEY  Your S3 account access key
@@ -694,6 +851,7 @@ COMMANDS
     List S3 endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging s3 describe --version=VERSION --name=NAME [<flags>]
@@ -701,6 +859,7 @@ COMMANDS
     version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the S3 logging object
 
@@ -708,6 +867,7 @@ COMMANDS
     Update a S3 logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the S3 logging object
         --new-name=NEW-NAME      New name of the S3 logging object
@@ -755,6 +915,7 @@ COMMANDS
     Delete a S3 logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the S3 logging object
 
@@ -764,6 +925,7 @@ COMMANDS
     -n, --name=NAME                The name of the Syslog logging object. Used
                                    as a primary key for API access
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
         --address=ADDRESS          A hostname or IPv4 address
         --port=PORT                The port number
@@ -803,6 +965,7 @@ COMMANDS
     List Syslog endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging syslog describe --version=VERSION --name=NAME [<flags>]
@@ -810,6 +973,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Syslog logging object
 
@@ -817,6 +981,7 @@ COMMANDS
     Update a Syslog logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
     -n, --name=NAME                The name of the Syslog logging object
         --new-name=NEW-NAME        New name of the Syslog logging object
@@ -858,6 +1023,7 @@ COMMANDS
     Delete a Syslog logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Syslog logging object
 
@@ -867,6 +1033,7 @@ COMMANDS
     -n, --name=NAME              The name of the Logentries logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --port=PORT              The port number
         --use-tls                Whether to use TLS for secure logging. Can be
@@ -895,6 +1062,7 @@ COMMANDS
     List Logentries endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging logentries describe --version=VERSION --name=NAME [<flags>]
@@ -902,6 +1070,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logentries logging object
 
@@ -909,6 +1078,7 @@ COMMANDS
     Update a Logentries logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logentries logging object
         --new-name=NEW-NAME      New name of the Logentries logging object
@@ -939,6 +1109,7 @@ COMMANDS
     Delete a Logentries logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logentries logging object
 
@@ -948,6 +1119,7 @@ COMMANDS
     -n, --name=NAME              The name of the Papertrail logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --address=ADDRESS        A hostname or IPv4 address
         --port=PORT              The port number
@@ -973,6 +1145,7 @@ COMMANDS
     List Papertrail endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging papertrail describe --version=VERSION --name=NAME [<flags>]
@@ -980,6 +1153,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Papertrail logging object
 
@@ -987,6 +1161,7 @@ COMMANDS
     Update a Papertrail logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Papertrail logging object
         --new-name=NEW-NAME      New name of the Papertrail logging object
@@ -1014,6 +1189,7 @@ COMMANDS
     Delete a Papertrail logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Papertrail logging object
 
@@ -1023,6 +1199,7 @@ COMMANDS
     -n, --name=NAME              The name of the Sumologic logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --url=URL                The URL to POST to
         --format=FORMAT          Apache style log formatting
@@ -1050,6 +1227,7 @@ COMMANDS
     List Sumologic endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging sumologic describe --version=VERSION --name=NAME [<flags>]
@@ -1057,6 +1235,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Sumologic logging object
 
@@ -1064,6 +1243,7 @@ COMMANDS
     Update a Sumologic logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Sumologic logging object
         --new-name=NEW-NAME      New name of the Sumologic logging object
@@ -1093,6 +1273,7 @@ COMMANDS
     Delete a Sumologic logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Sumologic logging object
 
@@ -1102,6 +1283,7 @@ COMMANDS
     -n, --name=NAME              The name of the GCS logging object. Used as a
                                  primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --user=USER              Your GCS service account email address. The
                                  client_email field in your service account
@@ -1143,6 +1325,7 @@ COMMANDS
     List GCS endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging gcs describe --version=VERSION --name=NAME [<flags>]
@@ -1150,6 +1333,7 @@ COMMANDS
     version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the GCS logging object
 
@@ -1157,6 +1341,7 @@ COMMANDS
     Update a GCS logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the GCS logging object
         --new-name=NEW-NAME      New name of the GCS logging object
@@ -1200,6 +1385,7 @@ COMMANDS
     Delete a GCS logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the GCS logging object
 
@@ -1209,6 +1395,7 @@ COMMANDS
     -n, --name=NAME              The name of the FTP logging object. Used as a
                                  primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --address=ADDRESS        An hostname or IPv4 address
         --user=USER              The username for the server (can be anonymous)
@@ -1242,6 +1429,7 @@ COMMANDS
     List FTP endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging ftp describe --version=VERSION --name=NAME [<flags>]
@@ -1249,6 +1437,7 @@ COMMANDS
     version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the FTP logging object
 
@@ -1256,6 +1445,7 @@ COMMANDS
     Update an FTP logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the FTP logging object
         --new-name=NEW-NAME      New name of the FTP logging object
@@ -1298,6 +1488,7 @@ COMMANDS
     Delete an FTP logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the FTP logging object
 
@@ -1307,6 +1498,7 @@ COMMANDS
     -n, --name=NAME                The name of the Splunk logging object. Used
                                    as a primary key for API access
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
         --url=URL                  The URL to POST to
         --tls-ca-cert=TLS-CA-CERT  A secure certificate to authenticate the
@@ -1335,6 +1527,7 @@ COMMANDS
     List Splunk endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging splunk describe --version=VERSION --name=NAME [<flags>]
@@ -1342,6 +1535,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Splunk logging object
 
@@ -1349,6 +1543,7 @@ COMMANDS
     Update a Splunk logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
     -n, --name=NAME                The name of the Splunk logging object
         --new-name=NEW-NAME        New name of the Splunk logging object
@@ -1379,6 +1574,7 @@ COMMANDS
     Delete a Splunk logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Splunk logging object
 
@@ -1388,6 +1584,7 @@ COMMANDS
     -n, --name=NAME              The name of the Scalyr logging object. Used as
                                  a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --auth-token=AUTH-TOKEN  The token to use for authentication
                                  (https://www.scalyr.com/keys)
@@ -1410,6 +1607,7 @@ COMMANDS
     List Scalyr endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging scalyr describe --version=VERSION --name=NAME [<flags>]
@@ -1417,6 +1615,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Scalyr logging object
 
@@ -1424,6 +1623,7 @@ COMMANDS
     Update a Scalyr logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Scalyr logging object
         --new-name=NEW-NAME      New name of the Scalyr logging object
@@ -1448,6 +1648,7 @@ COMMANDS
     Delete a Scalyr logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Scalyr logging object
 
@@ -1457,6 +1658,7 @@ COMMANDS
     -n, --name=NAME              The name of the Loggly logging object. Used as
                                  a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --auth-token=AUTH-TOKEN  The token to use for authentication
                                  (https://www.loggly.com/docs/customer-token-authentication-token/)
@@ -1477,6 +1679,7 @@ COMMANDS
     List Loggly endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging loggly describe --version=VERSION --name=NAME [<flags>]
@@ -1484,6 +1687,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Loggly logging object
 
@@ -1491,6 +1695,7 @@ COMMANDS
     Update a Loggly logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Loggly logging object
         --new-name=NEW-NAME      New name of the Loggly logging object
@@ -1513,6 +1718,7 @@ COMMANDS
     Delete a Loggly logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Loggly logging object
 
@@ -1522,6 +1728,7 @@ COMMANDS
     -n, --name=NAME              The name of the Honeycomb logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --dataset=DATASET        The Honeycomb Dataset you want to log to
         --auth-token=AUTH-TOKEN  The Write Key from the Account page of your
@@ -1544,6 +1751,7 @@ COMMANDS
     List Honeycomb endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging honeycomb describe --version=VERSION --name=NAME [<flags>]
@@ -1551,6 +1759,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Honeycomb logging object
 
@@ -1558,6 +1767,7 @@ COMMANDS
     Update a Honeycomb logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Honeycomb logging object
         --new-name=NEW-NAME      New name of the Honeycomb logging object
@@ -1582,6 +1792,7 @@ COMMANDS
     Delete a Honeycomb logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Honeycomb logging object
 
@@ -1591,6 +1802,7 @@ COMMANDS
     -n, --name=NAME              The name of the Heroku logging object. Used as
                                  a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --url=URL                The url to stream logs to
         --auth-token=AUTH-TOKEN  The token to use for authentication
@@ -1612,6 +1824,7 @@ COMMANDS
     List Heroku endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging heroku describe --version=VERSION --name=NAME [<flags>]
@@ -1619,6 +1832,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Heroku logging object
 
@@ -1626,6 +1840,7 @@ COMMANDS
     Update a Heroku logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Heroku logging object
         --new-name=NEW-NAME      New name of the Heroku logging object
@@ -1649,6 +1864,7 @@ COMMANDS
     Delete a Heroku logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Heroku logging object
 
@@ -1658,6 +1874,7 @@ COMMANDS
     -n, --name=NAME              The name of the SFTP logging object. Used as a
                                  primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --address=ADDRESS        The hostname or IPv4 addres
         --user=USER              The username for the server
@@ -1705,6 +1922,7 @@ COMMANDS
     List SFTP endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging sftp describe --version=VERSION --name=NAME [<flags>]
@@ -1712,6 +1930,7 @@ COMMANDS
     version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the SFTP logging object
 
@@ -1719,6 +1938,7 @@ COMMANDS
     Update an SFTP logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the SFTP logging object
         --new-name=NEW-NAME      New name of the SFTP logging object
@@ -1768,6 +1988,7 @@ COMMANDS
     Delete an SFTP logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the SFTP logging object
 
@@ -1777,6 +1998,7 @@ COMMANDS
     -n, --name=NAME              The name of the Logshuttle logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --url=URL                Your Log Shuttle endpoint url
         --auth-token=AUTH-TOKEN  The data authentication token associated with
@@ -1798,6 +2020,7 @@ COMMANDS
     List Logshuttle endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging logshuttle describe --version=VERSION --name=NAME [<flags>]
@@ -1805,6 +2028,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logshuttle logging object
 
@@ -1812,6 +2036,7 @@ COMMANDS
     Update a Logshuttle logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logshuttle logging object
         --new-name=NEW-NAME      New name of the Logshuttle logging object
@@ -1835,6 +2060,7 @@ COMMANDS
     Delete a Logshuttle logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Logshuttle logging object
 
@@ -1844,6 +2070,7 @@ COMMANDS
     -n, --name=NAME              The name of the Cloudfiles logging object. Used
                                  as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --user=USER              The username for your Cloudfile account
         --access-key=ACCESS-KEY  Your Cloudfile account access key
@@ -1883,6 +2110,7 @@ COMMANDS
     List Cloudfiles endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging cloudfiles describe --version=VERSION --name=NAME [<flags>]
@@ -1890,6 +2118,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Cloudfiles logging object
 
@@ -1897,6 +2126,7 @@ COMMANDS
     Update a Cloudfiles logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Cloudfiles logging object
         --new-name=NEW-NAME      New name of the Cloudfiles logging object
@@ -1938,6 +2168,7 @@ COMMANDS
     Delete a Cloudfiles logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Cloudfiles logging object
 
@@ -1947,6 +2178,7 @@ COMMANDS
     -n, --name=NAME              The name of the DigitalOcean Spaces logging
                                  object. Used as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --bucket=BUCKET          The name of the DigitalOcean Space
         --access-key=ACCESS-KEY  Your DigitalOcean Spaces account access key
@@ -1985,6 +2217,7 @@ COMMANDS
     List DigitalOcean Spaces logging endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging digitalocean describe --version=VERSION --name=NAME [<flags>]
@@ -1992,6 +2225,7 @@ COMMANDS
     Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the DigitalOcean Spaces logging
                                  object
@@ -2000,6 +2234,7 @@ COMMANDS
     Update a DigitalOcean Spaces logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the DigitalOcean Spaces logging
                                  object
@@ -2042,6 +2277,7 @@ COMMANDS
     Delete a DigitalOcean Spaces logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the DigitalOcean Spaces logging
                                  object
@@ -2052,6 +2288,7 @@ COMMANDS
     -n, --name=NAME                The name of the Elasticsearch logging object.
                                    Used as a primary key for API access
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
         --index=INDEX              The name of the Elasticsearch index to send
                                    documents (logs) to. The index must follow
@@ -2107,6 +2344,7 @@ COMMANDS
     List Elasticsearch endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging elasticsearch describe --version=VERSION --name=NAME [<flags>]
@@ -2114,6 +2352,7 @@ COMMANDS
     Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Elasticsearch logging object
 
@@ -2121,6 +2360,7 @@ COMMANDS
     Update an Elasticsearch logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
     -n, --name=NAME                The name of the Elasticsearch logging object
         --new-name=NEW-NAME        New name of the Elasticsearch logging object
@@ -2178,6 +2418,7 @@ COMMANDS
     Delete an Elasticsearch logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Elasticsearch logging object
 
@@ -2187,6 +2428,7 @@ COMMANDS
     -n, --name=NAME              The name of the Azure Blob Storage logging
                                  object. Used as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --container=CONTAINER    The name of the Azure Blob Storage container in
                                  which to store logs
@@ -2229,6 +2471,7 @@ COMMANDS
     List Azure Blob Storage logging endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging azureblob describe --version=VERSION --name=NAME [<flags>]
@@ -2236,6 +2479,7 @@ COMMANDS
     Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Azure Blob Storage logging
                                  object
@@ -2244,6 +2488,7 @@ COMMANDS
     Update an Azure Blob Storage logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Azure Blob Storage logging
                                  object
@@ -2290,6 +2535,7 @@ COMMANDS
     Delete an Azure Blob Storage logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Azure Blob Storage logging
                                  object
@@ -2300,6 +2546,7 @@ COMMANDS
     -n, --name=NAME              The name of the Datadog logging object. Used as
                                  a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --auth-token=AUTH-TOKEN  The API key from your Datadog account
         --region=REGION          The region that log data will be sent to. One
@@ -2323,6 +2570,7 @@ COMMANDS
     List Datadog endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging datadog describe --version=VERSION --name=NAME [<flags>]
@@ -2330,6 +2578,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Datadog logging object
 
@@ -2337,6 +2586,7 @@ COMMANDS
     Update a Datadog logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Datadog logging object
         --new-name=NEW-NAME      New name of the Datadog logging object
@@ -2362,6 +2612,7 @@ COMMANDS
     Delete a Datadog logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Datadog logging object
 
@@ -2371,6 +2622,7 @@ COMMANDS
     -n, --name=NAME                The name of the HTTPS logging object. Used as
                                    a primary key for API access
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
         --url=URL                  URL that log data will be sent to. Must use
                                    the https protocol
@@ -2429,6 +2681,7 @@ COMMANDS
     List HTTPS endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging https describe --version=VERSION --name=NAME [<flags>]
@@ -2436,6 +2689,7 @@ COMMANDS
     service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the HTTPS logging object
 
@@ -2443,6 +2697,7 @@ COMMANDS
     Update an HTTPS logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
     -n, --name=NAME                The name of the HTTPS logging object
         --new-name=NEW-NAME        New name of the HTTPS logging object
@@ -2503,6 +2758,7 @@ COMMANDS
     Delete an HTTPS logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the HTTPS logging object
 
@@ -2512,6 +2768,7 @@ COMMANDS
     -n, --name=NAME                The name of the Kafka logging object. Used as
                                    a primary key for API access
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
         --topic=TOPIC              The Kafka topic to send logs to
         --brokers=BROKERS          A comma-separated list of IP addresses or
@@ -2559,6 +2816,7 @@ COMMANDS
     List Kafka endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging kafka describe --version=VERSION --name=NAME [<flags>]
@@ -2566,6 +2824,7 @@ COMMANDS
     version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Kafka logging object
 
@@ -2573,6 +2832,7 @@ COMMANDS
     Update a Kafka logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID    Service ID
+        --env=ENV                  Package manifest environment to use
         --version=VERSION          Number of service version
     -n, --name=NAME                The name of the Kafka logging object
         --new-name=NEW-NAME        New name of the Kafka logging object
@@ -2622,6 +2882,7 @@ COMMANDS
     Delete a Kafka logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Kafka logging object
 
@@ -2631,6 +2892,7 @@ COMMANDS
     -n, --name=NAME              The name of the Google Cloud Pub/Sub logging
                                  object. Used as a primary key for API access
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
         --user=USER              Your Google Cloud Platform service account
                                  email address. The client_email field in your
@@ -2659,6 +2921,7 @@ COMMANDS
     List Google Cloud Pub/Sub endpoints on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
 
   logging googlepubsub describe --version=VERSION --name=NAME [<flags>]
@@ -2666,6 +2929,7 @@ COMMANDS
     Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Google Cloud Pub/Sub logging
                                  object
@@ -2674,6 +2938,7 @@ COMMANDS
     Update a Google Cloud Pub/Sub logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Google Cloud Pub/Sub logging
                                  object
@@ -2706,6 +2971,7 @@ COMMANDS
     Delete a Google Cloud Pub/Sub logging endpoint on a Fastly service version
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --version=VERSION        Number of service version
     -n, --name=NAME              The name of the Google Cloud Pub/Sub logging
                                  object
@@ -2714,10 +2980,11 @@ COMMANDS
     List stats regions
 
 
-  stats historical --service-id=SERVICE-ID [<flags>]
+  stats historical [<flags>]
     View historical stats for a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --from=FROM              From time, accepted formats at
                                  https://docs.fastly.com/api/stats#Range
         --to=TO                  To time
@@ -2725,10 +2992,11 @@ COMMANDS
         --region=REGION          Filter by region ('stats regions' to list)
         --format=FORMAT          Output format (json)
 
-  stats realtime --service-id=SERVICE-ID [<flags>]
+  stats realtime [<flags>]
     View realtime stats for a Fastly service
 
     -s, --service-id=SERVICE-ID  Service ID
+        --env=ENV                Package manifest environment to use
         --format=FORMAT          Output format (json)
 
 For help on a specific command, try e.g.
@@ -0,0 +1,250 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+const (
+	// NodeVersionConstraint is the semver constraint for the versions of
+	// Node.js that we support.
+	NodeVersionConstraint = ">= 12.0.0"
+	// AssemblyScriptVersionConstraint is the semver constraint for the versions
+	// of the AssemblyScript compiler that we support.
+	AssemblyScriptVersionConstraint = "~0.14.0"
+	// AssemblyScriptEntrypoint is the source file the AssemblyScript compiler
+	// builds the package from.
+	AssemblyScriptEntrypoint = "assembly/index.ts"
+)
+
+// NPMPackage models the properties of an installed npm package which we are
+// interested in and are read from the `npm list --json` command output.
+type NPMPackage struct {
+	Version      string                `json:"version"`
+	Dependencies map[string]NPMPackage `json:"dependencies"`
+}
+
+// AssemblyScript implements Toolchain for the AssemblyScript language.
+type AssemblyScript struct{}
+
+// Verify implements the Toolchain interface and verifies whether the
+// AssemblyScript language toolchain is correctly configured on the host.
+func (a AssemblyScript) Verify(out io.Writer) error {
+	// 1) Check Node.js, npm and package.json
+	//
+	// The AssemblyScript compiler is itself a Node.js program, which is
+	// installed locally to the package by npm.
+
+	if err := verifyNPM(out); err != nil {
+		return err
+	}
+
+	// 2) Verify the `assemblyscript` compiler version
+	//
+	// We use npm to list the packages installed locally and assert that a
+	// version of the compiler matching our constraint is present.
+
+	fmt.Fprintf(out, "Checking if AssemblyScript is installed...\n")
+
+	return verifyNPMPackage(out, "assemblyscript", AssemblyScriptVersionConstraint)
+}
+
+// Build implements the Toolchain interface and attempts to compile the package
+// AssemblyScript source to a Wasm binary.
+func (a AssemblyScript) Build(out io.Writer, verbose bool) error {
+	// Get working directory.
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	binDir := filepath.Join(dir, "bin")
+	if err := createBinDirectory(binDir); err != nil {
+		return err
+	}
+
+	args := []string{
+		AssemblyScriptEntrypoint,
+		"--binaryFile",
+		filepath.Join(binDir, "main.wasm"),
+		"--optimize",
+		"--noAssert",
+	}
+	if verbose {
+		args = append(args, "--verbose")
+	}
+
+	// Call the locally installed asc compiler.
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the variables come from trusted sources.
+	/* #nosec */
+	cmd := exec.Command(filepath.Join(dir, "node_modules", ".bin", "asc"), args...)
+
+	return streamCommand(cmd, out, verbose)
+}
+
+// BuildTests implements the Toolchain interface. AssemblyScript has no test
+// harness which can be compiled to a WASI binary, so it isn't supported.
+func (a AssemblyScript) BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error) {
+	return nil, errTestsUnsupported("assemblyscript")
+}
+
+// Version implements the Toolchain interface and returns the version of the
+// AssemblyScript compiler installed locally to the package.
+func (a AssemblyScript) Version() (string, error) {
+	stdout, err := exec.Command("npm", "list", "--json", "--depth", "0", "assemblyscript").Output()
+	if err != nil {
+		return "", fmt.Errorf("error executing npm list: %w", err)
+	}
+	version, err := getNPMPackageVersion(stdout, "assemblyscript")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("assemblyscript %s", version), nil
+}
+
+// verifyNPM verifies whether a supported version of Node.js and npm are
+// installed, and whether a package.json file exists in the current directory,
+// as needed by toolchains which are installed locally to the package by npm.
+func verifyNPM(out io.Writer) error {
+	// 1) Check `node` is on $PATH and is a supported version
+
+	fmt.Fprintf(out, "Checking if node is installed...\n")
+
+	p, err := exec.LookPath("node")
+	if err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("`node` not found in $PATH"),
+			Remediation: fmt.Sprintf("To fix this error, install Node.js %s by visiting:\n\n\t$ %s", NodeVersionConstraint, text.Bold("https://nodejs.org/")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found node at %s\n", p)
+
+	cmd := exec.Command("node", "--version")
+	stdoutStderr, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error executing node: %w", err)
+	}
+
+	nodeVersion, err := semver.NewVersion(strings.TrimSpace(string(stdoutStderr)))
+	if err != nil {
+		return fmt.Errorf("error parsing node version: %w", err)
+	}
+
+	nodeConstraint, err := semver.NewConstraint(NodeVersionConstraint)
+	if err != nil {
+		return fmt.Errorf("error parsing node version constraint: %w", err)
+	}
+
+	if !nodeConstraint.Check(nodeVersion) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("node version %s not supported", nodeVersion),
+			Remediation: fmt.Sprintf("To fix this error, install Node.js %s by visiting:\n\n\t$ %s", NodeVersionConstraint, text.Bold("https://nodejs.org/")),
+		}
+	}
+
+	// 2) Check `npm` is on $PATH
+	//
+	// npm is Node's package manager, it is needed to install and locate the
+	// compiler. We only check whether the binary exists on the users $PATH and
+	// error with installation help text.
+
+	fmt.Fprintf(out, "Checking if npm is installed...\n")
+
+	p, err = exec.LookPath("npm")
+	if err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("`npm` not found in $PATH"),
+			Remediation: fmt.Sprintf("To fix this error, install Node.js and npm by visiting:\n\n\t$ %s", text.Bold("https://nodejs.org/")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found npm at %s\n", p)
+
+	// 3) Check package.json file exists in $PWD
+	//
+	// A valid package.json file is needed to install the compiler locally to
+	// the package. Therefore, we assert whether one exists in the current $PWD.
+
+	fpath, err := filepath.Abs("package.json")
+	if err != nil {
+		return fmt.Errorf("error getting package.json path: %w", err)
+	}
+
+	if !common.FileExists(fpath) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("%s not found", fpath),
+			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold("npm init")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found package.json at %s\n", fpath)
+
+	return nil
+}
+
+// verifyNPMPackage verifies whether a version of the named npm package which
+// matches the constraint is installed locally to the package.
+func verifyNPMPackage(out io.Writer, name, constraint string) error {
+	remediation := errors.RemediationError{
+		Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold(fmt.Sprintf("npm install --save-dev %s@%q", name, constraint))),
+	}
+
+	// npm exits with a non-zero status when the package isn't installed, so
+	// we rely on the parsed output rather than the exit status.
+	cmd := exec.Command("npm", "list", "--json", "--depth", "0", name)
+	stdout, _ := cmd.Output()
+
+	version, err := getNPMPackageVersion(stdout, name)
+	if err != nil {
+		remediation.Inner = err
+		return remediation
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("error parsing %s version constraint: %w", name, err)
+	}
+
+	if !c.Check(version) {
+		remediation.Inner = fmt.Errorf("%s version %s not supported", name, version)
+		return remediation
+	}
+
+	fmt.Fprintf(out, "Found %s %s\n", name, version)
+
+	return nil
+}
+
+// getNPMPackageVersion parses the output of `npm list --json` and returns the
+// version of the named top-level dependency as a semver.Version.
+func getNPMPackageVersion(output []byte, name string) (*semver.Version, error) {
+	var pkg NPMPackage
+	if err := json.Unmarshal(output, &pkg); err != nil {
+		return nil, fmt.Errorf("error parsing npm package list: %w", err)
+	}
+
+	dep, ok := pkg.Dependencies[name]
+	if !ok || dep.Version == "" {
+		return nil, fmt.Errorf("%s package not found", name)
+	}
+
+	version, err := semver.NewVersion(dep.Version)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing npm package list: %w", err)
+	}
+
+	return version, nil
+}
@@ -1,17 +1,23 @@
 package compute
 
 import (
-	"bufio"
+	"bytes"
 	"crypto/rand"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/ignore"
 	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/compute/wasm"
 	"github.com/fastly/cli/pkg/config"
 	"github.com/fastly/cli/pkg/text"
 	"github.com/kennygrant/sanitize"
@@ -21,10 +27,18 @@ import (
 // IgnoreFilePath is the filepath name of the Fastly ignore file.
 const IgnoreFilePath = ".fastlyignore"
 
-// Toolchain abstracts a Compute@Edge source language toolchain.
+// packageModTime is the modification time given to every file in a package
+// archive.
+var packageModTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Toolchain abstracts a Compute@Edge source language toolchain. BuildTests
+// compiles the package's tests to WASI test binaries, which may be written to
+// dir, a temporary directory removed once they're run.
 type Toolchain interface {
 	Verify(out io.Writer) error
 	Build(out io.Writer, verbose bool) error
+	BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error)
+	Version() (string, error)
 }
 
 // BuildCommand produces a deployable artifact from files on the local disk.
@@ -35,6 +49,15 @@ type BuildCommand struct {
 	lang       string
 	includeSrc bool
 	force      bool
+	listFiles  bool
+	watch      bool
+	offline    bool
+	strip      bool
+	keepDebug  bool
+	cache      bool
+	jsonEvents bool
+	bin        string
+	pkg        string
 }
 
 // NewBuildCommand returns a usable command registered under the parent.
@@ -45,17 +68,39 @@ func NewBuildCommand(parent common.Registerer, client api.HTTPClient, globals *c
 	c.CmdClause = parent.Command("build", "Build a Compute@Edge package locally")
 	c.CmdClause.Flag("name", "Package name").StringVar(&c.name)
 	c.CmdClause.Flag("language", "Language type").StringVar(&c.lang)
+	c.CmdClause.Flag("bin", "Name of the binary to build, for Rust packages with more than one").StringVar(&c.bin)
+	c.CmdClause.Flag("package", "Name of the Cargo package to build, for Rust workspaces with more than one").StringVar(&c.pkg)
 	c.CmdClause.Flag("include-source", "Include source code in built package").BoolVar(&c.includeSrc)
 	c.CmdClause.Flag("force", "Skip verification steps and force build").BoolVar(&c.force)
+	c.CmdClause.Flag("offline", "Skip checking remote sources for the latest versions of dependencies, using cached or locked versions instead").BoolVar(&c.offline)
+	c.CmdClause.Flag("watch", "Rebuild the package whenever its source files change").BoolVar(&c.watch)
+	c.CmdClause.Flag("strip", "Remove debug information and other custom sections from the Wasm binary before packaging it").BoolVar(&c.strip)
+	c.CmdClause.Flag("keep-debug", "When stripping, keep the unstripped Wasm binary beside the package as pkg/<name>.debug.wasm").BoolVar(&c.keepDebug)
+	c.CmdClause.Flag("cache", "Skip building the package if the files and settings it is built from haven't changed since it was last built (use --no-cache to always build)").Default("true").NegatableBoolVar(&c.cache)
+	c.CmdClause.Flag("json-events", "Write progress as newline-delimited JSON events, for CI systems, instead of text").BoolVar(&c.jsonEvents)
+	c.CmdClause.Flag("list-files", "Print the files which would be included in the package archive, without building it").BoolVar(&c.listFiles)
 	return &c
 }
 
 // Exec implements the command interface.
 func (c *BuildCommand) Exec(in io.Reader, out io.Writer) (err error) {
+	if c.watch {
+		if c.jsonEvents {
+			return fmt.Errorf("--json-events can't be used with --watch")
+		}
+		return c.watchFiles(in, out)
+	}
+
+	// JSON events replace all of the text output, so the messages written
+	// once the build is done are written as events too.
 	var progress text.Progress
-	if c.Globals.Verbose() {
+	switch {
+	case c.jsonEvents:
+		progress = text.NewJSONProgress(out)
+		out = progress
+	case c.Globals.Verbose():
 		progress = text.NewVerboseProgress(out)
-	} else {
+	default:
 		progress = text.NewQuietProgress(out)
 	}
 
@@ -72,17 +117,16 @@ func (c *BuildCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		return fmt.Errorf("error reading package manifest: %w", err)
 	}
 
-	// Language from flag takes priority, otherwise infer from manifest and
-	// error if neither are provided. Sanitize by trim and lowercase.
-	var lang string
-	if c.lang != "" {
-		lang = c.lang
-	} else if m.Language != "" {
-		lang = m.Language
-	} else {
-		return fmt.Errorf("language cannot be empty, please provide a language")
+	language, scripts, err := c.resolveLanguage(m, c.bin, c.pkg)
+	if err != nil {
+		return err
+	}
+	lang := language.Name
+
+	// Only the Rust toolchain chooses the binary to build.
+	if _, ok := language.Toolchain.(*Rust); !ok && (c.bin != "" || c.pkg != "") {
+		return fmt.Errorf("--bin and --package can only be used when building with the Rust toolchain")
 	}
-	lang = strings.ToLower(strings.TrimSpace(lang))
 
 	// Name from flag takes priority, otherwise infer from manifest
 	// error if neither are provided. Sanitize value to ensure it is a safe
@@ -97,78 +141,276 @@ func (c *BuildCommand) Exec(in io.Reader, out io.Writer) (err error) {
 	}
 	name = sanitize.BaseName(name)
 
-	var toolchain Toolchain
-	switch lang {
-	case "rust":
-		toolchain = &Rust{c.client}
-	default:
-		return fmt.Errorf("unsupported language %s", lang)
+	if c.listFiles {
+		files, err := packageFiles(language, c.includeSrc)
+		if err != nil {
+			return err
+		}
+
+		progress.Done()
+
+		text.Break(out)
+		for _, f := range append(files, PackageMetadataFilename) {
+			fmt.Fprintln(out, filepath.ToSlash(f))
+		}
+		return nil
 	}
 
 	if !c.force {
 		progress.Step(fmt.Sprintf("Verifying local %s toolchain...", lang))
 
-		err = toolchain.Verify(progress)
+		err = language.Verify(progress)
 		if err != nil {
 			return err
 		}
 	}
 
-	progress.Step(fmt.Sprintf("Building package using %s toolchain...", lang))
+	// Stripping is enabled by either the flag or the manifest, which allows
+	// a package to always be built without debug information.
+	strip, keepDebug := c.strip, c.keepDebug
+	if m.Build != nil {
+		strip = strip || m.Build.Strip
+		keepDebug = keepDebug || m.Build.KeepDebug
+	}
 
-	if err := toolchain.Build(progress, c.Globals.Flag.Verbose); err != nil {
-		return err
+	dest := filepath.Join("pkg", fmt.Sprintf("%s.tar.gz", name))
+
+	// The pre_build script runs before checking for changes, as it may
+	// generate the files which the package is built from.
+	if scripts.PreBuild != "" {
+		progress.Step("Running pre_build script...")
+
+		if err := runScript(progress, "pre_build", scripts.PreBuild, c.Globals.Flag.Verbose); err != nil {
+			return err
+		}
 	}
 
-	progress.Step("Creating package archive...")
+	// The package is up to date if the inputs to the build are unchanged
+	// since it was last built. A toolchain whose version can't be read isn't
+	// cached, and the build reports why it can't be used, except for a custom
+	// build script, which may read any file and run any compiler, so is never
+	// cached.
+	_, custom := language.Toolchain.(*Custom)
+	fingerprintPath := buildFingerprintPath(name)
+	settings := []string{
+		fmt.Sprintf("name=%s", name),
+		fmt.Sprintf("include-source=%t", c.includeSrc),
+		fmt.Sprintf("strip=%t", strip),
+		fmt.Sprintf("keep-debug=%t", keepDebug),
+		fmt.Sprintf("bin=%s", c.bin),
+		fmt.Sprintf("package=%s", c.pkg),
+	}
+	toolchainVersion, versionErr := language.Version()
 
-	dest := filepath.Join("pkg", fmt.Sprintf("%s.tar.gz", name))
+	if c.cache && versionErr == nil && common.FileExists(dest) {
+		progress.Step("Checking for changes since the last build...")
 
-	files := []string{
-		ManifestFilename,
-		"Cargo.toml",
+		fingerprint, err := buildFingerprint(language, toolchainVersion, settings...)
+		if err == nil && fingerprint == readBuildFingerprint(fingerprintPath) {
+			text.Artifact(progress, "package", dest)
+			progress.Done()
+			text.Success(out, "Package %s (%s) is up to date", name, dest)
+			return nil
+		}
 	}
 
-	ignoreFiles, err := getIgnoredFiles(IgnoreFilePath)
-	if err != nil {
-		return err
+	// The fingerprint of the last build is removed first, so that a failed
+	// build isn't mistaken for an up to date one.
+	if err := os.Remove(fingerprintPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing build fingerprint: %w", err)
 	}
 
-	binFiles, err := getNonIgnoredFiles("bin", ignoreFiles)
-	if err != nil {
+	progress.Step(fmt.Sprintf("Building package using %s toolchain...", lang))
+
+	if err := language.Build(progress, c.Globals.Flag.Verbose); err != nil {
 		return err
 	}
-	files = append(files, binFiles...)
 
-	if c.includeSrc {
-		// TODO(phamann): we will need to lookup the directory name based on the
-		// source language type when we support multiple languages.
-		srcFiles, err := getNonIgnoredFiles("src", ignoreFiles)
+	if scripts.PostBuild != "" {
+		progress.Step("Running post_build script...")
+
+		if err := runScript(progress, "post_build", scripts.PostBuild, c.Globals.Flag.Verbose); err != nil {
+			return err
+		}
+	}
+
+	var (
+		sizeBefore, sizeAfter int
+		debugPath             string
+	)
+	if strip {
+		progress.Step("Stripping Wasm binary...")
+
+		if keepDebug {
+			debugPath = filepath.Join("pkg", fmt.Sprintf("%s.debug.wasm", name))
+		}
+		sizeBefore, sizeAfter, err = stripWasmBinary(filepath.Join("bin", "main.wasm"), debugPath)
 		if err != nil {
 			return err
 		}
-		files = append(files, srcFiles...)
 	}
 
-	err = createPackageArchive(files, dest)
+	progress.Step("Creating package archive...")
+
+	files, err := packageFiles(language, c.includeSrc)
+	if err != nil {
+		return err
+	}
+
+	err = createPackageArchive(".", files, dest)
 	if err != nil {
 		return fmt.Errorf("error creating package archive: %w", err)
 	}
 
+	// Failing to record the fingerprint only means the next build isn't
+	// skipped, so it isn't an error.
+	var fingerprintErr error
+	if !custom {
+		fingerprintErr = versionErr
+		if fingerprintErr == nil {
+			var fingerprint string
+			fingerprint, fingerprintErr = buildFingerprint(language, toolchainVersion, settings...)
+			if fingerprintErr == nil {
+				fingerprintErr = writeBuildFingerprint(fingerprintPath, fingerprint)
+			}
+		}
+	}
+
+	text.Artifact(progress, "package", dest)
+	if debugPath != "" && sizeAfter < sizeBefore {
+		text.Artifact(progress, "debug_wasm", debugPath)
+	}
 	progress.Done()
 
+	if fingerprintErr != nil {
+		text.Warning(out, "Unable to record the build fingerprint, so the next build won't be skipped: %v", fingerprintErr)
+	}
+
+	if c.isOffline() && !c.force {
+		text.Warning(out, "Built offline, so the latest versions of dependencies weren't checked")
+	}
+
+	if strip {
+		text.Info(out, "Stripped Wasm binary from %d to %d bytes", sizeBefore, sizeAfter)
+		if debugPath != "" && sizeAfter < sizeBefore {
+			text.Info(out, "Kept the unstripped Wasm binary in %s", debugPath)
+		}
+	}
+
 	text.Success(out, "Built %s package %s (%s)", lang, name, dest)
 	return nil
 }
 
+// resolveLanguage returns the language of the package, which is built using a
+// custom toolchain if the manifest defines a build script, and the scripts
+// defined by the manifest. The Rust toolchain builds the given binary and
+// package of a Cargo workspace.
+func (c *BuildCommand) resolveLanguage(m manifest.File, bin, pkg string) (*Language, manifest.Scripts, error) {
+	// A custom build script doesn't require a language.
+	var scripts manifest.Scripts
+	if m.Scripts != nil {
+		scripts = *m.Scripts
+	}
+
+	// Language from flag takes priority, otherwise infer from manifest and
+	// error if neither are provided. Sanitize by trim and lowercase.
+	var lang string
+	if c.lang != "" {
+		lang = c.lang
+	} else if m.Language != "" {
+		lang = m.Language
+	} else if scripts.Build == "" {
+		return nil, scripts, fmt.Errorf("language cannot be empty, please provide a language")
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+
+	rust, err := m.RustSettings()
+	if err != nil {
+		return nil, scripts, fmt.Errorf("error reading package manifest: %w", err)
+	}
+
+	cratesEndpoint, _ := c.Globals.CratesEndpoint()
+	languages := newLanguages(toolchainOptions{
+		client:         c.client,
+		cratesEndpoint: cratesEndpoint,
+		crateCacheFile: crateCacheFilePath,
+		offline:        c.isOffline(),
+		rustToolchain:  rust.Toolchain,
+		rustBin:        bin,
+		rustPackage:    pkg,
+	})
+
+	if scripts.Build != "" {
+		return newCustomLanguage(languages, lang, scripts.Build), scripts, nil
+	}
+
+	language, ok := getLanguage(languages, lang)
+	if !ok {
+		return nil, scripts, fmt.Errorf("unsupported language %s", lang)
+	}
+	return language, scripts, nil
+}
+
+// isOffline reports whether the build shouldn't check remote sources, which
+// is set by either the --offline flag or the offline config file setting.
+func (c *BuildCommand) isOffline() bool {
+	return c.offline || c.Globals.File.Offline
+}
+
+// packageFiles returns the files which make up a package of the language,
+// which are the package manifest, the language's package files and any files
+// in the bin directory, and optionally its source directory, which aren't
+// ignored by the .fastlyignore file. Each file is listed once, even when the
+// source directory contains the others.
+func packageFiles(language *Language, includeSrc bool) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(paths ...string) {
+		for _, p := range paths {
+			p = filepath.Clean(p)
+			if !seen[p] {
+				seen[p] = true
+				files = append(files, p)
+			}
+		}
+	}
+	add(ManifestFilename)
+	add(language.IncludeFiles...)
+
+	matcher, err := ignore.ReadFile(IgnoreFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s file: %w", IgnoreFilePath, err)
+	}
+
+	// The bin directory doesn't exist until the package is first built.
+	if common.FileExists("bin") {
+		binFiles, err := getNonIgnoredFiles("bin", matcher)
+		if err != nil {
+			return nil, err
+		}
+		add(binFiles...)
+	}
+
+	if includeSrc {
+		srcFiles, err := getSourceFiles(language.SourceDirectory, matcher)
+		if err != nil {
+			return nil, err
+		}
+		add(srcFiles...)
+	}
+
+	return files, nil
+}
+
 // createPackageArchive packages build artifacts as a Fastly package, which
-// must be a GZipped Tar archive such as: package-name.tar.gz.
+// must be a GZipped Tar archive such as: package-name.tar.gz. The files are
+// paths relative to the root directory, and keep those paths in the package.
 //
 // Due to a behavior of archiver.Archive() which recursively writes all files in
 // a provided directory to the archive we first copy our input files to a
 // temporary directory to ensure only the specified files are included and not
 // any in the directory which may be ignored.
-func createPackageArchive(files []string, destination string) error {
+func createPackageArchive(root string, files []string, destination string) error {
 	// Create temporary directory to copy files into.
 	p := make([]byte, 8)
 	n, err := rand.Read(p)
@@ -194,13 +436,33 @@ func createPackageArchive(files []string, destination string) error {
 		return fmt.Errorf("error creating temporary directory: %w", err)
 	}
 
-	for _, src := range files {
-		dst := filepath.Join(dir, src)
-		if err = common.CopyFile(src, dst); err != nil {
+	for _, f := range files {
+		dst := filepath.Join(dir, f)
+		if err = common.CopyFile(filepath.Join(root, f), dst); err != nil {
 			return fmt.Errorf("error copying file: %w", err)
 		}
 	}
 
+	metadata, err := newPackageMetadata(root, files)
+	if err != nil {
+		return err
+	}
+	if err := metadata.Write(filepath.Join(dir, PackageMetadataFilename)); err != nil {
+		return fmt.Errorf("error writing package metadata: %w", err)
+	}
+
+	// Reset the modification time of every file so that building unchanged
+	// files produces an identical archive, and therefore an identical hash.
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, packageModTime, packageModTime)
+	})
+	if err != nil {
+		return fmt.Errorf("error preparing package files: %w", err)
+	}
+
 	tar := archiver.NewTarGz()
 	tar.OverwriteExisting = true //
 	tar.MkdirAll = true          // make destination directory if it doesn't exist
@@ -212,73 +474,175 @@ func createPackageArchive(files []string, destination string) error {
 	return nil
 }
 
-// fileNameWithoutExtension returns a filename with its extension stripped.
-func fileNameWithoutExtension(filename string) string {
-	base := filepath.Base(filename)
-	firstDot := strings.Index(base, ".")
-	if firstDot > -1 {
-		return base[:firstDot]
+// stripWasmBinary rewrites the Wasm binary at path without its custom
+// sections, returning its size before and after. If debugPath is set, the
+// unstripped binary is first copied there, unless it has nothing to strip, so
+// that stripping an already stripped binary doesn't overwrite the copy.
+func stripWasmBinary(path, debugPath string) (before, after int, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading Wasm binary: %w", err)
 	}
-	return base
-}
-
-// getIgnoredFiles reads the .fastlyignore file line-by-line and expands the
-// glob pattern into a map containing all files it matches. If no ignore file
-// is present it returns an empty map.
-func getIgnoredFiles(filePath string) (files map[string]bool, err error) {
-	files = make(map[string]bool)
-
-	if !common.FileExists(filePath) {
-		return files, nil
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading Wasm binary: %w", err)
 	}
 
-	// gosec flagged this:
-	// G304 (CWE-22): Potential file inclusion via variable
-	// Disabling as we trust the source of the filepath variable as it comes
-	// from the IgnoreFilePath constant.
-	/* #nosec */
-	file, err := os.Open(filePath)
+	stripped, err := wasm.Strip(data)
 	if err != nil {
-		return files, err
+		return 0, 0, fmt.Errorf("error stripping Wasm binary: malformed Wasm module: %w", err)
 	}
-	defer func() {
-		cerr := file.Close()
-		if err == nil {
-			err = cerr
+	if len(stripped) == len(data) {
+		return len(data), len(stripped), nil
+	}
+
+	if debugPath != "" {
+		if err := os.MkdirAll(filepath.Dir(debugPath), 0750); err != nil {
+			return 0, 0, fmt.Errorf("error writing debug Wasm binary: %w", err)
+		}
+		if err := ioutil.WriteFile(debugPath, data, 0600); err != nil {
+			return 0, 0, fmt.Errorf("error writing debug Wasm binary: %w", err)
 		}
+	}
+
+	if err := ioutil.WriteFile(path, stripped, fi.Mode()); err != nil {
+		return 0, 0, fmt.Errorf("error writing stripped Wasm binary: %w", err)
+	}
+	return len(data), len(stripped), nil
+}
+
+// streamCommand runs a toolchain command, streaming its stdout and stderr to
+// out. If the command fails and we're not in verbose mode, the buffered stderr
+// output is returned as the error.
+func streamCommand(cmd *exec.Cmd, out io.Writer, verbose bool) error {
+	return streamCommandOutput(cmd, out, out, verbose)
+}
+
+// streamCommandOutput runs a toolchain command as streamCommand does, but
+// streams its stdout and stderr to separate writers.
+func streamCommandOutput(cmd *exec.Cmd, stdoutOut, stderrOut io.Writer, verbose bool) error {
+	// Pipe the child process stdout and stderr to our own writers.
+	var stdoutBuf, stderrBuf bytes.Buffer
+	stdoutIn, _ := cmd.StdoutPipe()
+	stderrIn, _ := cmd.StderrPipe()
+	stdout := io.MultiWriter(stdoutOut, &stdoutBuf)
+	stderr := io.MultiWriter(stderrOut, &stderrBuf)
+
+	// Start the command.
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start compilation process: %w", err)
+	}
+
+	var errStdout, errStderr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		_, errStdout = io.Copy(stdout, stdoutIn)
+		wg.Done()
 	}()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		glob := strings.TrimSpace(scanner.Text())
-		globFiles, err := filepath.Glob(glob)
-		if err != nil {
-			return files, fmt.Errorf("parsing glob %s: %w", glob, err)
-		}
-		for _, f := range globFiles {
-			files[f] = true
+	_, errStderr = io.Copy(stderr, stderrIn)
+	wg.Wait()
+
+	if errStdout != nil {
+		return fmt.Errorf("error streaming stdout output from child process: %w", errStdout)
+	}
+	if errStderr != nil {
+		return fmt.Errorf("error streaming stderr output from child process: %w", errStderr)
+	}
+
+	// Wait for the command to exit.
+	if err := cmd.Wait(); err != nil {
+		// If we're not in verbose mode return the bufferred stderr output
+		// from the toolchain as the error.
+		if !verbose && stderrBuf.Len() > 0 {
+			return fmt.Errorf("error during compilation process:\n%s", strings.TrimSpace(stderrBuf.String()))
 		}
+		return fmt.Errorf("error during compilation process")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return files, fmt.Errorf("reading %s file: %w", filePath, err)
+	return nil
+}
+
+// createBinDirectory checks if the bin directory of a package exists and
+// creates it if not.
+func createBinDirectory(binDir string) error {
+	fi, err := os.Stat(binDir)
+	switch {
+	case err == nil && fi.IsDir():
+		// no problem
+	case err == nil && !fi.IsDir():
+		return fmt.Errorf("error creating bin directory: target already exists as a regular file")
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(binDir, 0750); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
 	}
+	return nil
+}
 
-	return files, nil
+// isBuildOutput reports whether a file path relative to the package root is
+// within the bin or pkg output directories, or is a hidden file such as those
+// in a .git directory.
+func isBuildOutput(path string) bool {
+	root := strings.SplitN(filepath.ToSlash(filepath.Clean(path)), "/", 2)[0]
+	return root == "bin" || root == "pkg" || strings.HasPrefix(root, ".")
+}
+
+// fileNameWithoutExtension returns a filename with its extension stripped.
+func fileNameWithoutExtension(filename string) string {
+	base := filepath.Base(filename)
+	firstDot := strings.Index(base, ".")
+	if firstDot > -1 {
+		return base[:firstDot]
+	}
+	return base
 }
 
-// getNonIgnoredFiles walks a filepath and returns all files don't exist in the
-// provided ignore files map.
-func getNonIgnoredFiles(base string, ignoredFiles map[string]bool) ([]string, error) {
+// getSourceFiles walks a source directory and returns all files which aren't
+// ignored by the matcher. Languages whose source lives in the package root
+// would otherwise include the build output and any previously built packages,
+// so those directories are skipped entirely.
+func getSourceFiles(base string, matcher *ignore.Matcher) ([]string, error) {
 	var files []string
 	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if path != base && (isBuildOutput(path) || matcher.Match(path, info.IsDir())) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if info.IsDir() {
 			return nil
 		}
-		if ignoredFiles[path] {
+		files = append(files, path)
+		return nil
+	})
+
+	return files, err
+}
+
+// getNonIgnoredFiles walks a filepath and returns all files which aren't
+// ignored by the matcher. Ignored directories are skipped entirely.
+func getNonIgnoredFiles(base string, matcher *ignore.Matcher) ([]string, error) {
+	var files []string
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if matcher.Match(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
 			return nil
 		}
 		files = append(files, path)
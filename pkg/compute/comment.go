@@ -0,0 +1,79 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	gotemplate "text/template"
+	"time"
+)
+
+// defaultVersionCommentTemplate is the template of the comment set on a
+// version by deploy, unless it is overridden by --version-comment-template.
+const defaultVersionCommentTemplate = `Deployed {{if .Commit}}commit {{.Commit}}{{if .Dirty}} (dirty){{end}}{{if .Branch}} on branch {{.Branch}}{{end}}, {{end}}package {{printf "%.16s" .PackageHash}} with Fastly CLI {{.CLIVersion}} at {{.Timestamp.UTC.Format "2006-01-02T15:04:05Z"}}`
+
+// versionCommentData is the data available to a version comment template.
+// Commit, Dirty and Branch describe the git repository the package was
+// deployed from, and are empty if it isn't in one. Branch is also empty if
+// the repository has a detached HEAD. PackageHash is the SHA-512 hash of the
+// package archive, as reported by the package API.
+type versionCommentData struct {
+	Commit      string
+	Dirty       bool
+	Branch      string
+	PackageHash string
+	CLIVersion  string
+	Timestamp   time.Time
+}
+
+// parseVersionCommentTemplate parses a version comment template, using the
+// default template if text is empty.
+func parseVersionCommentTemplate(text string) (*gotemplate.Template, error) {
+	if text == "" {
+		text = defaultVersionCommentTemplate
+	}
+	tmpl, err := gotemplate.New("version_comment").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing version comment template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// versionComment renders the version comment template with the data.
+func versionComment(tmpl *gotemplate.Template, data versionCommentData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering version comment template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// readGitState sets the commit, dirty flag and branch of the git repository
+// containing dir. They are left empty if git isn't installed or dir isn't in
+// a repository, as the comment is informational.
+func (d *versionCommentData) readGitState(dir string) {
+	git := func(args ...string) (string, bool) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(stdout.String()), true
+	}
+
+	commit, ok := git("rev-parse", "HEAD")
+	if !ok {
+		return
+	}
+	d.Commit = commit
+
+	if status, ok := git("status", "--porcelain"); ok && status != "" {
+		d.Dirty = true
+	}
+	if branch, ok := git("rev-parse", "--abbrev-ref", "HEAD"); ok && branch != "HEAD" {
+		d.Branch = branch
+	}
+}
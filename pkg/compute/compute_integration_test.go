@@ -3,6 +3,7 @@ package compute_test
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha512"
 	"errors"
 	"fmt"
 	"io"
@@ -10,19 +11,23 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/app"
 	"github.com/fastly/cli/pkg/common"
 	"github.com/fastly/cli/pkg/compute"
+	"github.com/fastly/cli/pkg/compute/wasm"
 	"github.com/fastly/cli/pkg/config"
 	"github.com/fastly/cli/pkg/mock"
 	"github.com/fastly/cli/pkg/testutil"
 	"github.com/fastly/cli/pkg/update"
 	"github.com/fastly/go-fastly/fastly"
+	"github.com/mholt/archiver/v3"
 )
 
 func TestInit(t *testing.T) {
@@ -48,6 +53,16 @@ func TestInit(t *testing.T) {
 			args:      []string{"compute", "init"},
 			wantError: "no token provided",
 		},
+		{
+			name:       "unsupported language",
+			args:       []string{"compute", "init", "--language", "cobol"},
+			configFile: config.File{Token: "123"},
+			api: mock.API{
+				GetTokenSelfFn: tokenOK,
+				GetUserFn:      getUserOk,
+			},
+			wantError: "unsupported language cobol",
+		},
 		{
 			name:       "unkown repository",
 			args:       []string{"compute", "init", "--from", "https://example.com/template"},
@@ -288,18 +303,194 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestInitTemplateSources(t *testing.T) {
+	// A template containing variables, in a local directory and archive.
+	templatedir := makeInitEnvironment(t)
+	defer os.RemoveAll(templatedir)
+
+	templateFiles := map[string]string{
+		"fastly.toml":  "name = \"{{name}}\"\nlanguage = \"rust\"\n",
+		"Cargo.toml":   "[package]\nname = \"{{name}}\"\nauthors = [\"{{author}}\"]\n",
+		"src/main.rs":  "// Service {{service_id}}\n",
+		".git/HEAD":    "ref: refs/heads/main\n",
+		"sub/keep.txt": "{{name}}",
+	}
+	for name, content := range templateFiles {
+		path := filepath.Join(templatedir, "template", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(templatedir, "template.tar.gz")
+	if err := archiver.NewTarGz().Archive([]string{filepath.Join(templatedir, "template")}, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	initAPI := mock.API{
+		GetTokenSelfFn:  tokenOK,
+		GetUserFn:       getUserOk,
+		CreateServiceFn: createServiceOK,
+		CreateDomainFn:  createDomainOK,
+		CreateBackendFn: createBackendOK,
+		DeleteServiceFn: deleteServiceOK,
+		DeleteBackendFn: deleteBackendOK,
+		DeleteDomainFn:  deleteDomainOK,
+	}
+
+	for _, testcase := range []struct {
+		name          string
+		args          []string
+		wantError     string
+		wantFiles     map[string]string
+		unwantedFiles []string
+		wantOutput    []string
+		localOnly     bool
+	}{
+		{
+			name:      "non-interactive without language",
+			args:      []string{"compute", "init", "--non-interactive"},
+			wantError: "a language or template is required when not prompting for input",
+		},
+		{
+			name:      "invalid template",
+			args:      []string{"compute", "init", "--non-interactive", "--from", "not a template"},
+			wantError: "invalid template not a template",
+		},
+		{
+			name: "local directory",
+			args: []string{"compute", "init", "--non-interactive", "--name", "test", "--author", "test@example.com", "--from", filepath.Join(templatedir, "template")},
+			wantFiles: map[string]string{
+				"Cargo.toml":   "[package]\nname = \"test\"\nauthors = [\"test@example.com\"]\n",
+				"src/main.rs":  "// Service 12345\n",
+				"sub/keep.txt": "test",
+			},
+			unwantedFiles: []string{".git"},
+		},
+		{
+			name: "archive",
+			args: []string{"compute", "init", "--accept-defaults", "--name", "test", "--from", archive},
+			wantFiles: map[string]string{
+				"src/main.rs":  "// Service 12345\n",
+				"sub/keep.txt": "test",
+			},
+		},
+		{
+			name:      "missing archive",
+			args:      []string{"compute", "init", "--accept-defaults", "--from", filepath.Join(templatedir, "missing.tar.gz")},
+			wantError: "template archive",
+		},
+		{
+			name:      "local only with service ID",
+			args:      []string{"compute", "init", "--local-only", "--service-id", "123"},
+			wantError: "--local-only can't be used with --service-id",
+		},
+		{
+			name: "local only",
+			args: []string{"compute", "init", "--local-only", "--accept-defaults", "--name", "test", "--from", filepath.Join(templatedir, "template")},
+			wantFiles: map[string]string{
+				"src/main.rs": "// Service \n",
+			},
+			localOnly: true,
+		},
+		{
+			name: "json events",
+			args: []string{"compute", "init", "--json-events", "--name", "test", "--from", filepath.Join(templatedir, "template")},
+			wantFiles: map[string]string{
+				"src/main.rs": "// Service 12345\n",
+			},
+			wantOutput: []string{
+				`{"event":"step_started","time":`,
+				`"step":"Fetching package template..."`,
+				`"service_id":"12345","version":1}}`,
+				`"message":"SUCCESS: Initialized service 12345"}`,
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			pwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rootdir := makeInitEnvironment(t)
+			defer os.RemoveAll(rootdir)
+
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			var (
+				args                           = testcase.args
+				env                            = config.Environment{}
+				file                           = config.File{Token: "123"}
+				appConfigFile                  = "/dev/null"
+				clientFactory                  = mock.APIClient(initAPI)
+				httpClient                     = http.DefaultClient
+				versioner     update.Versioner = nil
+				in            io.Reader        = strings.NewReader("")
+				buf           bytes.Buffer
+				out           io.Writer = common.NewSyncWriter(&buf)
+			)
+			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			for name, want := range testcase.wantFiles {
+				content, err := ioutil.ReadFile(filepath.Join(rootdir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatalf("wanted file %s not found", name)
+				}
+				testutil.AssertString(t, want, string(content))
+			}
+			for _, name := range testcase.unwantedFiles {
+				if _, err := os.Stat(filepath.Join(rootdir, name)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("unwanted file %s found", name)
+				}
+			}
+			for _, s := range testcase.wantOutput {
+				testutil.AssertStringContains(t, buf.String(), s)
+			}
+			if testcase.localOnly {
+				testutil.AssertStringContains(t, buf.String(), "Initialized package test locally")
+				content, err := ioutil.ReadFile(filepath.Join(rootdir, compute.ManifestFilename))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(string(content), "service_id") {
+					t.Errorf("unwanted service_id in manifest:\n%s", content)
+				}
+			} else if testcase.wantError == "" {
+				testutil.AssertStringContains(t, buf.String(), "Initialized service 12345")
+				content, err := ioutil.ReadFile(filepath.Join(rootdir, compute.ManifestFilename))
+				if err != nil {
+					t.Fatal(err)
+				}
+				testutil.AssertStringContains(t, string(content), `service_id = "12345"`)
+			}
+		})
+	}
+}
+
 func TestBuild(t *testing.T) {
 	if os.Getenv("TEST_COMPUTE_BUILD") == "" {
 		t.Log("skipping test")
 		t.Skip("Set TEST_COMPUTE_BUILD to run this test")
 	}
 
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "inspect", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	for _, testcase := range []struct {
 		name                 string
 		args                 []string
 		fastlyManifest       string
 		cargoManifest        string
 		cargoLock            string
+		files                map[string]string
 		client               api.HTTPClient
 		wantError            string
 		wantRemediationError string
@@ -328,9 +519,9 @@ func TestBuild(t *testing.T) {
 		{
 			name:           "unknown language",
 			args:           []string{"compute", "build"},
-			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			fastlyManifest: "name = \"test\"\nlanguage = \"cobol\"\n",
 			client:         versionClient{[]string{"0.0.0"}},
-			wantError:      "unsupported language javascript",
+			wantError:      "unsupported language cobol",
 		},
 		{
 			name:           "error reading cargo metadata",
@@ -367,6 +558,126 @@ func TestBuild(t *testing.T) {
 			client:             versionClient{[]string{"0.0.0"}},
 			wantOutputContains: "Built rust package test",
 		},
+		{
+			name:               "list files",
+			args:               []string{"compute", "build", "--list-files", "--include-source"},
+			fastlyManifest:     "name = \"test\"\nlanguage = \"rust\"\n",
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "fastly.toml\nCargo.toml\nsrc/main.rs\npackage-metadata.json\n",
+		},
+		{
+			name:           "javascript @fastly/js-compute not found",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			files: map[string]string{
+				"package.json": `{"name":"test","version":"0.1.0"}`,
+			},
+			client:               versionClient{[]string{"0.0.0"}},
+			wantError:            "@fastly/js-compute package not found",
+			wantRemediationError: "npm install --save-dev @fastly/js-compute@\">= 0.2.0\"",
+		},
+		{
+			name:           "javascript @fastly/js-compute out-of-date",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			files: map[string]string{
+				"package.json": `{"name":"test","version":"0.1.0","devDependencies":{"@fastly/js-compute":"^0.1.0"}}`,
+				"node_modules/@fastly/js-compute/package.json": `{"name":"@fastly/js-compute","version":"0.1.0"}`,
+			},
+			client:               versionClient{[]string{"0.0.0"}},
+			wantError:            "@fastly/js-compute version 0.1.0 not supported",
+			wantRemediationError: "npm install --save-dev @fastly/js-compute@\">= 0.2.0\"",
+		},
+		{
+			name:           "javascript js-compute-runtime not found",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			files: map[string]string{
+				"package.json": `{"name":"test","version":"0.1.0","devDependencies":{"@fastly/js-compute":"^0.2.0"}}`,
+				"node_modules/@fastly/js-compute/package.json": `{"name":"@fastly/js-compute","version":"0.2.0"}`,
+			},
+			client:               versionClient{[]string{"0.0.0"}},
+			wantError:            "js-compute-runtime not found in node_modules/.bin",
+			wantRemediationError: "npm install",
+		},
+		{
+			name:           "javascript success",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			files: map[string]string{
+				"package.json": `{"name":"test","version":"0.1.0","devDependencies":{"@fastly/js-compute":"^0.2.0"}}`,
+				"node_modules/@fastly/js-compute/package.json": `{"name":"@fastly/js-compute","version":"0.2.0"}`,
+				"node_modules/.bin/js-compute-runtime":         "#!/bin/sh\necho wasm > \"$2\"\n",
+			},
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "Built javascript package test",
+		},
+		{
+			name:           "javascript version unreadable",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"javascript\"\n",
+			files: map[string]string{
+				"package.json": `{"name":"test","version":"0.1.0","devDependencies":{"@fastly/js-compute":"^0.3.0"}}`,
+				"node_modules/@fastly/js-compute/package.json": `{"name":"@fastly/js-compute","version":"0.2.0"}`,
+				"node_modules/.bin/js-compute-runtime":         "#!/bin/sh\necho wasm > \"$2\"\n",
+			},
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "next build won't be skipped: error executing npm list",
+		},
+		{
+			name:               "custom build script",
+			args:               []string{"compute", "build", "--force"},
+			fastlyManifest:     "name = \"test\"\n\n[scripts]\nbuild = \"echo wasm > bin/main.wasm\"\npre_build = \"echo running pre_build\"\npost_build = \"echo running post_build\"\n",
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "Built custom package test",
+		},
+		{
+			name:                 "custom build script without binary",
+			args:                 []string{"compute", "build", "--force"},
+			fastlyManifest:       "name = \"test\"\n\n[scripts]\nbuild = \"echo no binary\"\n",
+			client:               versionClient{[]string{"0.0.0"}},
+			wantError:            "build script did not produce",
+			wantRemediationError: "[scripts] section",
+		},
+		{
+			name:           "custom build script with stale binary",
+			args:           []string{"compute", "build", "--force"},
+			fastlyManifest: "name = \"test\"\n\n[scripts]\nbuild = \"echo no binary\"\n",
+			files: map[string]string{
+				"bin/main.wasm": "stale",
+			},
+			client:               versionClient{[]string{"0.0.0"}},
+			wantError:            "build script did not produce",
+			wantRemediationError: "[scripts] section",
+		},
+		{
+			name:           "bin with custom build script",
+			args:           []string{"compute", "build", "--force", "--bin", "test"},
+			fastlyManifest: "name = \"test\"\n\n[scripts]\nbuild = \"echo wasm > bin/main.wasm\"\n",
+			client:         versionClient{[]string{"0.0.0"}},
+			wantError:      "--bin and --package can only be used when building with the Rust toolchain",
+		},
+		{
+			name:               "strip",
+			args:               []string{"compute", "build", "--force", "--strip"},
+			fastlyManifest:     fmt.Sprintf("name = \"test\"\n\n[scripts]\nbuild = \"cp %s bin/main.wasm\"\n", wasmBinary),
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "Stripped Wasm binary from 242 to 118 bytes",
+		},
+		{
+			name:               "strip from manifest keeping debug binary",
+			args:               []string{"compute", "build", "--force"},
+			fastlyManifest:     fmt.Sprintf("name = \"test\"\n\n[scripts]\nbuild = \"cp %s bin/main.wasm\"\n\n[build]\nstrip = true\nkeep_debug = true\n", wasmBinary),
+			client:             versionClient{[]string{"0.0.0"}},
+			wantOutputContains: "Kept the unstripped Wasm binary in pkg/test.debug.wasm",
+		},
+		{
+			name:           "failing pre_build script",
+			args:           []string{"compute", "build"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"rust\"\n\n[scripts]\nbuild = \"echo wasm > bin/main.wasm\"\npre_build = \"exit 1\"\n",
+			client:         versionClient{[]string{"0.0.0"}},
+			wantError:      "error running pre_build script",
+		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
 			// We're going to chdir to a build environment,
@@ -389,6 +700,15 @@ func TestBuild(t *testing.T) {
 			}
 			defer os.Chdir(pwd)
 
+			for filename, content := range testcase.files {
+				if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filename, []byte(content), 0777); err != nil {
+					t.Fatal(err)
+				}
+			}
+
 			var (
 				args                           = testcase.args
 				env                            = config.Environment{}
@@ -411,59 +731,428 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestBuildCache(t *testing.T) {
+	// The version of the JavaScript toolchain is read with npm.
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm not found in $PATH")
+	}
+
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "inspect", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-build-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	writeFile := func(filename, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The JavaScript compiler is stood in for by a script which copies a Wasm
+	// binary, so that the package builds without installing it.
+	manifest := "name = \"test\"\nlanguage = \"javascript\"\n\n[scripts]\n"
+	writeFile(compute.ManifestFilename, manifest)
+	writeFile(compute.IgnoreFilePath, "src/ignored.txt\n")
+	writeFile(filepath.Join("src", "index.js"), "addEventListener(\"fetch\", () => {})\n")
+	writeFile("package.json", `{"name":"test","version":"0.1.0","devDependencies":{"@fastly/js-compute":"^0.2.0"}}`)
+	writeFile(filepath.Join("node_modules", "@fastly", "js-compute", "package.json"), `{"name":"@fastly/js-compute","version":"0.2.0"}`)
+	writeFile(filepath.Join("node_modules", ".bin", "js-compute-runtime"), fmt.Sprintf("#!/bin/sh\ncp %s \"$2\"\n", wasmBinary))
+	if err := os.Chmod(filepath.Join("node_modules", ".bin", "js-compute-runtime"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each step builds the package in the same directory, after changing it.
+	for _, step := range []struct {
+		name       string
+		args       []string
+		change     func()
+		wantOutput string
+	}{
+		{
+			name:       "first build",
+			args:       []string{"compute", "build"},
+			wantOutput: "Built javascript package test (pkg/test.tar.gz)",
+		},
+		{
+			name:       "unchanged",
+			args:       []string{"compute", "build"},
+			wantOutput: "Package test (pkg/test.tar.gz) is up to date",
+		},
+		{
+			name:       "no cache",
+			args:       []string{"compute", "build", "--no-cache"},
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "ignored file changed",
+			args:       []string{"compute", "build"},
+			change:     func() { writeFile(filepath.Join("src", "ignored.txt"), "ignored") },
+			wantOutput: "Package test (pkg/test.tar.gz) is up to date",
+		},
+		{
+			name:       "source changed",
+			args:       []string{"compute", "build"},
+			change:     func() { writeFile(filepath.Join("src", "index.js"), "addEventListener(\"fetch\", () => null)\n") },
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "file outside source directory changed",
+			args:       []string{"compute", "build"},
+			change:     func() { writeFile(filepath.Join("lib", "util.js"), "export const util = 1\n") },
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "flags changed",
+			args:       []string{"compute", "build", "--strip"},
+			wantOutput: "Stripped Wasm binary from 242 to 118 bytes",
+		},
+		{
+			name:       "stripped binary unchanged",
+			args:       []string{"compute", "build", "--strip"},
+			wantOutput: "Package test (pkg/test.tar.gz) is up to date",
+		},
+		{
+			name:       "package removed",
+			args:       []string{"compute", "build", "--strip"},
+			change:     func() { os.Remove(filepath.Join("pkg", "test.tar.gz")) },
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "binary changed",
+			args:       []string{"compute", "build", "--strip"},
+			change:     func() { writeFile(filepath.Join("bin", "main.wasm"), "changed") },
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "manifest changed",
+			args:       []string{"compute", "build", "--strip"},
+			change:     func() { writeFile(compute.ManifestFilename, manifest+"post_build = \"true\"\n") },
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "json events",
+			args:       []string{"compute", "build", "--strip", "--no-cache", "--json-events"},
+			wantOutput: `{"event":"done","time":`,
+		},
+		{
+			name:       "json events up to date",
+			args:       []string{"compute", "build", "--strip", "--json-events"},
+			wantOutput: `"artifacts":{"package":"pkg/test.tar.gz"}}`,
+		},
+		{
+			name: "pre_build added",
+			args: []string{"compute", "build"},
+			change: func() {
+				writeFile(compute.ManifestFilename, manifest+"pre_build = \"echo generated >> src/generated.js\"\n")
+			},
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name:       "pre_build output changed",
+			args:       []string{"compute", "build"},
+			wantOutput: "Built javascript package test",
+		},
+		{
+			name: "custom build script",
+			args: []string{"compute", "build"},
+			change: func() {
+				writeFile(compute.ManifestFilename, fmt.Sprintf("name = \"test\"\n\n[scripts]\nbuild = \"cp %s bin/main.wasm\"\n", wasmBinary))
+			},
+			wantOutput: "Built custom package test",
+		},
+		{
+			name:       "custom build script unchanged",
+			args:       []string{"compute", "build"},
+			wantOutput: "Built custom package test",
+		},
+	} {
+		if step.change != nil {
+			step.change()
+		}
+
+		var (
+			env                            = config.Environment{}
+			file                           = config.File{}
+			appConfigFile                  = "/dev/null"
+			clientFactory                  = mock.APIClient(mock.API{})
+			httpClient                     = http.DefaultClient
+			versioner     update.Versioner = nil
+			in            io.Reader        = nil
+			buf           bytes.Buffer
+			out           io.Writer = common.NewSyncWriter(&buf)
+		)
+		err := app.Run(step.args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if !strings.Contains(buf.String(), step.wantOutput) {
+			t.Fatalf("%s: want output containing %q, have %q", step.name, step.wantOutput, buf.String())
+		}
+	}
+}
+
 func TestDeploy(t *testing.T) {
 	for _, testcase := range []struct {
-		name             string
-		args             []string
-		manifest         string
-		api              mock.API
-		client           api.HTTPClient
-		wantError        string
-		wantOutput       []string
-		manifestIncludes string
+		name                 string
+		args                 []string
+		manifest             string
+		api                  mock.API
+		client               api.HTTPClient
+		stdin                string
+		wantError            string
+		wantRemediationError string
+		wantOutput           []string
+		manifestIncludes     string
 	}{
 		{
 			name:      "no fastly.toml manifest",
-			args:      []string{"compute", "deploy"},
+			args:      []string{"compute", "deploy", "-s", "123"},
 			wantError: "error reading package manifest",
 			wantOutput: []string{
 				"Reading package manifest...",
 			},
 		},
 		{
-			name:      "path with no service ID",
+			name:      "no service ID and no token",
 			args:      []string{"compute", "deploy", "-p", "pkg/package.tar.gz"},
 			manifest:  "name = \"package\"\n",
-			wantError: "error reading service: no service ID found. Please provide one via the --service-id flag or within your package manifest",
-			wantOutput: []string{
-				"Validating package...",
-			},
+			wantError: "no token provided",
 		},
 		{
-			name:      "empty service ID",
-			args:      []string{"compute", "deploy"},
+			name:      "no service ID with version",
+			args:      []string{"compute", "deploy", "-t", "123", "--version", "2"},
 			manifest:  "name = \"package\"\n",
-			wantError: "error reading service: no service ID found. Please provide one via the --service-id flag or within your package manifest",
-			wantOutput: []string{
-				"Reading package manifest...",
-				"Validating package...",
-			},
+			wantError: "--version can't be used when the package has no service ID",
 		},
 		{
-			name:      "latest version error",
-			args:      []string{"compute", "deploy"},
-			api:       mock.API{ListVersionsFn: listVersionsError},
+			name:      "unknown environment",
+			args:      []string{"compute", "deploy", "-t", "123", "--env", "production"},
 			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
-			wantError: "error listing service versions: fixture error",
-			wantOutput: []string{
-				"Reading package manifest...",
-				"Validating package...",
-				"Fetching latest version...",
-			},
+			wantError: "error reading package manifest: environment production not found",
 		},
 		{
-			name: "clone version error",
-			args: []string{"compute", "deploy"},
+			name:      "package too large",
+			args:      []string{"compute", "deploy", "-t", "123", "--max-package-size", "10"},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "exceeds the limit of 10 bytes",
+		},
+		{
+			name: "environment",
+			args: []string{"compute", "deploy", "-t", "123", "--env", "staging"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:   codeClient{http.StatusOK},
+			manifest: "name = \"package\"\nservice_id = \"999\"\n\n[env.staging]\nservice_id = \"123\"\n",
+			wantOutput: []string{
+				"Fetching latest version...",
+				"Uploading package...",
+				"Activating version...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "environment without service ID",
+			args: []string{"compute", "deploy", "-t", "123", "--env", "staging", "--domain", "example.com", "--backend", "127.0.0.1"},
+			api: mock.API{
+				CreateServiceFn:   createServiceOK,
+				CreateDomainFn:    createDomainOK,
+				CreateBackendFn:   createBackendOK,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:           codeClient{http.StatusOK},
+			manifest:         "name = \"package\"\nservice_id = \"999\"\n\n[env.staging]\n",
+			manifestIncludes: "[env.staging]\n    service_id = \"12345\"",
+			wantOutput: []string{
+				"Creating service...",
+				"Deployed package (service 12345, version 1)",
+			},
+		},
+		{
+			name: "no service ID create service error",
+			args: []string{"compute", "deploy", "-t", "123"},
+			api: mock.API{
+				CreateServiceFn: createServiceError,
+			},
+			manifest:  "name = \"package\"\n",
+			wantError: "error creating service: fixture error",
+			wantOutput: []string{
+				"The package manifest has no service ID, so a new service will be created for the package.",
+				"Domain: [",
+				"Backend (originless, hostname or IP address): [originless] ",
+				"Reading package manifest...",
+				"Validating package...",
+				"Creating service...",
+			},
+		},
+		{
+			name: "no service ID create backend error",
+			args: []string{"compute", "deploy", "-t", "123", "--domain", "example.com", "--backend", "127.0.0.1"},
+			api: mock.API{
+				CreateServiceFn: createServiceOK,
+				DeleteServiceFn: deleteServiceOK,
+				CreateDomainFn:  createDomainOK,
+				DeleteDomainFn:  deleteDomainOK,
+				CreateBackendFn: createBackendError,
+			},
+			manifest:  "name = \"package\"\n",
+			wantError: "error creating backend: fixture error",
+			wantOutput: []string{
+				"Creating service...",
+				"Creating domain...",
+				"Creating backend...",
+			},
+		},
+		{
+			name: "no service ID activate error",
+			args: []string{"compute", "deploy", "-t", "123", "--domain", "example.com", "--backend", "127.0.0.1"},
+			api: mock.API{
+				CreateServiceFn:   createServiceOK,
+				DeleteServiceFn:   deleteServiceOK,
+				CreateDomainFn:    createDomainOK,
+				DeleteDomainFn:    deleteDomainOK,
+				CreateBackendFn:   createBackendOK,
+				DeleteBackendFn:   deleteBackendOK,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionError,
+			},
+			client:    codeClient{http.StatusOK},
+			manifest:  "name = \"package\"\n",
+			wantError: "error activating version: fixture error",
+			wantOutput: []string{
+				"Creating service...",
+				"Creating domain...",
+				"Creating backend...",
+				"Uploading package...",
+				"Activating version...",
+			},
+		},
+		{
+			name: "no service ID verification failure",
+			args: []string{"compute", "deploy", "-t", "123", "--domain", "example.com", "--backend", "127.0.0.1", "--verify-url", "https://verify.example.com/", "--verify-duration", "0s", "--verify-delay", "0s"},
+			api: mock.API{
+				CreateServiceFn:     createServiceOK,
+				CreateDomainFn:      createDomainOK,
+				CreateBackendFn:     createBackendOK,
+				UpdateVersionFn:     updateVersionOk,
+				ActivateVersionFn:   activateVersionOk,
+				DeactivateVersionFn: deactivateVersionOk,
+				DeleteServiceFn:     deleteServiceOK,
+			},
+			client:               verifyClient{http.StatusServiceUnavailable},
+			manifest:             "name = \"package\"\n",
+			wantError:            "error verifying deployment of version 1",
+			wantRemediationError: "The new service was deleted.",
+			wantOutput: []string{
+				"Activating version...",
+				"Verifying deployment for 0s...",
+				"Verification failed, deleting new service...",
+			},
+		},
+		{
+			name: "no service ID verification failure with deactivate error",
+			args: []string{"compute", "deploy", "-t", "123", "--domain", "example.com", "--backend", "127.0.0.1", "--verify-url", "https://verify.example.com/", "--verify-duration", "0s", "--verify-delay", "0s"},
+			api: mock.API{
+				CreateServiceFn:     createServiceOK,
+				CreateDomainFn:      createDomainOK,
+				CreateBackendFn:     createBackendOK,
+				UpdateVersionFn:     updateVersionOk,
+				ActivateVersionFn:   activateVersionOk,
+				DeactivateVersionFn: deactivateVersionError,
+			},
+			client:               verifyClient{http.StatusServiceUnavailable},
+			manifest:             "name = \"package\"\n",
+			wantError:            "error verifying deployment of version 1",
+			wantRemediationError: "The new service 12345 couldn't be deleted, as deactivating version 1 failed: fixture error",
+		},
+		{
+			name: "no service ID verification failure with delete error",
+			args: []string{"compute", "deploy", "-t", "123", "--domain", "example.com", "--backend", "127.0.0.1", "--verify-url", "https://verify.example.com/", "--verify-duration", "0s", "--verify-delay", "0s"},
+			api: mock.API{
+				CreateServiceFn:     createServiceOK,
+				CreateDomainFn:      createDomainOK,
+				CreateBackendFn:     createBackendOK,
+				UpdateVersionFn:     updateVersionOk,
+				ActivateVersionFn:   activateVersionOk,
+				DeactivateVersionFn: deactivateVersionOk,
+				DeleteServiceFn:     deleteServiceError,
+			},
+			client:               verifyClient{http.StatusServiceUnavailable},
+			manifest:             "name = \"package\"\n",
+			wantError:            "error verifying deployment of version 1",
+			wantRemediationError: "The new service 12345 couldn't be deleted: fixture error",
+		},
+		{
+			name: "no service ID success",
+			args: []string{"compute", "deploy", "-t", "123"},
+			api: mock.API{
+				CreateServiceFn:   createServiceOK,
+				CreateDomainFn:    createDomainOK,
+				CreateBackendFn:   createBackendOK,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:           codeClient{http.StatusOK},
+			manifest:         "name = \"package\"\n",
+			stdin:            "example.com\norigin.example.com\n",
+			manifestIncludes: `service_id = "12345"`,
+			wantOutput: []string{
+				"Domain: [",
+				"Backend (originless, hostname or IP address): [originless] ",
+				"Reading package manifest...",
+				"Validating package...",
+				"Creating service...",
+				"Creating domain...",
+				"Creating backend...",
+				"Uploading package...",
+				"Activating version...",
+				"Updating package manifest...",
+				"Manage this service at:",
+				"https://manage.fastly.com/configure/services/12345",
+				"Deployed package (service 12345, version 1)",
+			},
+		},
+		{
+			name:      "latest version error",
+			args:      []string{"compute", "deploy"},
+			api:       mock.API{ListVersionsFn: listVersionsError},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "error listing service versions: fixture error",
+			wantOutput: []string{
+				"Reading package manifest...",
+				"Validating package...",
+				"Fetching latest version...",
+			},
+		},
+		{
+			name: "clone version error",
+			args: []string{"compute", "deploy"},
 			api: mock.API{
 				ListVersionsFn: listVersionsActiveOk,
 				CloneVersionFn: cloneVersionError,
@@ -495,7 +1184,7 @@ func TestDeploy(t *testing.T) {
 		},
 		{
 			name: "package API error",
-			args: []string{"compute", "deploy", "-t", "123"},
+			args: []string{"compute", "deploy", "-t", "123", "--upload-retries", "0"},
 			api: mock.API{
 				ListVersionsFn: listVersionsActiveOk,
 				CloneVersionFn: cloneVersionOk,
@@ -513,7 +1202,7 @@ func TestDeploy(t *testing.T) {
 		},
 		{
 			name: "package API error",
-			args: []string{"compute", "deploy", "-t", "123"},
+			args: []string{"compute", "deploy", "-t", "123", "--upload-retries", "0"},
 			api: mock.API{
 				ListVersionsFn: listVersionsActiveOk,
 				CloneVersionFn: cloneVersionOk,
@@ -531,7 +1220,7 @@ func TestDeploy(t *testing.T) {
 		},
 		{
 			name: "package API server error",
-			args: []string{"compute", "deploy", "-t", "123"},
+			args: []string{"compute", "deploy", "-t", "123", "--upload-retries", "0"},
 			api: mock.API{
 				ListVersionsFn: listVersionsActiveOk,
 				CloneVersionFn: cloneVersionOk,
@@ -553,6 +1242,7 @@ func TestDeploy(t *testing.T) {
 			api: mock.API{
 				ListVersionsFn:    listVersionsActiveOk,
 				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
 				ActivateVersionFn: activateVersionError,
 			},
 			client:    codeClient{http.StatusOK},
@@ -573,6 +1263,7 @@ func TestDeploy(t *testing.T) {
 			api: mock.API{
 				ListVersionsFn:    listVersionsActiveOk,
 				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
 				ActivateVersionFn: activateVersionOk,
 				ListDomainsFn:     listDomainsError,
 			},
@@ -596,6 +1287,7 @@ func TestDeploy(t *testing.T) {
 			api: mock.API{
 				ListVersionsFn:    listVersionsActiveOk,
 				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
 				ActivateVersionFn: activateVersionOk,
 				ListDomainsFn:     listDomainsOk,
 			},
@@ -615,12 +1307,168 @@ func TestDeploy(t *testing.T) {
 				"Deployed package (service 123, version 2)",
 			},
 		},
+		{
+			name: "json events",
+			args: []string{"compute", "deploy", "-t", "123", "--json-events"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:   codeClient{http.StatusOK},
+			manifest: "name = \"package\"\nservice_id = \"123\"\n",
+			wantOutput: []string{
+				`{"event":"step_started","time":`,
+				`"step":"Uploading package..."`,
+				`{"event":"step_finished","time":`,
+				`"output":"Setting version in manifest to 2...\n"}`,
+				`{"event":"done","time":`,
+				`"artifacts":{"package":"pkg/package.tar.gz","service_id":"123","version":2}}`,
+				`{"event":"message","time":`,
+				`"message":"SUCCESS: Deployed package (service 123, version 2)"}`,
+			},
+		},
+		{
+			name: "json events without service ID",
+			args: []string{"compute", "deploy", "-t", "123", "--json-events"},
+			api: mock.API{
+				CreateServiceFn:   createServiceOK,
+				CreateDomainFn:    createDomainOK,
+				CreateBackendFn:   createBackendOK,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:           codeClient{http.StatusOK},
+			manifest:         "name = \"package\"\n",
+			manifestIncludes: "service_id = \"12345\"",
+			wantOutput: []string{
+				`"step":"Creating service..."`,
+				`"artifacts":{"package":"pkg/package.tar.gz","service_id":"12345","version":1}}`,
+			},
+		},
+		{
+			name: "json events activate error",
+			args: []string{"compute", "deploy", "-t", "123", "--json-events"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionError,
+			},
+			client:    codeClient{http.StatusOK},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "error activating version: fixture error",
+			wantOutput: []string{
+				`{"event":"step_failed","time":`,
+				`"step":"Activating version..."`,
+				`{"event":"failed","time":`,
+			},
+		},
+		{
+			name: "verification success",
+			args: []string{"compute", "deploy", "-t", "123", "--verify-url", "https://verify.example.com/", "--verify-duration", "0s", "--verify-delay", "0s"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:           verifyClient{http.StatusOK},
+			manifest:         "name = \"package\"\nservice_id = \"123\"\n",
+			manifestIncludes: "version = 2",
+			wantOutput: []string{
+				"Uploading package...",
+				"Checking for concurrent deploys...",
+				"Activating version...",
+				"Verifying deployment for 0s...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "verification failure",
+			args: []string{"compute", "deploy", "-t", "123", "--verify-url", "https://verify.example.com/", "--verify-duration", "0s", "--verify-delay", "0s"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+			},
+			client:    verifyClient{http.StatusServiceUnavailable},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "error verifying deployment of version 2",
+			wantOutput: []string{
+				"Checking for concurrent deploys...",
+				"Activating version...",
+				"Verifying deployment for 0s...",
+				"Verification failed, rolling back to version 1...",
+			},
+		},
+		{
+			name: "unchanged package",
+			args: []string{"compute", "deploy", "-t", "123"},
+			api: mock.API{
+				ListVersionsFn: listVersionsActiveOk,
+			},
+			client:   packageClient{"pkg/package.tar.gz"},
+			manifest: "name = \"package\"\nservice_id = \"123\"\n",
+			wantOutput: []string{
+				"Reading package manifest...",
+				"Validating package...",
+				"Fetching latest version...",
+				"Comparing package...",
+				"Package unchanged, nothing to deploy (service 123, version 1)",
+			},
+		},
+		{
+			name: "unchanged package with force",
+			args: []string{"compute", "deploy", "-t", "123", "--force"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:   packageClient{"pkg/package.tar.gz"},
+			manifest: "name = \"package\"\nservice_id = \"123\"\n",
+			wantOutput: []string{
+				"Reading package manifest...",
+				"Validating package...",
+				"Fetching latest version...",
+				"Cloning latest version...",
+				"Uploading package...",
+				"Activating version...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "unchanged package with version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version", "2"},
+			api: mock.API{
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: packageClient{"pkg/package.tar.gz"},
+			wantOutput: []string{
+				"Validating package...",
+				"Comparing package...",
+				"Skipping upload of unchanged package...",
+				"Activating version...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
 		{
 			name: "success with path",
 			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
 			api: mock.API{
 				ListVersionsFn:    listVersionsActiveOk,
 				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
 				ActivateVersionFn: activateVersionOk,
 				ListDomainsFn:     listDomainsOk,
 			},
@@ -635,216 +1483,895 @@ func TestDeploy(t *testing.T) {
 			},
 		},
 		{
-			name: "success with inactive version",
+			name: "concurrent deploy",
 			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
 			api: mock.API{
-				ListVersionsFn:    listVersionsInactiveOk,
+				ListVersionsFn:    listVersionsConcurrentDeploy(3, "2000-01-01T01:00:00Z"),
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionError,
+			},
+			client:               codeClient{http.StatusOK},
+			wantError:            "error activating version 2: the active version of service 123 changed from 1 to 3 during the deploy",
+			wantRemediationError: "--force",
+			wantOutput: []string{
+				"Cloning latest version...",
+				"Uploading package...",
+				"Checking for concurrent deploys...",
+			},
+		},
+		{
+			name: "concurrent change to base version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsConcurrentDeploy(1, "2000-01-03T01:00:00Z"),
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionError,
+			},
+			client:               codeClient{http.StatusOK},
+			wantError:            "error activating version 2: version 1 of service 123, which the deploy is based on, changed during the deploy",
+			wantRemediationError: "fastly compute deploy",
+		},
+		{
+			name: "concurrent deploy with force",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--force"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsConcurrentDeploy(3, "2000-01-01T01:00:00Z"),
 				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
 				ActivateVersionFn: activateVersionOk,
 				ListDomainsFn:     listDomainsOk,
 			},
 			client: codeClient{http.StatusOK},
 			wantOutput: []string{
-				"Validating package...",
-				"Fetching latest version...",
 				"Uploading package...",
 				"Activating version...",
 				"Deployed package (service 123, version 2)",
 			},
 		},
 		{
-			name: "success with version",
-			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version", "2"},
-			api: mock.API{
-				ActivateVersionFn: activateVersionOk,
-				ListDomainsFn:     listDomainsOk,
-			},
-			client:           codeClient{http.StatusOK},
-			manifestIncludes: "version = 2",
-			wantOutput: []string{
-				"Validating package...",
-				"Uploading package...",
-				"Activating version...",
-				"Updating package manifest...",
-				"Deployed package (service 123, version 2)",
+			name: "comment",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--comment", "Release 42"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionWithComment("Release 42"),
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Setting version comment...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "default comment",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionWithComment("with Fastly CLI "),
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "comment template",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version-comment-template", "Built at {{.Timestamp.Year}}"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsActiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionWithComment(fmt.Sprintf("Built at %d", time.Now().Year())),
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name:      "invalid comment template",
+			args:      []string{"compute", "deploy", "-t", "123", "--version-comment-template", "{{.Commit"},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "error parsing version comment template",
+		},
+		{
+			name:      "comment and comment template",
+			args:      []string{"compute", "deploy", "-t", "123", "--comment", "Release 42", "--version-comment-template", "{{.Commit}}"},
+			manifest:  "name = \"package\"\nservice_id = \"123\"\n",
+			wantError: "--comment and --version-comment-template can't be used together",
+		},
+		{
+			name: "comment error",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
+			api: mock.API{
+				ListVersionsFn:  listVersionsActiveOk,
+				CloneVersionFn:  cloneVersionOk,
+				UpdateVersionFn: updateVersionError,
+			},
+			client:    codeClient{http.StatusOK},
+			wantError: "error setting version comment: fixture error",
+		},
+		{
+			name: "comment with locked version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version", "2", "--comment", "Release 42"},
+			api: mock.API{
+				ListVersionsFn: listVersionsActiveOk,
+			},
+			client:               codeClient{http.StatusOK},
+			wantError:            "version 2 is locked or active, so its comment can't be set",
+			wantRemediationError: "omitting --version",
+			wantOutput: []string{
+				"Fetching version...",
+			},
+		},
+		{
+			name: "comment with inactive version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version", "2", "--comment", "Release 42"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsInactiveOk,
+				UpdateVersionFn:   updateVersionWithComment("Release 42"),
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Fetching version...",
+				"Uploading package...",
+				"Setting version comment...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "comment template with latest inactive version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version-comment-template", "Built at {{.Timestamp.Year}}"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsInactiveOk,
+				UpdateVersionFn:   updateVersionWithComment(fmt.Sprintf("Built at %d", time.Now().Year())),
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Fetching latest version...",
+				"Uploading package...",
+				"Setting version comment...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "success with inactive version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123"},
+			api: mock.API{
+				ListVersionsFn:    listVersionsInactiveOk,
+				CloneVersionFn:    cloneVersionOk,
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Validating package...",
+				"Fetching latest version...",
+				"Uploading package...",
+				"Activating version...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+		{
+			name: "success with version",
+			args: []string{"compute", "deploy", "-t", "123", "-p", "pkg/package.tar.gz", "-s", "123", "--version", "2"},
+			api: mock.API{
+				UpdateVersionFn:   updateVersionOk,
+				ActivateVersionFn: activateVersionOk,
+				ListDomainsFn:     listDomainsOk,
+			},
+			client:           codeClient{http.StatusOK},
+			manifestIncludes: "version = 2",
+			wantOutput: []string{
+				"Validating package...",
+				"Uploading package...",
+				"Activating version...",
+				"Updating package manifest...",
+				"Deployed package (service 123, version 2)",
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			// We're going to chdir to a deploy environment,
+			// so save the PWD to return to, afterwards.
+			pwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create our deploy environment in a temp dir.
+			// Defer a call to clean it up.
+			rootdir := makeDeployEnvironment(t, testcase.manifest)
+			defer os.RemoveAll(rootdir)
+
+			// Before running the test, chdir into the build environment.
+			// When we're done, chdir back to our original location.
+			// This is so we can reliably copy the testdata/ fixtures.
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			var (
+				args                           = testcase.args
+				env                            = config.Environment{}
+				file                           = config.File{}
+				appConfigFile                  = "/dev/null"
+				clientFactory                  = mock.APIClient(testcase.api)
+				httpClient                     = testcase.client
+				versioner     update.Versioner = nil
+				in            io.Reader        = strings.NewReader(testcase.stdin)
+				buf           bytes.Buffer
+				out           io.Writer = common.NewSyncWriter(&buf)
+			)
+			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
+			for _, s := range testcase.wantOutput {
+				testutil.AssertStringContains(t, buf.String(), s)
+			}
+			if testcase.manifestIncludes != "" {
+				content, err := ioutil.ReadFile(filepath.Join(rootdir, compute.ManifestFilename))
+				if err != nil {
+					t.Fatal(err)
+				}
+				testutil.AssertStringContains(t, string(content), testcase.manifestIncludes)
+			}
+
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	for _, testcase := range []struct {
+		name       string
+		args       []string
+		client     api.HTTPClient
+		wantError  string
+		wantOutput []string
+	}{
+		{
+			name:      "no token",
+			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz"},
+			wantError: "no token provided",
+			wantOutput: []string{
+				"Initializing...",
+			},
+		},
+		{
+			name:       "invalid package path",
+			args:       []string{"compute", "update", "-s", "123", "--version", "1", "-p", "unkown.tar.gz", "-t", "123"},
+			wantError:  "error reading package: ",
+			wantOutput: []string{},
+		},
+		{
+			name:      "package API error",
+			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123", "--upload-retries", "0"},
+			client:    errorClient{err: errors.New("some network failure")},
+			wantError: "error executing API request: some network failure",
+			wantOutput: []string{
+				"Initializing...",
+				"Uploading package...",
+			},
+		},
+		{
+			name:      "package API server error",
+			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123", "--upload-retries", "0"},
+			client:    codeClient{http.StatusInternalServerError},
+			wantError: "error from API: 500 Internal Server Error",
+			wantOutput: []string{
+				"Initializing...",
+				"Uploading package...",
+			},
+		},
+		{
+			name:   "success",
+			args:   []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123"},
+			client: codeClient{http.StatusOK},
+			wantOutput: []string{
+				"Initializing...",
+				"Uploading package...",
+				"Updated package (service 123, version 1)",
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			// We're going to chdir to a deploy environment,
+			// so save the PWD to return to, afterwards.
+			pwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create our deploy environment in a temp dir.
+			// Defer a call to clean it up.
+			rootdir := makeDeployEnvironment(t, "")
+			defer os.RemoveAll(rootdir)
+
+			// Before running the test, chdir into the build environment.
+			// When we're done, chdir back to our original location.
+			// This is so we can reliably copy the testdata/ fixtures.
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			var (
+				args                           = testcase.args
+				env                            = config.Environment{}
+				file                           = config.File{}
+				appConfigFile                  = "/dev/null"
+				clientFactory                  = mock.APIClient(mock.API{})
+				httpClient                     = testcase.client
+				versioner     update.Versioner = nil
+				in            io.Reader        = nil
+				buf           bytes.Buffer
+				out           io.Writer = common.NewSyncWriter(&buf)
+			)
+			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			for _, s := range testcase.wantOutput {
+				testutil.AssertStringContains(t, buf.String(), s)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "pack", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name       string
+		args       []string
+		manifest   string
+		wantError  string
+		wantOutput string
+	}{
+		{
+			name:       "success",
+			args:       []string{"compute", "validate", "-p", "pkg/package.tar.gz"},
+			wantError:  "",
+			wantOutput: "Validated package",
+		},
+		{
+			name:       "unknown manifest key",
+			args:       []string{"compute", "validate", "-p", "pkg/package.tar.gz"},
+			manifest:   "name = \"package\"\nfuture_key = true\n",
+			wantOutput: "fastly.toml: unknown key \"future_key\"",
+		},
+		{
+			name:      "unknown manifest key strict",
+			args:      []string{"compute", "validate", "-p", "pkg/package.tar.gz", "--strict"},
+			manifest:  "name = \"package\"\nfuture_key = true\n",
+			wantError: "fastly.toml: unknown key \"future_key\"",
+		},
+		{
+			name:       "unknown manifest key json",
+			args:       []string{"compute", "validate", "-p", "pkg/package.tar.gz", "--format", "json"},
+			manifest:   "name = \"package\"\nfuture_key = true\n",
+			wantOutput: `"valid":true,"problems":[{"file":"fastly.toml","message":"unknown key \"future_key\"","warning":true}]`,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			// We're going to chdir to a deploy environment,
+			// so save the PWD to return to, afterwards.
+			pwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create our deploy environment in a temp dir.
+			// Defer a call to clean it up.
+			rootdir := makeDeployEnvironment(t, "")
+			defer os.RemoveAll(rootdir)
+
+			// Before running the test, chdir into the build environment.
+			// When we're done, chdir back to our original location.
+			// This is so we can reliably copy the testdata/ fixtures.
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			var (
+				args                           = testcase.args
+				env                            = config.Environment{}
+				file                           = config.File{}
+				appConfigFile                  = "/dev/null"
+				clientFactory                  = mock.APIClient(mock.API{})
+				httpClient                     = http.DefaultClient
+				versioner     update.Versioner = nil
+				in            io.Reader        = nil
+				buf           bytes.Buffer
+				out           io.Writer = common.NewSyncWriter(&buf)
+			)
+			// A package with the manifest is packed in place of the fixture.
+			if testcase.manifest != "" {
+				if err := ioutil.WriteFile(compute.ManifestFilename, []byte(testcase.manifest), 0600); err != nil {
+					t.Fatal(err)
+				}
+				packArgs := []string{"compute", "pack", "--wasm-binary", wasmBinary}
+				if err := app.Run(packArgs, env, file, appConfigFile, clientFactory, httpClient, versioner, in, ioutil.Discard); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertStringContains(t, buf.String(), testcase.wantOutput)
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "pack", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name                 string
+		args                 []string
+		manifest             string
+		wantError            string
+		wantRemediationError string
+		wantOutput           string
+		wantPackage          string
+		manifestIncludes     string
+	}{
+		{
+			name:      "no name",
+			args:      []string{"compute", "pack", "--wasm-binary", wasmBinary},
+			wantError: "name cannot be empty, please provide a name",
+		},
+		{
+			name:      "missing manifest",
+			args:      []string{"compute", "pack", "--wasm-binary", wasmBinary, "--manifest", "missing.toml"},
+			wantError: "error reading package manifest: missing.toml not found",
+		},
+		{
+			name:      "missing Wasm binary",
+			args:      []string{"compute", "pack", "--wasm-binary", "missing.wasm", "--name", "package"},
+			wantError: "error copying Wasm binary",
+		},
+		{
+			name:      "invalid Wasm binary",
+			args:      []string{"compute", "pack", "--wasm-binary", compute.ManifestFilename},
+			manifest:  "name = \"package\"\n",
+			wantError: "bin/main.wasm: malformed Wasm module",
+		},
+		{
+			name:             "without manifest",
+			args:             []string{"compute", "pack", "--wasm-binary", wasmBinary, "--name", "my package"},
+			wantOutput:       "Packed package my package (pkg/my-package.tar.gz)",
+			wantPackage:      "pkg/my-package.tar.gz",
+			manifestIncludes: `name = "my package"`,
+		},
+		{
+			name:        "unknown manifest key",
+			args:        []string{"compute", "pack", "--wasm-binary", wasmBinary},
+			manifest:    "name = \"package\"\nfuture_key = true\n",
+			wantOutput:  "WARNING: fastly.toml: unknown key \"future_key\"",
+			wantPackage: "pkg/package.tar.gz",
+		},
+		{
+			name:                 "name mismatch",
+			args:                 []string{"compute", "pack", "--wasm-binary", wasmBinary, "--name", "other"},
+			manifest:             "name = \"package\"\n",
+			wantError:            "--name other doesn't match the name package in the package manifest",
+			wantRemediationError: "Remove the --name flag",
+		},
+		{
+			name:             "matching name",
+			args:             []string{"compute", "pack", "--wasm-binary", wasmBinary, "--name", "package"},
+			manifest:         "name = \"package\"\n",
+			wantOutput:       "Packed package package (pkg/package.tar.gz)",
+			wantPackage:      "pkg/package.tar.gz",
+			manifestIncludes: "name = \"package\"\n",
+		},
+		{
+			name:             "with manifest",
+			args:             []string{"compute", "pack", "--wasm-binary", wasmBinary},
+			manifest:         "# Built with Bazel\nname = \"package\"\nservice_id = \"123\"\n",
+			wantOutput:       "Packed package package (pkg/package.tar.gz)",
+			wantPackage:      "pkg/package.tar.gz",
+			manifestIncludes: "# Built with Bazel\nname = \"package\"\nservice_id = \"123\"\n",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			pwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rootdir, err := ioutil.TempDir("", "fastly-pack")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(rootdir)
+
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			if testcase.manifest != "" {
+				if err := ioutil.WriteFile(compute.ManifestFilename, []byte(testcase.manifest), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var (
+				args                           = testcase.args
+				env                            = config.Environment{}
+				file                           = config.File{}
+				appConfigFile                  = "/dev/null"
+				clientFactory                  = mock.APIClient(mock.API{})
+				httpClient                     = http.DefaultClient
+				versioner     update.Versioner = nil
+				in            io.Reader        = nil
+				buf           bytes.Buffer
+				out           io.Writer = common.NewSyncWriter(&buf)
+			)
+			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
+			testutil.AssertStringContains(t, buf.String(), testcase.wantOutput)
+			if testcase.wantPackage == "" {
+				return
+			}
+
+			// The package must pass validation, as deploy validates it.
+			buf.Reset()
+			err = app.Run([]string{"compute", "validate", "-p", testcase.wantPackage}, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertNoError(t, err)
+
+			var manifest string
+			err = archiver.Walk(testcase.wantPackage, func(f archiver.File) error {
+				if f.Name() == compute.ManifestFilename {
+					data, err := ioutil.ReadAll(f)
+					manifest = string(data)
+					return err
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertStringContains(t, manifest, testcase.manifestIncludes)
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "inspect", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name        string
+		args        []string
+		manifest    string
+		wantError   string
+		wantOutputs []string
+	}{
+		{
+			name:      "no manifest",
+			args:      []string{"compute", "inspect"},
+			wantError: "error reading package manifest",
+		},
+		{
+			name:      "missing package",
+			args:      []string{"compute", "inspect", "-p", "missing.tar.gz"},
+			wantError: "error reading package",
+		},
+		{
+			name:      "negative top",
+			args:      []string{"compute", "inspect", "-p", "pkg/package.tar.gz", "--top=-1"},
+			wantError: "invalid --top value -1",
+		},
+		{
+			name:      "zero top",
+			args:      []string{"compute", "inspect", "-p", "pkg/package.tar.gz", "--top", "0"},
+			wantError: "invalid --top value 0",
+		},
+		{
+			name:     "package from manifest",
+			args:     []string{"compute", "inspect"},
+			manifest: "name = \"package\"\n",
+			wantOutputs: []string{
+				"Package: pkg/package.tar.gz",
+				"Wasm binary: bin/main.wasm (242 bytes, of which 92 bytes are debug information)",
+				"code                   27    11.2%",
+				"custom    .debug_info  54    22.3%",
+				"2      _start    20    8.3%",
+				"1      helper    2     0.8%",
+				"fastly_http_req  send",
+			},
+		},
+		{
+			name:     "json",
+			args:     []string{"compute", "inspect", "-p", "pkg/package.tar.gz", "--format", "json", "--top", "1"},
+			manifest: "name = \"package\"\n",
+			wantOutputs: []string{
+				`"wasm_size":242,"debug_size":92`,
+				`{"id":"custom","name":".debug_line","size":38,"debug":true}`,
+				`"functions":[{"index":2,"name":"_start","size":20}]`,
+				`"imports":[{"module":"fastly_http_req","name":"send"}]`,
 			},
 		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
-			// We're going to chdir to a deploy environment,
-			// so save the PWD to return to, afterwards.
 			pwd, err := os.Getwd()
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			// Create our deploy environment in a temp dir.
-			// Defer a call to clean it up.
-			rootdir := makeDeployEnvironment(t, testcase.manifest)
+			rootdir, err := ioutil.TempDir("", "fastly-inspect")
+			if err != nil {
+				t.Fatal(err)
+			}
 			defer os.RemoveAll(rootdir)
 
-			// Before running the test, chdir into the build environment.
-			// When we're done, chdir back to our original location.
-			// This is so we can reliably copy the testdata/ fixtures.
 			if err := os.Chdir(rootdir); err != nil {
 				t.Fatal(err)
 			}
 			defer os.Chdir(pwd)
 
 			var (
-				args                           = testcase.args
 				env                            = config.Environment{}
 				file                           = config.File{}
 				appConfigFile                  = "/dev/null"
-				clientFactory                  = mock.APIClient(testcase.api)
-				httpClient                     = testcase.client
+				clientFactory                  = mock.APIClient(mock.API{})
+				httpClient                     = http.DefaultClient
 				versioner     update.Versioner = nil
 				in            io.Reader        = nil
 				buf           bytes.Buffer
 				out           io.Writer = common.NewSyncWriter(&buf)
 			)
-			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
-			testutil.AssertErrorContains(t, err, testcase.wantError)
-			for _, s := range testcase.wantOutput {
-				testutil.AssertStringContains(t, buf.String(), s)
-			}
-			if testcase.manifestIncludes != "" {
-				content, err := ioutil.ReadFile(filepath.Join(rootdir, compute.ManifestFilename))
-				if err != nil {
+
+			if testcase.manifest != "" {
+				if err := ioutil.WriteFile(compute.ManifestFilename, []byte(testcase.manifest), 0600); err != nil {
 					t.Fatal(err)
 				}
-				testutil.AssertStringContains(t, string(content), testcase.manifestIncludes)
+				err = app.Run([]string{"compute", "pack", "--wasm-binary", wasmBinary}, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+				testutil.AssertNoError(t, err)
+				buf.Reset()
 			}
 
+			err = app.Run(testcase.args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			for _, s := range testcase.wantOutputs {
+				testutil.AssertStringContains(t, buf.String(), s)
+			}
 		})
 	}
 }
 
-func TestUpdate(t *testing.T) {
+func TestSymbolicate(t *testing.T) {
+	wasmBinary, err := filepath.Abs(filepath.Join("testdata", "symbolicate", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backtrace := strings.Join([]string{
+		"Error: failed to run main module",
+		"wasm backtrace:",
+		"    0:   0x65 - <unknown>!<wasm function 1>",
+		"    1:   0x80 - <unknown>!<wasm function 2>",
+		"",
+	}, "\n")
+
 	for _, testcase := range []struct {
-		name       string
-		args       []string
-		client     api.HTTPClient
-		wantError  string
-		wantOutput []string
+		name                 string
+		args                 []string
+		stdin                string
+		backtrace            string
+		pack                 bool
+		strip                bool
+		wantError            string
+		wantRemediationError string
+		wantOutput           string
 	}{
 		{
-			name:      "no token",
-			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz"},
-			wantError: "no token provided",
-			wantOutput: []string{
-				"Initializing...",
-			},
+			name:      "no wasm binary",
+			args:      []string{"compute", "symbolicate"},
+			stdin:     backtrace,
+			wantError: "error reading Wasm binary",
 		},
 		{
-			name:       "invalid package path",
-			args:       []string{"compute", "update", "-s", "123", "--version", "1", "-p", "unkown.tar.gz", "-t", "123"},
-			wantError:  "error reading package: ",
-			wantOutput: []string{},
+			name:  "stdin",
+			args:  []string{"compute", "symbolicate", "--wasm-binary", wasmBinary},
+			stdin: backtrace,
+			wantOutput: strings.Join([]string{
+				"Error: failed to run main module",
+				"wasm backtrace:",
+				"    0:   0x65 - <unknown>!<wasm function 1>",
+				"        at app::handle (/app/src/main.rs:5:9)",
+				"    1:   0x80 - <unknown>!<wasm function 2>",
+				"        at _start (/app/src/main.rs:12:5)",
+			}, "\n"),
 		},
 		{
-			name:      "package API error",
-			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123"},
-			client:    errorClient{err: errors.New("some network failure")},
-			wantError: "error executing API request: some network failure",
-			wantOutput: []string{
-				"Initializing...",
-				"Uploading package...",
-			},
+			name:       "file",
+			args:       []string{"compute", "symbolicate", "--wasm-binary", wasmBinary, "--file", "backtrace.txt"},
+			backtrace:  "at wasm://wasm/1e2b3c4d:wasm-function[2]:0x87\n",
+			wantOutput: "at wasm://wasm/1e2b3c4d:wasm-function[2]:0x87\n    at _start (/app/src/main.rs:13:2)\n",
 		},
 		{
-			name:      "package API server error",
-			args:      []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123"},
-			client:    codeClient{http.StatusInternalServerError},
-			wantError: "error from API: 500 Internal Server Error",
-			wantOutput: []string{
-				"Initializing...",
-				"Uploading package...",
-			},
+			name:      "missing file",
+			args:      []string{"compute", "symbolicate", "--wasm-binary", wasmBinary, "--file", "backtrace.txt"},
+			wantError: "error reading backtrace",
 		},
 		{
-			name:   "success",
-			args:   []string{"compute", "update", "-s", "123", "--version", "1", "-p", "pkg/package.tar.gz", "-t", "123"},
-			client: codeClient{http.StatusOK},
-			wantOutput: []string{
-				"Initializing...",
-				"Uploading package...",
-				"Updated package (service 123, version 1)",
-			},
+			name:       "package",
+			args:       []string{"compute", "symbolicate", "--package", "pkg/package.tar.gz"},
+			stdin:      backtrace,
+			pack:       true,
+			wantOutput: "        at app::handle (/app/src/main.rs:5:9)",
+		},
+		{
+			name:                 "stripped wasm binary",
+			args:                 []string{"compute", "symbolicate", "--wasm-binary", "stripped.wasm"},
+			stdin:                backtrace,
+			strip:                true,
+			wantError:            "no DWARF debug information",
+			wantRemediationError: "fastly compute build --strip --keep-debug",
+		},
+		{
+			name:       "no frames",
+			args:       []string{"compute", "symbolicate", "--wasm-binary", wasmBinary},
+			stdin:      "    0:   0x7 - <unknown>!<wasm function 0>\n",
+			wantOutput: "None of the backtrace frames could be located",
 		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
-			// We're going to chdir to a deploy environment,
-			// so save the PWD to return to, afterwards.
 			pwd, err := os.Getwd()
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			// Create our deploy environment in a temp dir.
-			// Defer a call to clean it up.
-			rootdir := makeDeployEnvironment(t, "")
+			rootdir, err := ioutil.TempDir("", "fastly-symbolicate")
+			if err != nil {
+				t.Fatal(err)
+			}
 			defer os.RemoveAll(rootdir)
 
-			// Before running the test, chdir into the build environment.
-			// When we're done, chdir back to our original location.
-			// This is so we can reliably copy the testdata/ fixtures.
 			if err := os.Chdir(rootdir); err != nil {
 				t.Fatal(err)
 			}
 			defer os.Chdir(pwd)
 
 			var (
-				args                           = testcase.args
 				env                            = config.Environment{}
 				file                           = config.File{}
 				appConfigFile                  = "/dev/null"
 				clientFactory                  = mock.APIClient(mock.API{})
-				httpClient                     = testcase.client
+				httpClient                     = http.DefaultClient
 				versioner     update.Versioner = nil
-				in            io.Reader        = nil
+				in            io.Reader        = strings.NewReader(testcase.stdin)
 				buf           bytes.Buffer
 				out           io.Writer = common.NewSyncWriter(&buf)
 			)
-			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
-			testutil.AssertErrorContains(t, err, testcase.wantError)
-			for _, s := range testcase.wantOutput {
-				testutil.AssertStringContains(t, buf.String(), s)
+
+			if testcase.backtrace != "" {
+				if err := ioutil.WriteFile("backtrace.txt", []byte(testcase.backtrace), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if testcase.pack {
+				err = app.Run([]string{"compute", "pack", "--wasm-binary", wasmBinary, "--name", "package"}, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+				testutil.AssertNoError(t, err)
+				buf.Reset()
+			}
+			if testcase.strip {
+				b, err := ioutil.ReadFile(wasmBinary)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stripped, err := wasm.Strip(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile("stripped.wasm", stripped, 0600); err != nil {
+					t.Fatal(err)
+				}
 			}
+
+			err = app.Run(testcase.args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
+			testutil.AssertStringContains(t, buf.String(), testcase.wantOutput)
 		})
 	}
 }
 
-func TestValidate(t *testing.T) {
+func TestTest(t *testing.T) {
 	for _, testcase := range []struct {
-		name       string
-		args       []string
-		wantError  string
-		wantOutput string
+		name                 string
+		args                 []string
+		fastlyManifest       string
+		wantError            string
+		wantRemediationError string
 	}{
 		{
-			name:       "success",
-			args:       []string{"compute", "validate", "-p", "pkg/package.tar.gz"},
-			wantError:  "",
-			wantOutput: "Validated package",
+			name:      "no fastly.toml manifest",
+			args:      []string{"compute", "test"},
+			wantError: "error reading package manifest: open fastly.toml:", // actual message differs on Windows
+		},
+		{
+			name:           "unknown language",
+			args:           []string{"compute", "test"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"cobol\"\n",
+			wantError:      "unsupported language cobol",
+		},
+		{
+			name:                 "assemblyscript",
+			args:                 []string{"compute", "test", "--force"},
+			fastlyManifest:       "name = \"test\"\nlanguage = \"assemblyscript\"\n",
+			wantError:            "tests can't be built for packages built with assemblyscript",
+			wantRemediationError: "rust and go toolchains",
+		},
+		{
+			name:                 "javascript",
+			args:                 []string{"compute", "test", "--force"},
+			fastlyManifest:       "name = \"test\"\nlanguage = \"javascript\"\n",
+			wantError:            "tests can't be built for packages built with javascript",
+			wantRemediationError: "rust and go toolchains",
+		},
+		{
+			name:           "custom build script",
+			args:           []string{"compute", "test", "--force"},
+			fastlyManifest: "name = \"test\"\n\n[scripts]\nbuild = \"true\"\n",
+			wantError:      "tests can't be built for packages built with a custom build script",
+		},
+		{
+			name:           "package without rust",
+			args:           []string{"compute", "test", "--force", "--package", "edge"},
+			fastlyManifest: "name = \"test\"\nlanguage = \"go\"\n",
+			wantError:      "--package can only be used when testing with the Rust toolchain",
+		},
+		{
+			name:      "unknown format",
+			args:      []string{"compute", "test", "--format", "tap"},
+			wantError: "enum value must be one of text,junit, got 'tap'",
 		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
-			// We're going to chdir to a deploy environment,
-			// so save the PWD to return to, afterwards.
 			pwd, err := os.Getwd()
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			// Create our deploy environment in a temp dir.
-			// Defer a call to clean it up.
-			rootdir := makeDeployEnvironment(t, "")
+			rootdir, err := ioutil.TempDir("", "fastly-test")
+			if err != nil {
+				t.Fatal(err)
+			}
 			defer os.RemoveAll(rootdir)
 
-			// Before running the test, chdir into the build environment.
-			// When we're done, chdir back to our original location.
-			// This is so we can reliably copy the testdata/ fixtures.
+			if testcase.fastlyManifest != "" {
+				if err := ioutil.WriteFile(filepath.Join(rootdir, compute.ManifestFilename), []byte(testcase.fastlyManifest), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
 			if err := os.Chdir(rootdir); err != nil {
 				t.Fatal(err)
 			}
@@ -864,19 +2391,22 @@ func TestValidate(t *testing.T) {
 			)
 			err = app.Run(args, env, file, appConfigFile, clientFactory, httpClient, versioner, in, out)
 			testutil.AssertErrorContains(t, err, testcase.wantError)
-			testutil.AssertStringContains(t, buf.String(), testcase.wantOutput)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
 		})
 	}
 }
 
 func TestUploadPackage(t *testing.T) {
 	for _, testcase := range []struct {
-		name      string
-		client    *compute.Client
-		serviceID string
-		version   int
-		path      string
-		wantError string
+		name                 string
+		client               *compute.Client
+		serviceID            string
+		version              int
+		path                 string
+		opts                 compute.UploadOptions
+		wantError            string
+		wantRemediationError string
+		wantProgress         string
 	}{
 		{
 			name:      "no package",
@@ -910,6 +2440,62 @@ func TestUploadPackage(t *testing.T) {
 			path:      "pkg/package.tar.gz",
 			wantError: "error from API: 500 Internal Server Error",
 		},
+		{
+			name:                 "package API error body",
+			client:               compute.NewClient(&uploadClient{codes: []int{http.StatusBadRequest}, body: `{"msg":"Bad request","detail":"Package is missing a _start export"}`}, "", ""),
+			serviceID:            "123",
+			version:              1,
+			path:                 "pkg/package.tar.gz",
+			wantError:            "error from API: 400 Bad Request: Bad request",
+			wantRemediationError: "Package is missing a _start export",
+		},
+		{
+			name:                 "package API unauthorized",
+			client:               compute.NewClient(&uploadClient{codes: []int{http.StatusUnauthorized}, body: `{"msg":"Provided credentials are missing or invalid"}`}, "", ""),
+			serviceID:            "123",
+			version:              1,
+			path:                 "pkg/package.tar.gz",
+			wantError:            "error from API: 401 Unauthorized: Provided credentials are missing or invalid",
+			wantRemediationError: "fastly whoami",
+		},
+		{
+			name:                 "package too large",
+			client:               compute.NewClient(codeClient{http.StatusOK}, "", ""),
+			serviceID:            "123",
+			version:              1,
+			path:                 "pkg/package.tar.gz",
+			opts:                 compute.UploadOptions{MaxSize: 10},
+			wantError:            "exceeds the limit of 10 bytes",
+			wantRemediationError: "fastly compute build --strip",
+		},
+		{
+			name:         "retried server error",
+			client:       compute.NewClient(&uploadClient{codes: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}}, "", ""),
+			serviceID:    "123",
+			version:      1,
+			path:         "pkg/package.tar.gz",
+			opts:         compute.UploadOptions{Retries: 2, Backoff: time.Millisecond},
+			wantProgress: "Upload failed, retrying in 2ms: error from API: 502 Bad Gateway",
+		},
+		{
+			name:         "retries exhausted",
+			client:       compute.NewClient(codeClient{http.StatusInternalServerError}, "", ""),
+			serviceID:    "123",
+			version:      1,
+			path:         "pkg/package.tar.gz",
+			opts:         compute.UploadOptions{Retries: 2, Backoff: time.Millisecond},
+			wantError:    "error from API: 500 Internal Server Error",
+			wantProgress: "Upload failed, retrying in 1ms",
+		},
+		{
+			name:      "client error not retried",
+			client:    compute.NewClient(&uploadClient{codes: []int{http.StatusNotFound, http.StatusOK}}, "", ""),
+			serviceID: "123",
+			version:   1,
+			path:      "pkg/package.tar.gz",
+			opts:      compute.UploadOptions{Retries: 2, Backoff: time.Millisecond},
+			wantError: "error from API: 404 Not Found",
+		},
 		{
 			name:      "success",
 			client:    compute.NewClient(codeClient{http.StatusOK}, "", ""),
@@ -918,6 +2504,14 @@ func TestUploadPackage(t *testing.T) {
 			path:      "pkg/package.tar.gz",
 			wantError: "",
 		},
+		{
+			name:         "success with progress",
+			client:       compute.NewClient(&uploadClient{codes: []int{http.StatusOK}}, "", ""),
+			serviceID:    "123",
+			version:      1,
+			path:         "pkg/package.tar.gz",
+			wantProgress: "Uploaded",
+		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
 			// We're going to chdir to a deploy environment,
@@ -940,8 +2534,14 @@ func TestUploadPackage(t *testing.T) {
 			}
 			defer os.Chdir(pwd)
 
-			err = testcase.client.UpdatePackage(testcase.serviceID, testcase.version, testcase.path)
+			var progress bytes.Buffer
+			opts := testcase.opts
+			opts.Progress = &progress
+
+			err = testcase.client.UpdatePackage(testcase.serviceID, testcase.version, testcase.path, opts)
 			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
+			testutil.AssertStringContains(t, progress.String(), testcase.wantProgress)
 		})
 	}
 }
@@ -1118,6 +2718,10 @@ func deleteServiceOK(i *fastly.DeleteServiceInput) error {
 	return nil
 }
 
+func deleteServiceError(i *fastly.DeleteServiceInput) error {
+	return errTest
+}
+
 func createDomainOK(i *fastly.CreateDomainInput) (*fastly.Domain, error) {
 	return &fastly.Domain{
 		ServiceID: i.Service,
@@ -1185,6 +2789,31 @@ func listVersionsActiveOk(i *fastly.ListVersionsInput) ([]*fastly.Version, error
 	}, nil
 }
 
+// listVersionsConcurrentDeploy returns a function which lists the versions of
+// listVersionsActiveOk the first time it is called, and afterwards lists them
+// as though another deploy had since activated version active and updated
+// version 1 at updatedAt.
+func listVersionsConcurrentDeploy(active int, updatedAt string) func(*fastly.ListVersionsInput) ([]*fastly.Version, error) {
+	var calls int
+	return func(i *fastly.ListVersionsInput) ([]*fastly.Version, error) {
+		calls++
+		versions, err := listVersionsActiveOk(i)
+		if calls == 1 {
+			return versions, err
+		}
+		versions[0].Active = false
+		versions[0].UpdatedAt = testutil.MustParseTimeRFC3339(updatedAt)
+		versions = append(versions, &fastly.Version{
+			ServiceID: i.Service,
+			Number:    3,
+			Locked:    true,
+			UpdatedAt: testutil.MustParseTimeRFC3339("2000-01-03T01:00:00Z"),
+		})
+		versions[active-1].Active = true
+		return versions, err
+	}
+}
+
 func listVersionsError(i *fastly.ListVersionsInput) ([]*fastly.Version, error) {
 	return nil, errTest
 }
@@ -1197,6 +2826,25 @@ func cloneVersionError(i *fastly.CloneVersionInput) (*fastly.Version, error) {
 	return nil, errTest
 }
 
+func updateVersionOk(i *fastly.UpdateVersionInput) (*fastly.Version, error) {
+	return &fastly.Version{ServiceID: i.Service, Number: i.Version, Comment: i.Comment}, nil
+}
+
+// updateVersionWithComment returns a function which updates a version, unless
+// its comment doesn't contain want.
+func updateVersionWithComment(want string) func(*fastly.UpdateVersionInput) (*fastly.Version, error) {
+	return func(i *fastly.UpdateVersionInput) (*fastly.Version, error) {
+		if !strings.Contains(i.Comment, want) {
+			return nil, fmt.Errorf("comment %q doesn't contain %q", i.Comment, want)
+		}
+		return updateVersionOk(i)
+	}
+}
+
+func updateVersionError(i *fastly.UpdateVersionInput) (*fastly.Version, error) {
+	return nil, errTest
+}
+
 func activateVersionOk(i *fastly.ActivateVersionInput) (*fastly.Version, error) {
 	return &fastly.Version{ServiceID: i.Service, Number: i.Version}, nil
 }
@@ -1205,6 +2853,14 @@ func activateVersionError(i *fastly.ActivateVersionInput) (*fastly.Version, erro
 	return nil, errTest
 }
 
+func deactivateVersionOk(i *fastly.DeactivateVersionInput) (*fastly.Version, error) {
+	return &fastly.Version{ServiceID: i.Service, Number: i.Version}, nil
+}
+
+func deactivateVersionError(i *fastly.DeactivateVersionInput) (*fastly.Version, error) {
+	return nil, errTest
+}
+
 func listDomainsOk(i *fastly.ListDomainsInput) ([]*fastly.Domain, error) {
 	return []*fastly.Domain{
 		&fastly.Domain{Name: "https://directly-careful-coyote.edgecompute.app"},
@@ -1223,6 +2879,42 @@ func (c errorClient) Do(*http.Request) (*http.Response, error) {
 	return nil, c.err
 }
 
+// uploadClient reads package uploads as the API would, responding to each
+// with the next status code and the body. An upload whose length or form
+// doesn't match its headers is rejected.
+type uploadClient struct {
+	codes []int
+	body  string
+}
+
+func (c *uploadClient) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	code := c.codes[0]
+	if len(c.codes) > 1 {
+		c.codes = c.codes[1:]
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != req.ContentLength {
+		rec.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rec, `{"msg":"Content-Length is %d but the body is %d bytes"}`, req.ContentLength, len(body))
+		return rec.Result(), nil
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if _, _, err := req.FormFile("package"); err != nil {
+		rec.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rec, `{"msg":"invalid package form: %v"}`, err)
+		return rec.Result(), nil
+	}
+
+	rec.WriteHeader(code)
+	rec.WriteString(c.body)
+	return rec.Result(), nil
+}
+
 type codeClient struct {
 	code int
 }
@@ -1233,6 +2925,42 @@ func (c codeClient) Do(*http.Request) (*http.Response, error) {
 	return rec.Result(), nil
 }
 
+// verifyClient responds to requests to the verification URL with the status
+// code, and to every other request with 200 OK.
+type verifyClient struct {
+	code int
+}
+
+func (c verifyClient) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	if req.URL.Host == "verify.example.com" {
+		rec.WriteHeader(c.code)
+	}
+	return rec.Result(), nil
+}
+
+// packageClient responds to package API requests as though the package at
+// path had been uploaded.
+type packageClient struct {
+	path string
+}
+
+func (p packageClient) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	if req.Method != http.MethodGet {
+		rec.WriteHeader(http.StatusOK)
+		return rec.Result(), nil
+	}
+
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha512.Sum512(data)
+	fmt.Fprintf(rec, `{"metadata":{"size":%d,"hashsum":"%x"}}`, len(data), sum)
+	return rec.Result(), nil
+}
+
 type versionClient struct {
 	versions []string
 }
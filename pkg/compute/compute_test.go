@@ -1,20 +1,29 @@
 package compute
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/ignore"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/compute/wasm"
+	"github.com/fastly/cli/pkg/config"
 	"github.com/fastly/cli/pkg/testutil"
 	"github.com/fastly/go-fastly/fastly"
 	"github.com/mholt/archiver/v3"
@@ -43,6 +52,7 @@ func TestCreatePackageArchive(t *testing.T) {
 			wantFiles: []string{
 				"Cargo.lock",
 				"Cargo.toml",
+				"package-metadata.json",
 				"main.rs",
 			},
 		},
@@ -68,7 +78,7 @@ func TestCreatePackageArchive(t *testing.T) {
 			}
 			defer os.Chdir(pwd)
 
-			err = createPackageArchive(testcase.inputFiles, testcase.destination)
+			err = createPackageArchive(".", testcase.inputFiles, testcase.destination)
 			testutil.AssertNoError(t, err)
 
 			var files, directories []string
@@ -114,101 +124,90 @@ func TestFileNameWithoutExtension(t *testing.T) {
 	}
 }
 
-func TestGetIgnoredFiles(t *testing.T) {
+func TestGetNonIgnoredFiles(t *testing.T) {
 	for _, testcase := range []struct {
 		name         string
+		path         string
 		fastlyignore string
-		wantfiles    map[string]bool
+		wantFiles    []string
 	}{
+		{
+			name: "no ignored files",
+			path: ".",
+			wantFiles: []string{
+				"Cargo.lock",
+				"Cargo.toml",
+				filepath.Join("src/main.rs"),
+			},
+		},
+		{
+			name:         "one ignored file",
+			path:         ".",
+			fastlyignore: "src/main.rs",
+			wantFiles: []string{
+				".fastlyignore",
+				"Cargo.lock",
+				"Cargo.toml",
+			},
+		},
+		{
+			name:         "multiple ignored files",
+			path:         ".",
+			fastlyignore: "Cargo.toml\nCargo.lock\n.fastlyignore",
+			wantFiles: []string{
+				filepath.Join("src/main.rs"),
+			},
+		},
 		{
 			name:         "ignore src",
+			path:         ".",
 			fastlyignore: "src/*",
-			wantfiles: map[string]bool{
-				filepath.Join("src/main.rs"): true,
+			wantFiles: []string{
+				".fastlyignore",
+				"Cargo.lock",
+				"Cargo.toml",
 			},
 		},
 		{
 			name:         "ignore cargo files",
+			path:         ".",
 			fastlyignore: "Cargo.*",
-			wantfiles: map[string]bool{
-				"Cargo.lock": true,
-				"Cargo.toml": true,
+			wantFiles: []string{
+				".fastlyignore",
+				filepath.Join("src/main.rs"),
 			},
 		},
 		{
 			name:         "ignore all",
+			path:         ".",
 			fastlyignore: "*",
-			wantfiles: map[string]bool{
-				".fastlyignore": true,
-				"Cargo.lock":    true,
-				"Cargo.toml":    true,
-				"src":           true,
-			},
 		},
-	} {
-		t.Run(testcase.name, func(t *testing.T) {
-			// we're going to chdir to a build environment,
-			// so save the pwd to return to, afterwards.
-			pwd, err := os.Getwd()
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			// create our build environment in a temp dir.
-			// defer a call to clean it up.
-			rootdir := makeBuildEnvironment(t, testcase.fastlyignore)
-			defer os.RemoveAll(rootdir)
-
-			// before running the test, chdir into the build environment.
-			// when we're done, chdir back to our original location.
-			// this is so we can reliably copy the testdata/ fixtures.
-			if err := os.Chdir(rootdir); err != nil {
-				t.Fatal(err)
-			}
-			defer os.Chdir(pwd)
-
-			output, err := getIgnoredFiles(IgnoreFilePath)
-			testutil.AssertNoError(t, err)
-			testutil.AssertEqual(t, testcase.wantfiles, output)
-		})
-	}
-}
-
-func TestGetNonIgnoredFiles(t *testing.T) {
-	for _, testcase := range []struct {
-		name         string
-		path         string
-		ignoredFiles map[string]bool
-		wantFiles    []string
-	}{
 		{
-			name:         "no ignored files",
+			name:         "negated pattern",
 			path:         ".",
-			ignoredFiles: map[string]bool{},
+			fastlyignore: "# ignore everything but the lock file\n*\n!Cargo.lock\n",
 			wantFiles: []string{
 				"Cargo.lock",
-				"Cargo.toml",
-				filepath.Join("src/main.rs"),
 			},
 		},
 		{
-			name: "one ignored file",
-			path: ".",
-			ignoredFiles: map[string]bool{
-				filepath.Join("src/main.rs"): true,
-			},
+			name:         "directory pattern",
+			path:         ".",
+			fastlyignore: "src/\n/.fastlyignore",
 			wantFiles: []string{
 				"Cargo.lock",
 				"Cargo.toml",
 			},
 		},
 		{
-			name: "multiple ignored files",
-			path: ".",
-			ignoredFiles: map[string]bool{
-				"Cargo.toml": true,
-				"Cargo.lock": true,
-			},
+			name:         "unanchored pattern",
+			path:         "src",
+			fastlyignore: "**/*.rs",
+		},
+		{
+			name:         "anchored pattern",
+			path:         "src",
+			fastlyignore: "/main.rs",
 			wantFiles: []string{
 				filepath.Join("src/main.rs"),
 			},
@@ -224,7 +223,7 @@ func TestGetNonIgnoredFiles(t *testing.T) {
 
 			// Create our build environment in a temp dir.
 			// Defer a call to clean it up.
-			rootdir := makeBuildEnvironment(t, "")
+			rootdir := makeBuildEnvironment(t, testcase.fastlyignore)
 			defer os.RemoveAll(rootdir)
 
 			// Before running the test, chdir into the build environment.
@@ -235,13 +234,140 @@ func TestGetNonIgnoredFiles(t *testing.T) {
 			}
 			defer os.Chdir(pwd)
 
-			output, err := getNonIgnoredFiles(testcase.path, testcase.ignoredFiles)
+			matcher, err := ignore.ReadFile(IgnoreFilePath)
+			testutil.AssertNoError(t, err)
+
+			output, err := getNonIgnoredFiles(testcase.path, matcher)
 			testutil.AssertNoError(t, err)
 			testutil.AssertEqual(t, testcase.wantFiles, output)
 		})
 	}
 }
 
+func TestParseTemplateSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fastly-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "template.tar.gz")
+	if err := ioutil.WriteFile(archive, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name       string
+		from       string
+		wantSource templateSource
+		wantError  string
+	}{
+		{
+			name:       "git URL",
+			from:       "https://github.com/fastly/fastly-template-rust-default.git",
+			wantSource: templateSource{Repository: "https://github.com/fastly/fastly-template-rust-default.git"},
+		},
+		{
+			name:       "URL",
+			from:       "https://git.example.com/templates/rust",
+			wantSource: templateSource{Repository: "https://git.example.com/templates/rust"},
+		},
+		{
+			name:       "local directory",
+			from:       dir,
+			wantSource: templateSource{Directory: dir},
+		},
+		{
+			name:       "local archive",
+			from:       archive,
+			wantSource: templateSource{Archive: archive},
+		},
+		{
+			name:       "archive URL",
+			from:       "https://example.com/template.tar.gz",
+			wantSource: templateSource{Archive: "https://example.com/template.tar.gz"},
+		},
+		{
+			name:      "missing archive",
+			from:      filepath.Join(dir, "missing.tar.gz"),
+			wantError: "not found",
+		},
+		{
+			name:       "shorthand",
+			from:       "fastly/templates",
+			wantSource: templateSource{Repository: "https://github.com/fastly/templates.git"},
+		},
+		{
+			name: "shorthand with subdirectory and tag",
+			from: "fastly/templates/rust/default@v1.2.0",
+			wantSource: templateSource{
+				Repository: "https://github.com/fastly/templates.git",
+				Ref:        "v1.2.0",
+				Subdir:     "rust/default",
+			},
+		},
+		{
+			name:      "invalid",
+			from:      "template",
+			wantError: "must be a Git URL, local directory, .tar.gz archive, or org/repo[/subdir]@tag",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			source, err := parseTemplateSource(testcase.from)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertEqual(t, testcase.wantSource, source)
+		})
+	}
+}
+
+func TestCopyTemplateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fastly-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	variables := templateVariables("test", "123", "test@example.com")
+
+	for _, testcase := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "variables",
+			content: "name = \"{{name}}\"\nservice_id = \"{{service_id}}\"\nauthors = [\"{{author}}\"]\n",
+			want:    "name = \"test\"\nservice_id = \"123\"\nauthors = [\"test@example.com\"]\n",
+		},
+		{
+			name:    "unknown variable",
+			content: "{{ name }} {{description}}",
+			want:    "{{ name }} {{description}}",
+		},
+		{
+			name:    "binary",
+			content: "\x00asm{{name}}",
+			want:    "\x00asm{{name}}",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			src := filepath.Join(dir, "src")
+			dst := filepath.Join(dir, "dst")
+			if err := ioutil.WriteFile(src, []byte(testcase.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := copyTemplateFile(src, dst, variables); err != nil {
+				t.Fatal(err)
+			}
+			have, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertString(t, testcase.want, string(have))
+		})
+	}
+}
+
 func TestGetIdealPackage(t *testing.T) {
 	for _, testcase := range []struct {
 		name          string
@@ -349,7 +475,7 @@ func TestGetLatestCrateVersion(t *testing.T) {
 		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
-			v, err := getLatestCrateVersion(testcase.inputClient, "fastly")
+			v, err := getLatestCrateVersion(testcase.inputClient, config.DefaultCratesEndpoint, "fastly")
 			testutil.AssertErrorContains(t, err, testcase.wantError)
 			if err == nil && !v.Equal(testcase.wantVersion) {
 				t.Errorf("wanted version %s, got %s", testcase.wantVersion, v)
@@ -358,6 +484,126 @@ func TestGetLatestCrateVersion(t *testing.T) {
 	}
 }
 
+func TestCrateRegistry(t *testing.T) {
+	rootdir, err := ioutil.TempDir("", "fastly-crates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	metadata := CargoMetadata{Package: []CargoPackage{{Name: "fastly", Version: "0.3.2"}}}
+
+	for _, testcase := range []struct {
+		name        string
+		cache       crateCache
+		client      api.HTTPClient
+		offline     bool
+		wantVersion string
+		wantWarning string
+		wantCached  string
+		wantError   string
+	}{
+		{
+			name:        "lookup",
+			client:      versionClient{[]string{"0.4.0"}},
+			wantVersion: "0.4.0",
+			wantCached:  "0.4.0",
+		},
+		{
+			name: "cached",
+			cache: crateCache{
+				"https://crates.io/fastly": {Version: "0.3.3", FetchedAt: time.Now()},
+			},
+			client:      errorClient{errTest},
+			wantVersion: "0.3.3",
+			wantCached:  "0.3.3",
+		},
+		{
+			name: "cached from another endpoint",
+			cache: crateCache{
+				"https://mirror.example.com/fastly": {Version: "0.3.3", FetchedAt: time.Now()},
+			},
+			client:      versionClient{[]string{"0.4.0"}},
+			wantVersion: "0.4.0",
+			wantCached:  "0.4.0",
+		},
+		{
+			name: "expired",
+			cache: crateCache{
+				"https://crates.io/fastly": {Version: "0.3.3", FetchedAt: time.Now().Add(-crateCacheTTL)},
+			},
+			client:      versionClient{[]string{"0.4.0"}},
+			wantVersion: "0.4.0",
+			wantCached:  "0.4.0",
+		},
+		{
+			name: "expired and lookup error",
+			cache: crateCache{
+				"https://crates.io/fastly": {Version: "0.3.3", FetchedAt: time.Now().Add(-crateCacheTTL)},
+			},
+			client:      errorClient{errTest},
+			wantVersion: "0.3.3",
+			wantWarning: "using the cached version 0.3.3",
+			wantCached:  "0.3.3",
+		},
+		{
+			name:      "lookup error",
+			client:    errorClient{errTest},
+			wantError: "fixture error",
+		},
+		{
+			name: "offline cached",
+			cache: crateCache{
+				"https://crates.io/fastly": {Version: "0.3.3", FetchedAt: time.Now().Add(-crateCacheTTL)},
+			},
+			client:      errorClient{errTest},
+			offline:     true,
+			wantVersion: "0.3.3",
+			wantWarning: "offline, using the cached latest version 0.3.3",
+			wantCached:  "0.3.3",
+		},
+		{
+			name:        "offline locked",
+			client:      errorClient{errTest},
+			offline:     true,
+			wantVersion: "0.3.2",
+			wantWarning: "using the locked version 0.3.2",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			cacheFile := filepath.Join(rootdir, strings.Replace(testcase.name, " ", "-", -1), "crates.json")
+			r := Rust{
+				crates:  crateRegistry{client: testcase.client, endpoint: config.DefaultCratesEndpoint, cacheFile: cacheFile},
+				offline: testcase.offline,
+			}
+			if testcase.cache != nil {
+				if err := r.crates.writeCache(testcase.cache); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out strings.Builder
+			v, err := r.latestCrateVersion(&out, metadata, "fastly")
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err != nil {
+				return
+			}
+			testutil.AssertString(t, testcase.wantVersion, v.String())
+			if testcase.wantWarning == "" {
+				testutil.AssertString(t, "", out.String())
+			} else {
+				testutil.AssertStringContains(t, out.String(), testcase.wantWarning)
+			}
+
+			var cached string
+			if v, err := r.crates.cachedVersion("fastly"); err == nil {
+				cached = v.String()
+			}
+			testutil.AssertString(t, testcase.wantCached, cached)
+		})
+	}
+}
+
 func TestGetCrateVersionFromMetadata(t *testing.T) {
 	for _, testcase := range []struct {
 		name        string
@@ -444,53 +690,1164 @@ func TestGetCrateVersionFromMetadata(t *testing.T) {
 	}
 }
 
-func makeBuildEnvironment(t *testing.T, fastlyIgnoreContent string) (rootdir string) {
-	t.Helper()
-
-	p := make([]byte, 8)
-	n, err := rand.Read(p)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	rootdir = filepath.Join(
-		os.TempDir(),
-		fmt.Sprintf("fastly-build-%x", p[:n]),
-	)
-
-	if err := os.MkdirAll(rootdir, 0700); err != nil {
-		t.Fatal(err)
+func TestSelectBinary(t *testing.T) {
+	workspace := CargoMetadata{
+		WorkspaceMembers: []string{"edge 0.1.0", "tools 0.1.0", "common 0.1.0"},
+		Package: []CargoPackage{
+			{
+				ID:           "edge 0.1.0",
+				Name:         "edge",
+				ManifestPath: "/ws/edge/Cargo.toml",
+				Targets:      []CargoTarget{{Name: "edge-app", Kind: []string{"bin"}}},
+			},
+			{
+				ID:           "tools 0.1.0",
+				Name:         "tools",
+				ManifestPath: "/ws/tools/Cargo.toml",
+				Targets: []CargoTarget{
+					{Name: "tools", Kind: []string{"lib"}},
+					{Name: "gen", Kind: []string{"bin"}},
+					{Name: "check", Kind: []string{"bin"}},
+				},
+			},
+			{
+				ID:           "common 0.1.0",
+				Name:         "common",
+				ManifestPath: "/ws/common/Cargo.toml",
+				Targets:      []CargoTarget{{Name: "common", Kind: []string{"lib"}}},
+			},
+			{
+				ID:      "fastly 0.5.0",
+				Name:    "fastly",
+				Targets: []CargoTarget{{Name: "fastly", Kind: []string{"lib"}}},
+			},
+		},
 	}
 
-	for _, filename := range [][]string{
-		{"Cargo.toml"},
-		{"Cargo.lock"},
-		{"src", "main.rs"},
+	for _, testcase := range []struct {
+		name                 string
+		pkg                  string
+		bin                  string
+		manifestPath         string
+		wantBinary           cargoBinary
+		wantError            string
+		wantRemediationError string
+	}{
+		{
+			name:         "package in current directory",
+			manifestPath: "/ws/edge/Cargo.toml",
+			wantBinary:   cargoBinary{Package: "edge", Name: "edge-app"},
+		},
+		{
+			name:                 "virtual workspace",
+			manifestPath:         "/ws/Cargo.toml",
+			wantError:            "the cargo workspace has more than one binary target: edge-app, gen, check",
+			wantRemediationError: "fastly compute build --bin edge-app",
+		},
+		{
+			name:         "virtual workspace with bin",
+			bin:          "gen",
+			manifestPath: "/ws/Cargo.toml",
+			wantBinary:   cargoBinary{Package: "tools", Name: "gen"},
+		},
+		{
+			name:         "package",
+			pkg:          "edge",
+			manifestPath: "/ws/tools/Cargo.toml",
+			wantBinary:   cargoBinary{Package: "edge", Name: "edge-app"},
+		},
+		{
+			name:                 "package with more than one binary",
+			manifestPath:         "/ws/tools/Cargo.toml",
+			wantError:            "the cargo workspace has more than one binary target: gen, check",
+			wantRemediationError: "--bin gen",
+		},
+		{
+			name:         "package and bin",
+			pkg:          "tools",
+			bin:          "check",
+			manifestPath: "/ws/edge/Cargo.toml",
+			wantBinary:   cargoBinary{Package: "tools", Name: "check"},
+		},
+		{
+			name:                 "unknown bin",
+			bin:                  "missing",
+			manifestPath:         "/ws/tools/Cargo.toml",
+			wantError:            "binary target missing not found in the cargo workspace",
+			wantRemediationError: "one of the binary targets: gen, check",
+		},
+		{
+			name:                 "unknown package",
+			pkg:                  "fastly",
+			manifestPath:         "/ws/edge/Cargo.toml",
+			wantError:            "package fastly isn't a member of the cargo workspace",
+			wantRemediationError: "set the --package flag",
+		},
+		{
+			name:         "package without binaries",
+			manifestPath: "/ws/common/Cargo.toml",
+			wantError:    "no binary targets found in the cargo workspace",
+		},
+		{
+			name:         "named package without binaries",
+			pkg:          "common",
+			manifestPath: "/ws/edge/Cargo.toml",
+			wantError:    "package common has no binary targets",
+		},
 	} {
-		fromFilename := filepath.Join("testdata", "build", filepath.Join(filename...))
-		toFilename := filepath.Join(rootdir, filepath.Join(filename...))
-		if err := common.CopyFile(fromFilename, toFilename); err != nil {
-			t.Fatal(err)
-		}
+		t.Run(testcase.name, func(t *testing.T) {
+			b, err := selectBinary(workspace, testcase.pkg, testcase.bin, testcase.manifestPath)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediationError)
+			testutil.AssertEqual(t, testcase.wantBinary, b)
+		})
 	}
+}
 
-	if fastlyIgnoreContent != "" {
-		filename := filepath.Join(rootdir, IgnoreFilePath)
-		if err := ioutil.WriteFile(filename, []byte(fastlyIgnoreContent), 0777); err != nil {
+func TestCargoMessages(t *testing.T) {
+	var out strings.Builder
+	m := &cargoMessages{out: &out, bin: "edge-app"}
+
+	// Build messages may be split across writes.
+	messages := `{"reason":"compiler-artifact","target":{"kind":["lib"],"name":"fastly"},"filenames":["/ws/target/wasm32-wasi/release/deps/libfastly.rlib"],"executable":null,"fresh":true}
+{"reason":"compiler-message","target":{"kind":["bin"],"name":"edge-app"},"message":{"level":"warning","rendered":"warning: unused variable: ` + "`x`" + `\n"}}
+not a build message
+{"reason":"compiler-message","target":{"kind":["bin"],"name":"edge-app"},"message":{"level":"error","rendered":"error: expected one of ` + "`;`" + `\n"}}
+{"reason":"compiler-artifact","target":{"kind":["bin"],"name":"edge-app"},"profile":{"test":true},"filenames":["/ws/target/wasm32-wasi/debug/deps/edge_app-0123.wasm"],"executable":"/ws/target/wasm32-wasi/debug/deps/edge_app-0123.wasm","fresh":false}
+{"reason":"compiler-artifact","target":{"kind":["bin"],"name":"edge-app"},"filenames":["/ws/target/wasm32-wasi/release/edge-app.wasm"],"executable":"/ws/target/wasm32-wasi/release/edge-app.wasm","fresh":false}
+{"reason":"build-finished","success":true}
+`
+	for len(messages) > 0 {
+		n := 40
+		if n > len(messages) {
+			n = len(messages)
+		}
+		if _, err := m.Write([]byte(messages[:n])); err != nil {
 			t.Fatal(err)
 		}
+		messages = messages[n:]
 	}
 
-	return rootdir
-}
-
-var errTest = errors.New("fixture error")
-
-type errorClient struct {
-	err error
+	testutil.AssertString(t, "warning: unused variable: `x`\nnot a build message\nerror: expected one of `;`\n", out.String())
+	testutil.AssertString(t, "error: expected one of `;`\n", m.errors.String())
+	testutil.AssertString(t, "/ws/target/wasm32-wasi/release/edge-app.wasm", m.artifact)
+	testutil.AssertEqual(t, []TestBinary{
+		{Name: "edge-app (bin)", Path: "/ws/target/wasm32-wasi/debug/deps/edge_app-0123.wasm", Harness: libtestHarness{}},
+	}, m.tests)
 }
 
-func (c errorClient) Do(*http.Request) (*http.Response, error) {
+func TestGetNPMPackageVersion(t *testing.T) {
+	for _, testcase := range []struct {
+		name        string
+		inputOutput string
+		wantVersion *semver.Version
+		wantError   string
+	}{
+		{
+			name:        "invalid output",
+			inputOutput: "npm ERR!",
+			wantError:   "error parsing npm package list",
+		},
+		{
+			name:        "package not found",
+			inputOutput: `{"name":"test","version":"1.0.0"}`,
+			wantError:   "assemblyscript package not found",
+		},
+		{
+			name:        "invalid version",
+			inputOutput: `{"dependencies":{"assemblyscript":{"version":"latest"}}}`,
+			wantError:   "error parsing npm package list",
+		},
+		{
+			name:        "success",
+			inputOutput: `{"name":"test","dependencies":{"assemblyscript":{"version":"0.14.3"}}}`,
+			wantVersion: semver.MustParse("0.14.3"),
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			v, err := getNPMPackageVersion([]byte(testcase.inputOutput), "assemblyscript")
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err == nil && !v.Equal(testcase.wantVersion) {
+				t.Errorf("wanted version %s, got %s", testcase.wantVersion, v)
+			}
+		})
+	}
+}
+
+func TestResolveRustToolchain(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name          string
+		toolchain     string
+		files         map[string]string
+		wantToolchain string
+		wantSource    string
+		wantError     string
+	}{
+		{
+			name:          "default",
+			wantToolchain: RustToolchainVersion,
+		},
+		{
+			name:          "manifest",
+			toolchain:     "1.46.0",
+			files:         map[string]string{"rust-toolchain": "stable"},
+			wantToolchain: "1.46.0",
+			wantSource:    "fastly.toml",
+		},
+		{
+			name:          "legacy file",
+			files:         map[string]string{"rust-toolchain": "nightly-2020-08-01\n"},
+			wantToolchain: "nightly-2020-08-01",
+			wantSource:    "rust-toolchain",
+		},
+		{
+			name:          "legacy file in TOML format",
+			files:         map[string]string{"rust-toolchain": "[toolchain]\nchannel = \"1.45.2\"\n"},
+			wantToolchain: "1.45.2",
+			wantSource:    "rust-toolchain",
+		},
+		{
+			name:          "TOML file",
+			files:         map[string]string{"rust-toolchain.toml": "[toolchain]\nchannel = \"stable\"\ntargets = [\"wasm32-wasi\"]\n"},
+			wantToolchain: "stable",
+			wantSource:    "rust-toolchain.toml",
+		},
+		{
+			name: "legacy file takes precedence",
+			files: map[string]string{
+				"rust-toolchain":      "beta",
+				"rust-toolchain.toml": "[toolchain]\nchannel = \"stable\"\n",
+			},
+			wantToolchain: "beta",
+			wantSource:    "rust-toolchain",
+		},
+		{
+			name:      "TOML file without channel",
+			files:     map[string]string{"rust-toolchain.toml": "[toolchain]\n"},
+			wantError: "error parsing rust-toolchain.toml: no toolchain channel set",
+		},
+		{
+			name:      "invalid legacy file",
+			files:     map[string]string{"rust-toolchain": "stable beta"},
+			wantError: "error parsing rust-toolchain: expected a single toolchain name",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			rootdir, err := ioutil.TempDir("", "fastly-toolchain")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(rootdir)
+
+			for filename, content := range testcase.files {
+				if err := ioutil.WriteFile(filepath.Join(rootdir, filename), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := os.Chdir(rootdir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(pwd)
+
+			toolchain, source, err := Rust{toolchain: testcase.toolchain}.resolveToolchain()
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertString(t, testcase.wantToolchain, toolchain)
+			testutil.AssertString(t, testcase.wantSource, source)
+		})
+	}
+}
+
+func TestCheckRustToolchainVersion(t *testing.T) {
+	for _, testcase := range []struct {
+		name            string
+		inputOutput     string
+		source          string
+		wantError       string
+		wantRemediation string
+	}{
+		{
+			name:        "unexpected output",
+			inputOutput: "error: toolchain 'stable' is not installed",
+			wantError:   "unexpected output",
+		},
+		{
+			name:        "minimum version",
+			inputOutput: "rustc 1.43.0 (4fb7144ed 2020-04-20)\n",
+		},
+		{
+			name:        "nightly",
+			inputOutput: "rustc 1.48.0-nightly (d006f5734 2020-08-28)\n",
+		},
+		{
+			name:            "unsupported version set by manifest",
+			inputOutput:     "rustc 1.42.0 (b8cedc004 2020-03-09)\n",
+			source:          "fastly.toml",
+			wantError:       "doesn't meet the supported version constraint",
+			wantRemediation: "[language.rust] section of",
+		},
+		{
+			name:            "unsupported version set by file",
+			inputOutput:     "rustc 1.40.0-nightly (2477e2493 2019-11-04)\n",
+			source:          "rust-toolchain",
+			wantError:       "doesn't meet the supported version constraint",
+			wantRemediation: "set a supported toolchain in",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			v, err := parseRustcVersion(testcase.inputOutput)
+			if err == nil {
+				err = checkRustToolchainVersion("test", testcase.source, v)
+			}
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if testcase.wantRemediation != "" {
+				testutil.AssertRemediationErrorContains(t, err, testcase.wantRemediation)
+			}
+		})
+	}
+}
+
+func TestParseTinyGoVersion(t *testing.T) {
+	for _, testcase := range []struct {
+		name        string
+		inputOutput string
+		wantVersion *semver.Version
+		wantError   string
+	}{
+		{
+			name:        "unexpected output",
+			inputOutput: "command not found",
+			wantError:   "unexpected output",
+		},
+		{
+			name:        "invalid version",
+			inputOutput: "tinygo version dev linux/amd64",
+			wantError:   "error parsing tinygo version",
+		},
+		{
+			name:        "success",
+			inputOutput: "tinygo version 0.15.0 linux/amd64 (using go version go1.14.4 and LLVM version 10.0.1)\n",
+			wantVersion: semver.MustParse("0.15.0"),
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			v, err := parseTinyGoVersion(testcase.inputOutput)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err == nil && !v.Equal(testcase.wantVersion) {
+				t.Errorf("wanted version %s, got %s", testcase.wantVersion, v)
+			}
+		})
+	}
+}
+
+func TestTinyGoBuildArgs(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		version  string
+		verbose  bool
+		wantArgs []string
+	}{
+		{
+			name:     "generic wasm abi",
+			version:  "0.26.0",
+			wantArgs: []string{"build", "-target", "wasi", "-wasm-abi", "generic", "-o", "main.wasm", "."},
+		},
+		{
+			name:     "without wasm abi",
+			version:  "0.27.0",
+			wantArgs: []string{"build", "-target", "wasi", "-o", "main.wasm", "."},
+		},
+		{
+			name:     "verbose",
+			version:  "0.30.0",
+			verbose:  true,
+			wantArgs: []string{"build", "-target", "wasi", "-o", "main.wasm", "-x", "."},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			args, err := tinyGoBuildArgs(semver.MustParse(testcase.version), "main.wasm", testcase.verbose)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, testcase.wantArgs, args)
+		})
+	}
+}
+
+func TestPackageFilesSourceRoot(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-package-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	for _, dir := range []string{"bin", "pkg", "handlers"} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{
+		"fastly.toml",
+		"go.mod",
+		"go.sum",
+		"main.go",
+		filepath.Join("handlers", "api.go"),
+		filepath.Join("bin", "main.wasm"),
+		filepath.Join("pkg", "package.tar.gz"),
+	} {
+		if err := ioutil.WriteFile(f, []byte("content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	language := &Language{Name: "go", SourceDirectory: ".", IncludeFiles: []string{"go.mod"}}
+
+	files, err := packageFiles(language, true)
+	testutil.AssertNoError(t, err)
+	var have []string
+	for _, f := range files {
+		have = append(have, filepath.ToSlash(f))
+	}
+	testutil.AssertEqual(t, []string{"fastly.toml", "go.mod", "bin/main.wasm", "go.sum", "handlers/api.go", "main.go"}, have)
+
+	testutil.AssertEqual(t, []string{IgnoreFilePath, "."}, watchPaths(language))
+}
+
+func TestIsBuildOutput(t *testing.T) {
+	for _, testcase := range []struct {
+		input      string
+		wantOutput bool
+	}{
+		{input: "main.go", wantOutput: false},
+		{input: filepath.Join("handlers", "api.go"), wantOutput: false},
+		{input: filepath.Join("bin", "main.wasm"), wantOutput: true},
+		{input: filepath.Join("pkg", "package.tar.gz"), wantOutput: true},
+		{input: filepath.Join(".git", "HEAD"), wantOutput: true},
+	} {
+		t.Run(testcase.input, func(t *testing.T) {
+			testutil.AssertEqual(t, testcase.wantOutput, isBuildOutput(testcase.input))
+		})
+	}
+}
+
+func TestLocalBackends(t *testing.T) {
+	for _, testcase := range []struct {
+		name         string
+		inputFile    manifest.File
+		wantBackends map[string]string
+		wantError    string
+	}{
+		{
+			name:         "no local server",
+			inputFile:    manifest.File{},
+			wantBackends: map[string]string{},
+		},
+		{
+			name: "valid backends",
+			inputFile: manifest.File{
+				LocalServer: &manifest.LocalServer{
+					Backends: map[string]manifest.LocalBackend{
+						"origin": {URL: "http://127.0.0.1:8080"},
+						"api":    {URL: "https://localhost:8443/v1"},
+					},
+				},
+			},
+			wantBackends: map[string]string{
+				"origin": "http://127.0.0.1:8080",
+				"api":    "https://localhost:8443/v1",
+			},
+		},
+		{
+			name: "invalid url",
+			inputFile: manifest.File{
+				LocalServer: &manifest.LocalServer{
+					Backends: map[string]manifest.LocalBackend{
+						"origin": {URL: "127.0.0.1:8080"},
+					},
+				},
+			},
+			wantError: "invalid URL \"127.0.0.1:8080\" for local backend origin",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			backends, err := localBackends(testcase.inputFile)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err == nil {
+				testutil.AssertEqual(t, testcase.wantBackends, backends)
+			}
+		})
+	}
+}
+
+func TestNewCustomLanguage(t *testing.T) {
+	languages := newLanguages(toolchainOptions{})
+	for _, testcase := range []struct {
+		name            string
+		lang            string
+		wantName        string
+		wantSourceDir   string
+		wantIncludeFile []string
+	}{
+		{
+			name:          "no language",
+			wantName:      "custom",
+			wantSourceDir: "src",
+		},
+		{
+			name:            "supported language",
+			lang:            "assemblyscript",
+			wantName:        "assemblyscript",
+			wantSourceDir:   "assembly",
+			wantIncludeFile: []string{"package.json"},
+		},
+		{
+			name:          "unsupported language",
+			lang:          "zig",
+			wantName:      "zig",
+			wantSourceDir: "src",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			l := newCustomLanguage(languages, testcase.lang, "make")
+			testutil.AssertString(t, testcase.wantName, l.Name)
+			testutil.AssertString(t, testcase.wantSourceDir, l.SourceDirectory)
+			testutil.AssertEqual(t, testcase.wantIncludeFile, l.IncludeFiles)
+			custom, ok := l.Toolchain.(*Custom)
+			if !ok {
+				t.Fatalf("want *Custom toolchain, have %T", l.Toolchain)
+			}
+			testutil.AssertString(t, "make", custom.script)
+		})
+	}
+}
+
+func TestReadPackageWasm(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-package-wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	if err := os.MkdirAll("bin", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("fastly.toml", []byte("name = \"package\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("bin", "main.wasm"), testWasmModule, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name      string
+		files     []string
+		wantPath  string
+		wantError string
+	}{
+		{
+			name:     "success",
+			files:    []string{"fastly.toml", filepath.Join("bin", "main.wasm")},
+			wantPath: "bin/main.wasm",
+		},
+		{
+			name:      "missing wasm",
+			files:     []string{"fastly.toml"},
+			wantError: "package must contain a main.wasm file",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			dest := filepath.Join("pkg", testcase.name, "package.tar.gz")
+			if err := createPackageArchive(".", testcase.files, dest); err != nil {
+				t.Fatal(err)
+			}
+
+			path, data, err := readPackageWasm(dest)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err == nil {
+				testutil.AssertString(t, testcase.wantPath, path)
+				testutil.AssertEqual(t, testWasmModule, data)
+			}
+		})
+	}
+}
+
+func TestPackageMetadata(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	files := []string{"fastly.toml", filepath.Join("bin", "main.wasm")}
+	if err := os.MkdirAll("bin", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(files[0], []byte("name = \"package\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(files[1], testWasmModule, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var sums []string
+	for _, dest := range []string{"pkg/first/package.tar.gz", "pkg/second/package.tar.gz"} {
+		if err := createPackageArchive(".", files, dest); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := validate(dest, defaultValidateLimits); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := getPackageHashSum(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums = append(sums, sum)
+	}
+	testutil.AssertString(t, sums[0], sums[1])
+
+	metadata, err := newPackageMetadata(".", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEqual(t, []string{"bin/main.wasm", "fastly.toml"}, sortedKeys(metadata.Files))
+
+	for _, testcase := range []struct {
+		name      string
+		hashes    map[string]string
+		hash      string
+		wantError string
+	}{
+		{
+			name:   "valid",
+			hashes: metadata.Files,
+			hash:   metadata.Hash,
+		},
+		{
+			name:      "modified file",
+			hashes:    map[string]string{"fastly.toml": metadata.Files["fastly.toml"], "bin/main.wasm": "abc"},
+			hash:      metadata.Hash,
+			wantError: "bin/main.wasm: hash does not match the package metadata",
+		},
+		{
+			name:      "extra file",
+			hashes:    map[string]string{"fastly.toml": metadata.Files["fastly.toml"], "bin/main.wasm": metadata.Files["bin/main.wasm"], "src/main.rs": "abc"},
+			hash:      metadata.Hash,
+			wantError: "src/main.rs: file is not listed in the package metadata",
+		},
+		{
+			name:      "missing file",
+			hashes:    map[string]string{"fastly.toml": metadata.Files["fastly.toml"]},
+			hash:      metadata.Hash,
+			wantError: "bin/main.wasm: file is listed in the package metadata but missing from the package",
+		},
+		{
+			name:      "package hash",
+			hashes:    metadata.Files,
+			hash:      "abc",
+			wantError: "package-metadata.json: package hash does not match the package metadata",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			problems := validateHashes(PackageMetadata{Hash: testcase.hash, Files: metadata.Files}, testcase.hashes)
+			var have []string
+			for _, p := range problems {
+				have = append(have, fmt.Sprintf("%s: %s", p.File, p.Message))
+			}
+			if testcase.wantError == "" {
+				testutil.AssertEqual(t, []string(nil), have)
+			} else {
+				testutil.AssertEqual(t, []string{testcase.wantError}, have)
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidateManifest(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "valid",
+			manifest: "name = \"test\"\nlanguage = \"rust\"\nservice_id = \"123\"\n",
+		},
+		{
+			name:     "invalid TOML",
+			manifest: "name = test",
+			want:     []string{"invalid manifest:"},
+		},
+		{
+			name:     "invalid type",
+			manifest: "name = \"test\"\nversion = \"one\"\n",
+			want:     []string{"invalid manifest:"},
+		},
+		{
+			name:     "every problem",
+			manifest: "language = \"cobol\"\nservcie_id = \"123\"\n\n[local_server.backends.origin]\nurl = \"127.0.0.1\"\n",
+			want: []string{
+				`unknown key "servcie_id"`,
+				"name is required",
+				"unsupported language cobol",
+				`invalid URL "127.0.0.1" for local backend origin`,
+			},
+		},
+		{
+			name:     "custom build script",
+			manifest: "name = \"test\"\nlanguage = \"cobol\"\n\n[scripts]\nbuild = \"make\"\n",
+		},
+		{
+			name:     "language section",
+			manifest: "name = \"test\"\n\n[language.rust]\ntoolchain = \"1.46.0\"\n",
+		},
+		{
+			name:     "unsupported language section",
+			manifest: "name = \"test\"\n\n[language.cobol]\n",
+			want:     []string{"unsupported language cobol"},
+		},
+		{
+			name:     "multiple language sections",
+			manifest: "name = \"test\"\n\n[language.rust]\n\n[language.go]\n",
+			want:     []string{"language must be a name or a single [language.<name>] section"},
+		},
+		{
+			name:     "invalid language section",
+			manifest: "name = \"test\"\n\n[language.rust]\ntoolchian = \"1.46.0\"\n",
+			want:     []string{`unknown key "toolchian" in language.rust`},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			problems := validateManifest(ManifestFilename, []byte(testcase.manifest))
+			testutil.AssertEqual(t, len(testcase.want), len(problems))
+			for i, p := range problems {
+				testutil.AssertString(t, ManifestFilename, p.File)
+				testutil.AssertStringContains(t, p.Message, testcase.want[i])
+				testutil.AssertBool(t, strings.HasPrefix(p.Message, "unknown key \"servcie_id\""), p.Warning)
+			}
+		})
+	}
+}
+
+func TestValidateWasm(t *testing.T) {
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, testcase := range []struct {
+		name string
+		wasm []byte
+		want []string
+	}{
+		{
+			name: "valid",
+			wasm: testWasmModule,
+		},
+		{
+			name: "malformed",
+			wasm: []byte("not wasm"),
+			want: []string{"malformed Wasm module: missing Wasm magic number"},
+		},
+		{
+			name: "invalid function body",
+			wasm: append(append([]byte{}, testWasmModule[:len(testWasmModule)-6]...),
+				0x0a, 0x06, 0x01, 0x04, 0x00, 0x41, 0x00, 0x0b,
+			),
+			want: []string{"invalid Wasm module:"},
+		},
+		{
+			name: "every problem",
+			wasm: append(append([]byte{}, preamble...),
+				0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+				0x02, 0x0d, 0x01, 0x03, 'e', 'n', 'v', 0x05, 'a', 'b', 'o', 'r', 't', 0x00, 0x00,
+				0x03, 0x02, 0x01, 0x00,
+				0x07, 0x08, 0x01, 0x04, 'm', 'a', 'i', 'n', 0x00, 0x01,
+				0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b,
+			),
+			want: []string{
+				"module does not export a _start function",
+				`module imports abort from unsupported module "env"`,
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			problems := validateWasm("bin/main.wasm", testcase.wasm)
+			testutil.AssertEqual(t, len(testcase.want), len(problems))
+			for i, p := range problems {
+				testutil.AssertString(t, "bin/main.wasm", p.File)
+				testutil.AssertStringContains(t, p.Message, testcase.want[i])
+			}
+		})
+	}
+}
+
+// testWasmModule is a minimal valid package Wasm binary, which exports an
+// empty _start function.
+var testWasmModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+	0x03, 0x02, 0x01, 0x00,
+	0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00,
+	0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b,
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	for _, dir := range []string{"bin", "src", filepath.Join("src", "tmp")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{
+		"fastly.toml",
+		"README.md",
+		IgnoreFilePath,
+		filepath.Join("src", "main.rs"),
+		filepath.Join("src", "main.rs.swp"),
+		filepath.Join("bin", "main.wasm"),
+		filepath.Join("src", "tmp", "scratch.rs"),
+	} {
+		if err := ioutil.WriteFile(f, []byte("content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(IgnoreFilePath, []byte("*.swp\ntmp/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := watchPaths(&Language{Name: "rust", SourceDirectory: "src", IncludeFiles: []string{"Cargo.toml"}})
+	testutil.AssertEqual(t, []string{"fastly.toml", IgnoreFilePath, "Cargo.toml", "src"}, paths)
+
+	before, err := snapshotFiles(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have []string
+	for path := range before {
+		have = append(have, filepath.ToSlash(path))
+	}
+	sort.Strings(have)
+	testutil.AssertEqual(t, []string{IgnoreFilePath, "fastly.toml", "src/main.rs"}, have)
+
+	for _, testcase := range []struct {
+		name   string
+		modify func() error
+		want   bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func() error { return nil },
+			want:   true,
+		},
+		{
+			name: "ignored file modified",
+			modify: func() error {
+				return ioutil.WriteFile(filepath.Join("src", "main.rs.swp"), []byte("changed content"), 0600)
+			},
+			want: true,
+		},
+		{
+			name: "build output modified",
+			modify: func() error {
+				return ioutil.WriteFile(filepath.Join("bin", "main.wasm"), []byte("changed content"), 0600)
+			},
+			want: true,
+		},
+		{
+			name: "source file modified",
+			modify: func() error {
+				return ioutil.WriteFile(filepath.Join("src", "main.rs"), []byte("changed content"), 0600)
+			},
+		},
+		{
+			name: "source file added",
+			modify: func() error {
+				return ioutil.WriteFile(filepath.Join("src", "lib.rs"), []byte("content"), 0600)
+			},
+		},
+		{
+			name: "include file added",
+			modify: func() error {
+				return ioutil.WriteFile("Cargo.toml", []byte("content"), 0600)
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if err := testcase.modify(); err != nil {
+				t.Fatal(err)
+			}
+			after, err := snapshotFiles(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertBool(t, testcase.want, sameFiles(before, after))
+			before = after
+		})
+	}
+}
+
+func TestWatchPreBuild(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	if err := os.MkdirAll("src", 0700); err != nil {
+		t.Fatal(err)
+	}
+	// The pre_build script writes under src/, which is watched, on every build.
+	manifest := "name = \"test\"\n\n[scripts]\nbuild = \"echo wasm > bin/main.wasm\"\npre_build = \"echo generated >> src/generated.txt\"\n"
+	if err := ioutil.WriteFile(ManifestFilename, []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &BuildCommand{cache: true}
+	c.Globals = &config.Data{}
+
+	var buf strings.Builder
+	stop := make(chan os.Signal)
+	done := make(chan error)
+	go func() { done <- c.watchUntil(stop, nil, &buf) }()
+
+	// Long enough for several polls, each of which would see the file written
+	// by the last build as a change if it weren't snapshotted after the build.
+	time.Sleep(10 * watchPollInterval)
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.AssertEqual(t, 1, strings.Count(buf.String(), "Build succeeded"))
+}
+
+func TestRustBuildFingerprint(t *testing.T) {
+	if _, err := exec.LookPath("cargo"); err != nil {
+		t.Skip("cargo not found")
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpdir, err := ioutil.TempDir("", "fastly-fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// The package is a cargo workspace with a build script and a member, and
+	// has a path dependency outside of the package directory.
+	rootdir := filepath.Join(tmpdir, "package")
+	for filename, content := range map[string]string{
+		filepath.Join(rootdir, ManifestFilename):              "name = \"test\"\nlanguage = \"rust\"\n",
+		filepath.Join(rootdir, "Cargo.toml"):                  "[package]\nname = \"test\"\nversion = \"0.1.0\"\n\n[dependencies]\nshared = { path = \"../shared\" }\n\n[workspace]\nmembers = [\"member\"]\n",
+		filepath.Join(rootdir, "build.rs"):                    "fn main() {}\n",
+		filepath.Join(rootdir, "src", "main.rs"):              "fn main() {}\n",
+		filepath.Join(rootdir, "member", "Cargo.toml"):        "[package]\nname = \"member\"\nversion = \"0.1.0\"\n",
+		filepath.Join(rootdir, "member", "src", "lib.rs"):     "pub fn member() {}\n",
+		filepath.Join(rootdir, "target", "debug", "test.d"):   "output",
+		filepath.Join(rootdir, ".cargo", "config.toml"):       "[build]\n",
+		filepath.Join(tmpdir, "shared", "Cargo.toml"):         "[package]\nname = \"shared\"\nversion = \"0.1.0\"\n",
+		filepath.Join(tmpdir, "shared", "src", "lib.rs"):      "pub fn shared() {}\n",
+		filepath.Join(tmpdir, "unrelated", "src", "lib.rs"):   "pub fn unrelated() {}\n",
+		filepath.Join(rootdir, "member", "src", "ignored.rs"): "",
+		filepath.Join(rootdir, IgnoreFilePath):                "member/src/ignored.rs\n",
+		filepath.Join(rootdir, "bin", "main.wasm"):            "wasm",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Chdir(rootdir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(pwd)
+
+	language := &Language{Name: "rust", SourceDirectory: "src", IncludeFiles: []string{"Cargo.toml"}, Toolchain: &Rust{}}
+	before, err := buildFingerprint(language, "rustc 1.43.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testcase := range []struct {
+		name     string
+		filename string
+		want     bool
+	}{
+		{
+			name: "unchanged",
+			want: true,
+		},
+		{
+			name:     "build script changed",
+			filename: "build.rs",
+		},
+		{
+			name:     "workspace member changed",
+			filename: filepath.Join("member", "src", "lib.rs"),
+		},
+		{
+			name:     "path dependency changed",
+			filename: filepath.Join("..", "shared", "src", "lib.rs"),
+		},
+		{
+			name:     "cargo configuration changed",
+			filename: filepath.Join(".cargo", "config.toml"),
+		},
+		{
+			name:     "target directory changed",
+			filename: filepath.Join("target", "debug", "test.d"),
+			want:     true,
+		},
+		{
+			name:     "ignored file changed",
+			filename: filepath.Join("member", "src", "ignored.rs"),
+			want:     true,
+		},
+		{
+			name:     "unrelated file changed",
+			filename: filepath.Join("..", "unrelated", "src", "lib.rs"),
+			want:     true,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if testcase.filename != "" {
+				f, err := os.OpenFile(testcase.filename, os.O_APPEND|os.O_WRONLY, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fmt.Fprintln(f)
+				if err := f.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			after, err := buildFingerprint(language, "rustc 1.43.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertBool(t, testcase.want, before == after)
+			before = after
+		})
+	}
+}
+
+func makeBuildEnvironment(t *testing.T, fastlyIgnoreContent string) (rootdir string) {
+	t.Helper()
+
+	p := make([]byte, 8)
+	n, err := rand.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir = filepath.Join(
+		os.TempDir(),
+		fmt.Sprintf("fastly-build-%x", p[:n]),
+	)
+
+	if err := os.MkdirAll(rootdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range [][]string{
+		{"Cargo.toml"},
+		{"Cargo.lock"},
+		{"src", "main.rs"},
+	} {
+		fromFilename := filepath.Join("testdata", "build", filepath.Join(filename...))
+		toFilename := filepath.Join(rootdir, filepath.Join(filename...))
+		if err := common.CopyFile(fromFilename, toFilename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fastlyIgnoreContent != "" {
+		filename := filepath.Join(rootdir, IgnoreFilePath)
+		if err := ioutil.WriteFile(filename, []byte(fastlyIgnoreContent), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rootdir
+}
+
+var errTest = errors.New("fixture error")
+
+type errorClient struct {
+	err error
+}
+
+func (c errorClient) Do(*http.Request) (*http.Response, error) {
 	return nil, c.err
 }
 
@@ -512,3 +1869,635 @@ func (v versionClient) Do(*http.Request) (*http.Response, error) {
 	}
 	return rec.Result(), nil
 }
+
+func TestManifestEnv(t *testing.T) {
+	file := manifest.File{
+		Name:      "package",
+		ServiceID: "default",
+		Env: map[string]manifest.Env{
+			"staging": {ServiceID: "staging"},
+			"preview": {},
+		},
+	}
+
+	for _, testcase := range []struct {
+		name       string
+		flag       manifest.Flag
+		wantID     string
+		wantSource manifest.Source
+	}{
+		{
+			name:       "no environment",
+			wantID:     "default",
+			wantSource: manifest.SourceFile,
+		},
+		{
+			name:       "environment",
+			flag:       manifest.Flag{Env: "staging"},
+			wantID:     "staging",
+			wantSource: manifest.SourceFile,
+		},
+		{
+			name:       "environment without service ID",
+			flag:       manifest.Flag{Env: "preview"},
+			wantSource: manifest.SourceUndefined,
+		},
+		{
+			name:       "unknown environment",
+			flag:       manifest.Flag{Env: "production"},
+			wantSource: manifest.SourceUndefined,
+		},
+		{
+			name:       "flag overrides environment",
+			flag:       manifest.Flag{ServiceID: "flag", Env: "staging"},
+			wantID:     "flag",
+			wantSource: manifest.SourceFlag,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			d := manifest.Data{File: file, Flag: testcase.flag}
+			id, source := d.ServiceID()
+			testutil.AssertString(t, testcase.wantID, id)
+			if source != testcase.wantSource {
+				t.Errorf("want source %v, have %v", testcase.wantSource, source)
+			}
+		})
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+	filename := filepath.Join(rootdir, manifest.Filename)
+
+	d := manifest.Data{File: manifest.File{Name: "package", ServiceID: "default"}, Flag: manifest.Flag{Env: "staging"}}
+	d.SetServiceID("staging")
+	if err := d.File.Write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var m manifest.File
+	if err := m.Read(filename); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertString(t, "default", m.ServiceID)
+	testutil.AssertEqual(t, map[string]manifest.Env{"staging": {ServiceID: "staging"}}, m.Env)
+}
+
+func TestManifestLanguage(t *testing.T) {
+	rootdir, err := ioutil.TempDir("", "fastly-language")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+	filename := filepath.Join(rootdir, manifest.Filename)
+
+	for _, testcase := range []struct {
+		name       string
+		manifest   string
+		wantName   string
+		wantConfig map[string]interface{}
+		wantError  string
+	}{
+		{
+			name:     "name",
+			manifest: "name = \"package\"\nlanguage = \"rust\"\n",
+			wantName: "rust",
+		},
+		{
+			name:       "section",
+			manifest:   "name = \"package\"\n\n[language.rust]\ntoolchain = \"1.46.0\"\n",
+			wantName:   "rust",
+			wantConfig: map[string]interface{}{"toolchain": "1.46.0"},
+		},
+		{
+			name:     "unset",
+			manifest: "name = \"package\"\n",
+		},
+		{
+			name:      "not a section",
+			manifest:  "name = \"package\"\n\n[language]\nrust = \"1.46.0\"\n",
+			wantError: "language.rust must be a table",
+		},
+		{
+			name:      "invalid type",
+			manifest:  "name = \"package\"\nlanguage = 1\n",
+			wantError: "language must be a name or a single [language.<name>] section",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(filename, []byte(testcase.manifest), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var m manifest.File
+			err := m.Read(filename)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err != nil {
+				return
+			}
+			testutil.AssertString(t, testcase.wantName, m.Language)
+			testutil.AssertEqual(t, testcase.wantConfig, m.LanguageConfig)
+
+			// The language is written back in the same form.
+			m.ServiceID = "123"
+			if err := m.Write(filename); err != nil {
+				t.Fatal(err)
+			}
+			var written manifest.File
+			if err := written.Read(filename); err != nil {
+				t.Fatal(err)
+			}
+			testutil.AssertString(t, testcase.wantName, written.Language)
+			testutil.AssertEqual(t, testcase.wantConfig, written.LanguageConfig)
+			testutil.AssertString(t, "123", written.ServiceID)
+		})
+	}
+}
+
+func TestVerifyDeployment(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, "Welcome to Compute@Edge")
+	}))
+	defer ts.Close()
+
+	for _, testcase := range []struct {
+		name         string
+		opts         verifyOptions
+		stats        fakeRealtimeStats
+		wantFailures []string
+	}{
+		{
+			name:  "success",
+			opts:  verifyOptions{url: ts.URL, status: 200, body: "Welcome", maxErrorRate: 0.05},
+			stats: fakeRealtimeStats{requests: 10, errors: 0},
+		},
+		{
+			name:         "unexpected status",
+			opts:         verifyOptions{url: ts.URL + "/missing", status: 200, maxErrorRate: 0.05},
+			wantFailures: []string{"/missing responded with status 404, expected 200"},
+		},
+		{
+			name:         "unexpected body",
+			opts:         verifyOptions{url: ts.URL, status: 200, body: "Goodbye", maxErrorRate: 0.05},
+			wantFailures: []string{`responded without "Goodbye" in the body`},
+		},
+		{
+			name:         "error rate",
+			opts:         verifyOptions{url: ts.URL, status: 200, maxErrorRate: 0.05},
+			stats:        fakeRealtimeStats{requests: 10, errors: 5},
+			wantFailures: []string{"5xx error rate 50.0% exceeds the maximum of 5.0%"},
+		},
+		{
+			name:  "delay",
+			opts:  verifyOptions{url: ts.URL, status: 200, maxErrorRate: 0.05, delay: 100 * time.Millisecond},
+			stats: fakeRealtimeStats{requests: 10, errors: 0},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			opts := testcase.opts
+			opts.duration = 200 * time.Millisecond
+			opts.probeInterval = 50 * time.Millisecond
+
+			stats := testcase.stats
+			start := time.Now()
+			report := verifyDeployment(http.DefaultClient, &stats, "123", opts, ioutil.Discard)
+			if elapsed := time.Since(start); elapsed < opts.delay {
+				t.Errorf("want verification to wait for the delay, took %s", elapsed)
+			}
+			if len(report.failures) != len(testcase.wantFailures) {
+				t.Fatalf("want %d failures, have %v", len(testcase.wantFailures), report.failures)
+			}
+			for i, want := range testcase.wantFailures {
+				testutil.AssertStringContains(t, report.failures[i], want)
+			}
+			if !report.failed() && report.probes < 2 {
+				t.Errorf("want probes until the duration passed, have %d", report.probes)
+			}
+		})
+	}
+}
+
+// fakeRealtimeStats reports the same number of requests and 5xx responses in
+// every second of realtime stats.
+type fakeRealtimeStats struct {
+	requests  uint64
+	errors    uint64
+	timestamp uint64
+}
+
+func (s *fakeRealtimeStats) GetRealtimeStatsJSON(i *fastly.GetRealtimeStatsInput, dst interface{}) error {
+	time.Sleep(5 * time.Millisecond)
+	s.timestamp++
+	data, err := json.Marshal(map[string]interface{}{
+		"timestamp": s.timestamp,
+		"data": []map[string]interface{}{
+			{"aggregated": map[string]uint64{"requests": s.requests, "status_5xx": s.errors}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func TestStripWasmBinary(t *testing.T) {
+	original, err := ioutil.ReadFile(filepath.Join("testdata", "inspect", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-strip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	binary := filepath.Join(rootdir, "main.wasm")
+	if err := ioutil.WriteFile(binary, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	debugPath := filepath.Join(rootdir, "pkg", "package.debug.wasm")
+
+	before, after, err := stripWasmBinary(binary, debugPath)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, len(original), before)
+	testutil.AssertEqual(t, 118, after)
+
+	stripped, err := ioutil.ReadFile(binary)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, after, len(stripped))
+	if problems := validateWasm("main.wasm", stripped); len(problems) > 0 {
+		t.Errorf("stripped binary is invalid: %v", problems)
+	}
+
+	debug, err := ioutil.ReadFile(debugPath)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, original, debug)
+
+	// Stripping again leaves the debug binary alone.
+	before, after, err = stripWasmBinary(binary, debugPath)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, 118, before)
+	testutil.AssertEqual(t, 118, after)
+	debug, err = ioutil.ReadFile(debugPath)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, original, debug)
+
+	_, _, err = stripWasmBinary(filepath.Join(rootdir, "missing.wasm"), "")
+	testutil.AssertErrorContains(t, err, "error reading Wasm binary")
+}
+
+func TestVersionComment(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	rootdir, err := ioutil.TempDir("", "fastly-comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootdir)
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = rootdir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	timestamp := time.Date(2020, time.September, 1, 12, 30, 0, 0, time.UTC)
+	data := versionCommentData{
+		PackageHash: "0123456789abcdef0123456789abcdef",
+		CLIVersion:  "v0.20.0",
+		Timestamp:   timestamp,
+	}
+
+	for _, testcase := range []struct {
+		name     string
+		setup    func()
+		template string
+		want     string
+	}{
+		{
+			name: "not a repository",
+			want: "Deployed package 0123456789abcdef with Fastly CLI v0.20.0 at 2020-09-01T12:30:00Z",
+		},
+		{
+			name: "clean",
+			setup: func() {
+				git("init", "-q")
+				git("checkout", "-q", "-b", "main")
+				if err := ioutil.WriteFile(filepath.Join(rootdir, "fastly.toml"), []byte("name = \"package\"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				git("add", ".")
+				git("commit", "-q", "-m", "Initial commit")
+			},
+			template: "{{.Branch}} {{.Dirty}} {{len .Commit}}",
+			want:     "main false 40",
+		},
+		{
+			name: "dirty",
+			setup: func() {
+				if err := ioutil.WriteFile(filepath.Join(rootdir, "fastly.toml"), []byte("name = \"changed\"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			template: "{{.Branch}} {{.Dirty}}",
+			want:     "main true",
+		},
+		{
+			name:     "detached",
+			setup:    func() { git("checkout", "-q", "--detach") },
+			template: "{{if .Branch}}{{.Branch}}{{else}}detached{{end}}",
+			want:     "detached",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			if testcase.setup != nil {
+				testcase.setup()
+			}
+
+			tmpl, err := parseVersionCommentTemplate(testcase.template)
+			testutil.AssertNoError(t, err)
+
+			d := data
+			d.readGitState(rootdir)
+			comment, err := versionComment(tmpl, d)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, testcase.want, comment)
+		})
+	}
+
+	_, err = parseVersionCommentTemplate("{{.Commit")
+	testutil.AssertErrorContains(t, err, "error parsing version comment template")
+
+	tmpl, err := parseVersionCommentTemplate("{{.Unknown}}")
+	testutil.AssertNoError(t, err)
+	_, err = versionComment(tmpl, data)
+	testutil.AssertErrorContains(t, err, "error rendering version comment template")
+}
+
+func TestFrameOffset(t *testing.T) {
+	for _, testcase := range []struct {
+		line       string
+		wantOffset int
+		wantOK     bool
+	}{
+		{line: "    0:   0x65 - <unknown>!<wasm function 1>", wantOffset: 0x65, wantOK: true},
+		{line: "   12: 0x1A2B - app::handle::h0123456789abcdef", wantOffset: 0x1a2b, wantOK: true},
+		{line: "    at wasm://wasm/1e2b3c4d:wasm-function[2]:0x87", wantOffset: 0x87, wantOK: true},
+		{line: "wasm backtrace:"},
+		{line: "panicked at 0x65: index out of bounds"},
+		{line: "    0: 0x100000000 - <unknown>!<wasm function 1>"},
+	} {
+		t.Run(testcase.line, func(t *testing.T) {
+			offset, ok := frameOffset(testcase.line)
+			testutil.AssertBool(t, testcase.wantOK, ok)
+			testutil.AssertEqual(t, testcase.wantOffset, offset)
+		})
+	}
+}
+
+func TestSymbolizer(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "symbolicate", "main.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSymbolizer(b)
+	testutil.AssertNoError(t, err)
+
+	for _, testcase := range []struct {
+		name    string
+		offset  int
+		want    frameLocation
+		wantOK  bool
+		wantStr string
+	}{
+		{
+			name:    "named by name section",
+			offset:  0x65,
+			want:    frameLocation{Function: "app::handle", File: "/app/src/main.rs", Line: 5, Column: 9},
+			wantOK:  true,
+			wantStr: "app::handle (/app/src/main.rs:5:9)",
+		},
+		{
+			name:    "generated code",
+			offset:  0x67,
+			want:    frameLocation{Function: "app::handle", File: "/app/src/main.rs", Column: 9},
+			wantOK:  true,
+			wantStr: "app::handle (/app/src/main.rs)",
+		},
+		{
+			name:    "named by DWARF",
+			offset:  0x80,
+			want:    frameLocation{Function: "_start", File: "/app/src/main.rs", Line: 12, Column: 5},
+			wantOK:  true,
+			wantStr: "_start (/app/src/main.rs:12:5)",
+		},
+		{
+			name:   "outside code section",
+			offset: 0x10,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			loc, ok := s.locate(testcase.offset)
+			testutil.AssertBool(t, testcase.wantOK, ok)
+			testutil.AssertEqual(t, testcase.want, loc)
+			if ok {
+				testutil.AssertString(t, testcase.wantStr, loc.String())
+			}
+		})
+	}
+
+	stripped, err := wasm.Strip(b)
+	testutil.AssertNoError(t, err)
+	_, err = newSymbolizer(stripped)
+	testutil.AssertBool(t, true, err == wasm.ErrNoDebugInfo)
+}
+
+func TestTestHarnesses(t *testing.T) {
+	for _, testcase := range []struct {
+		name        string
+		harness     TestHarness
+		outputs     map[string]string
+		wantCases   []TestCase
+		wantArgs    []string
+		wantSkipped bool
+		skipOutput  string
+	}{
+		{
+			name:    "libtest",
+			harness: libtestHarness{},
+			outputs: map[string]string{
+				"--list --format terse":           "tests::it_works: test\ntests::slow: test\nbenches::fast: bench\n",
+				"--list --ignored --format terse": "tests::slow: test\n",
+			},
+			wantCases: []TestCase{
+				{Name: "tests::it_works"},
+				{Name: "tests::slow", Ignored: true},
+			},
+			wantArgs: []string{"tests::it_works", "--exact", "--nocapture", "--test-threads", "1"},
+		},
+		{
+			name:    "go test",
+			harness: goTestHarness{},
+			outputs: map[string]string{
+				"-test.list .": "TestHandler\nBenchmarkHandler\nFuzzHandler\nExampleHandler\n",
+			},
+			wantCases: []TestCase{
+				{Name: "TestHandler"},
+				{Name: "ExampleHandler"},
+			},
+			wantArgs:    []string{"-test.run", "^TestHandler$", "-test.v"},
+			wantSkipped: true,
+			skipOutput:  "=== RUN   TestHandler\n    main_test.go:9: no backend\n--- SKIP: TestHandler (0.00s)\nPASS\n",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			cases, err := testcase.harness.List(func(args ...string) (string, error) {
+				output, ok := testcase.outputs[strings.Join(args, " ")]
+				if !ok {
+					return "", fmt.Errorf("unexpected arguments %q", args)
+				}
+				return output, nil
+			})
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, testcase.wantCases, cases)
+			testutil.AssertEqual(t, testcase.wantArgs, testcase.harness.Args(cases[0].Name))
+			testutil.AssertBool(t, testcase.wantSkipped, testcase.harness.Skipped(cases[0].Name, testcase.skipOutput))
+			testutil.AssertBool(t, false, testcase.harness.Skipped(cases[0].Name, "--- SKIP: "+cases[0].Name+"Other (0.00s)\n"))
+		})
+	}
+}
+
+func TestTestReports(t *testing.T) {
+	results := []testResult{
+		{Binary: "edge-app (bin)", Name: "tests::it_works", Status: testPassed, Duration: 10 * time.Millisecond, Output: "ok\n"},
+		{Binary: "edge-app (bin)", Name: "tests::it_fails", Status: testFailed, Message: "exit code 101", Duration: 20 * time.Millisecond, Output: "assertion failed <left> & <right>\n"},
+		{Binary: "lib (lib)", Name: "tests::slow", Status: testSkipped},
+	}
+
+	passed, failed, skipped := countTestResults(results)
+	testutil.AssertEqual(t, []int{1, 1, 1}, []int{passed, failed, skipped})
+
+	var buf strings.Builder
+	writeTextReport(&buf, results)
+	testutil.AssertString(t, strings.Join([]string{
+		"",
+		"edge-app (bin)",
+		"    PASS  tests::it_works (0.01s)",
+		"    FAIL  tests::it_fails (0.02s)",
+		"        assertion failed <left> & <right>",
+		"        exit code 101",
+		"",
+		"lib (lib)",
+		"    SKIP  tests::slow",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	testutil.AssertNoError(t, writeJUnitReport(&buf, results))
+	testutil.AssertString(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" skipped="1" time="0.030">
+  <testsuite name="edge-app (bin)" tests="2" failures="1" skipped="0" time="0.030">
+    <testcase name="tests::it_works" classname="edge-app (bin)" time="0.010">
+      <system-out>ok&#xA;</system-out>
+    </testcase>
+    <testcase name="tests::it_fails" classname="edge-app (bin)" time="0.020">
+      <failure message="exit code 101"></failure>
+      <system-out>assertion failed &lt;left&gt; &amp; &lt;right&gt;&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="lib (lib)" tests="1" failures="0" skipped="1" time="0.000">
+    <testcase name="tests::slow" classname="lib (lib)" time="0.000">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+// TestRunTestBinary runs a Go test binary compiled for WASI, which stands in
+// for the test binaries built by the supported toolchains as Go can compile
+// to WASI without any other toolchains installed.
+func TestRunTestBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fastly-compute-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package edge
+
+import "testing"
+
+func TestPass(t *testing.T) { t.Log("passing") }
+
+func TestFail(t *testing.T) { t.Fatal("failing") }
+
+func TestSkip(t *testing.T) { t.Skip("skipping") }
+
+func TestPanic(t *testing.T) { panic("panicking") }
+
+func TestHang(t *testing.T) {
+	for {
+	}
+}
+`
+	for name, content := range map[string]string{
+		"go.mod":       "module edge\n\ngo 1.21\n",
+		"edge_test.go": src,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "edge.test.wasm")
+	cmd := exec.Command("go", "test", "-c", "-o", path, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOFLAGS=", "GOTOOLCHAIN=local")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("unable to compile Go tests for WASI: %v\n%s", err, output)
+	}
+
+	var out strings.Builder
+	results, err := runTestBinary(context.Background(), TestBinary{Name: "edge", Path: path, Harness: goTestHarness{}}, "", 2*time.Second, &out)
+	testutil.AssertNoError(t, err)
+
+	got := make(map[string]testStatus)
+	for _, r := range results {
+		testutil.AssertString(t, "edge", r.Binary)
+		got[r.Name] = r.Status
+		if r.Name == "TestFail" {
+			testutil.AssertString(t, "exit code 1", r.Message)
+			if !strings.Contains(r.Output, "failing") {
+				t.Errorf("wanted TestFail output to contain the failure, got %q", r.Output)
+			}
+		}
+		if r.Name == "TestHang" {
+			testutil.AssertString(t, "timed out after 2s", r.Message)
+		}
+	}
+	testutil.AssertEqual(t, map[string]testStatus{
+		"TestPass":  testPassed,
+		"TestFail":  testFailed,
+		"TestSkip":  testSkipped,
+		"TestPanic": testFailed,
+		"TestHang":  testFailed,
+	}, got)
+	testutil.AssertString(t, "Running TestPass...\nRunning TestFail...\nRunning TestSkip...\nRunning TestPanic...\nRunning TestHang...\n", out.String())
+
+	results, err = runTestBinary(context.Background(), TestBinary{Name: "edge", Path: path, Harness: goTestHarness{}}, "Pass", 2*time.Second, ioutil.Discard)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, 1, len(results))
+	testutil.AssertString(t, "TestPass", results[0].Name)
+}
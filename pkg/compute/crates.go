@@ -0,0 +1,116 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fastly/cli/pkg/api"
+	"github.com/fastly/cli/pkg/config"
+)
+
+// crateCacheTTL is how long a crate version looked up from crates.io is used
+// before it is looked up again.
+const crateCacheTTL = 24 * time.Hour
+
+// crateCacheFilePath is the location of the cache of crate versions looked up
+// from crates.io, which is kept alongside the CLI config file.
+var crateCacheFilePath = filepath.Join(filepath.Dir(config.FilePath), "crates.json")
+
+// crateCache models the cache of crate versions, keyed by the crates.io
+// endpoint and crate name.
+type crateCache map[string]cachedCrate
+
+// cachedCrate is the latest version of a crate and when it was looked up.
+type cachedCrate struct {
+	Version   string    `json:"version"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// crateRegistry looks up the latest versions of crates from the crates.io API,
+// caching the results in a file.
+type crateRegistry struct {
+	client    api.HTTPClient
+	endpoint  string
+	cacheFile string
+}
+
+// latestVersion returns the latest version of a crate. A cached version is
+// used if it was looked up within the TTL, and otherwise the version is
+// looked up and cached. If the look up fails, an expired cached version is
+// returned instead along with stale set to true.
+func (r crateRegistry) latestVersion(name string) (version *semver.Version, stale bool, err error) {
+	cache := r.readCache()
+	key := r.key(name)
+
+	cached, cachedErr := cache.version(key)
+	if cachedErr == nil && time.Since(cache[key].FetchedAt) < crateCacheTTL {
+		return cached, false, nil
+	}
+
+	version, err = getLatestCrateVersion(r.client, r.endpoint, name)
+	if err != nil {
+		if cachedErr == nil {
+			return cached, true, nil
+		}
+		return nil, false, err
+	}
+
+	cache[key] = cachedCrate{Version: version.String(), FetchedAt: time.Now().UTC()}
+	r.writeCache(cache) // a cache which can't be written is only a performance cost
+
+	return version, false, nil
+}
+
+// cachedVersion returns the cached latest version of a crate, regardless of
+// when it was looked up.
+func (r crateRegistry) cachedVersion(name string) (*semver.Version, error) {
+	return r.readCache().version(r.key(name))
+}
+
+func (r crateRegistry) key(name string) string {
+	return fmt.Sprintf("%s/%s", r.endpoint, name)
+}
+
+// readCache returns the contents of the cache file, which are empty if the
+// file doesn't exist or can't be read.
+func (r crateRegistry) readCache() crateCache {
+	cache := make(crateCache)
+	if r.cacheFile == "" {
+		return cache
+	}
+	data, err := ioutil.ReadFile(r.cacheFile)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return make(crateCache)
+	}
+	return cache
+}
+
+func (r crateRegistry) writeCache(cache crateCache) error {
+	if r.cacheFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.cacheFile), config.DirectoryPermissions); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.cacheFile, data, config.FilePermissions)
+}
+
+func (c crateCache) version(key string) (*semver.Version, error) {
+	cached, ok := c[key]
+	if !ok {
+		return nil, fmt.Errorf("no cached version")
+	}
+	return semver.NewVersion(cached.Version)
+}
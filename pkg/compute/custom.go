@@ -0,0 +1,128 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+// Custom implements Toolchain for packages which are built by a user-defined
+// script from the [scripts] section of the package manifest. It allows any
+// compiler which emits WASI compatible Wasm to be used.
+type Custom struct {
+	script string
+}
+
+// Verify implements the Toolchain interface and verifies whether the shell
+// used to run the build script is available on the host.
+func (c Custom) Verify(out io.Writer) error {
+	shell, _ := shellCommand()
+
+	fmt.Fprintf(out, "Checking if %s is installed...\n", shell)
+
+	p, err := exec.LookPath(shell)
+	if err != nil {
+		return fmt.Errorf("`%s` not found in $PATH", shell)
+	}
+
+	fmt.Fprintf(out, "Found %s at %s\n", shell, p)
+
+	return nil
+}
+
+// Build implements the Toolchain interface and runs the build script, which is
+// expected to write the package Wasm binary to bin/main.wasm.
+func (c Custom) Build(out io.Writer, verbose bool) error {
+	if err := createBinDirectory("bin"); err != nil {
+		return err
+	}
+
+	// The binary from a previous build is removed first, so that a build
+	// script which doesn't write one isn't mistaken for one which did.
+	wasm := filepath.Join("bin", "main.wasm")
+	if err := os.Remove(wasm); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing %s: %w", wasm, err)
+	}
+
+	fmt.Fprintf(out, "Running build script: %s\n", c.script)
+
+	if err := streamCommand(scriptCommand(c.script), out, verbose); err != nil {
+		return err
+	}
+
+	if !common.FileExists(wasm) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("build script did not produce %s", wasm),
+			Remediation: fmt.Sprintf("Update the build script in the [scripts] section of %s to write the Wasm binary to bin/main.wasm.", text.Bold(ManifestFilename)),
+		}
+	}
+
+	return nil
+}
+
+// BuildTests implements the Toolchain interface. The build script only builds
+// the package, so its tests can't be built.
+func (c Custom) BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error) {
+	return nil, errTestsUnsupported("a custom build script")
+}
+
+// Version implements the Toolchain interface. The version of the compiler run
+// by the build script isn't known, so it is an error.
+func (c Custom) Version() (string, error) {
+	return "", fmt.Errorf("the version of the compiler run by the build script isn't known")
+}
+
+// newCustomLanguage returns a Language which builds the package with the given
+// script. If name is a supported language its source directory and package
+// files are kept, otherwise the source is assumed to be in src.
+func newCustomLanguage(languages []*Language, name, script string) *Language {
+	l := &Language{
+		Name:            "custom",
+		DisplayName:     "Custom",
+		SourceDirectory: "src",
+		Toolchain:       &Custom{script},
+	}
+	if known, ok := getLanguage(languages, name); ok {
+		l.SourceDirectory = known.SourceDirectory
+		l.IncludeFiles = known.IncludeFiles
+	}
+	if name != "" {
+		l.Name = name
+	}
+	return l
+}
+
+// runScript runs a [scripts] hook such as pre_build, streaming its output.
+func runScript(out io.Writer, name, script string, verbose bool) error {
+	fmt.Fprintf(out, "Running %s script: %s\n", name, script)
+	if err := streamCommand(scriptCommand(script), out, verbose); err != nil {
+		return fmt.Errorf("error running %s script: %w", name, err)
+	}
+	return nil
+}
+
+// scriptCommand returns a command which runs script using the system shell.
+func scriptCommand(script string) *exec.Cmd {
+	shell, flag := shellCommand()
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the script is defined by the user in their package manifest.
+	/* #nosec */
+	return exec.Command(shell, flag, script)
+}
+
+// shellCommand returns the system shell and the flag it takes to run a
+// command string.
+func shellCommand() (string, string) {
+	if runtime.GOOS == "windows" {
+		return "cmd.exe", "/C"
+	}
+	return "sh", "-c"
+}
@@ -2,14 +2,18 @@ package compute
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
@@ -25,10 +29,18 @@ import (
 // DeployCommand deploys an artifact previously produced by build.
 type DeployCommand struct {
 	common.Base
-	client   api.HTTPClient
-	manifest manifest.Data
-	path     string
-	version  int
+	client     api.HTTPClient
+	manifest   manifest.Data
+	path       string
+	version    int
+	force      bool
+	domain     string
+	backend    string
+	verify     verifyOptions
+	upload     UploadOptions
+	comment    string
+	template   string
+	jsonEvents bool
 }
 
 // NewDeployCommand returns a usable command registered under the parent.
@@ -39,17 +51,92 @@ func NewDeployCommand(parent common.Registerer, client api.HTTPClient, globals *
 	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("deploy", "Deploy a package to a Fastly Compute@Edge service")
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of version to activate").IntVar(&c.version)
 	c.CmdClause.Flag("path", "Path to package").Short('p').StringVar(&c.path)
+	c.CmdClause.Flag("force", "Upload and activate the package even if it is unchanged, or if another deploy changed the service at the same time").BoolVar(&c.force)
+	c.CmdClause.Flag("max-package-size", "Maximum size of the package archive in bytes").Default(strconv.Itoa(DefaultMaxPackageSize)).Int64Var(&c.upload.MaxSize)
+	c.CmdClause.Flag("upload-retries", "Number of times to retry uploading the package after a transient failure").Default("3").IntVar(&c.upload.Retries)
+	c.CmdClause.Flag("domain", "The domain of a service created by the deploy, used when the package has no service ID").StringVar(&c.domain)
+	c.CmdClause.Flag("backend", "A hostname, IPv4, or IPv6 address for the backend of a service created by the deploy").StringVar(&c.backend)
+	c.CmdClause.Flag("comment", "Comment to set on the deployed version, which must be neither locked nor active, instead of one describing the package and its git commit").StringVar(&c.comment)
+	c.CmdClause.Flag("version-comment-template", "Go template of the comment set on the deployed version, with the fields .Commit, .Dirty, .Branch, .PackageHash, .CLIVersion and .Timestamp").StringVar(&c.template)
+	c.CmdClause.Flag("json-events", "Write progress as newline-delimited JSON events, for CI systems, instead of text").BoolVar(&c.jsonEvents)
+	c.CmdClause.Flag("verify-url", "URL to request after activating the version, rolling back to the previously active version if verification fails").StringVar(&c.verify.url)
+	c.CmdClause.Flag("verify-status", "Expected HTTP status of responses from the verification URL").Default("200").IntVar(&c.verify.status)
+	c.CmdClause.Flag("verify-body", "Text expected in the body of responses from the verification URL").StringVar(&c.verify.body)
+	c.CmdClause.Flag("verify-duration", "How long to verify the deployment for").Default("1m").DurationVar(&c.verify.duration)
+	c.CmdClause.Flag("verify-delay", "How long to wait after activating the version before verifying the deployment").Default("5s").DurationVar(&c.verify.delay)
+	c.CmdClause.Flag("verify-error-rate", "Maximum ratio of 5xx responses reported by realtime stats during verification").Default("0.05").Float64Var(&c.verify.maxErrorRate)
 	return &c
 }
 
 // Exec implements the command interface.
 func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
+	// A misspelt environment would otherwise be deployed to a new service.
+	if env := c.manifest.Flag.Env; env != "" {
+		if _, ok := c.manifest.File.Env[env]; !ok {
+			return errors.RemediationError{
+				Inner:       fmt.Errorf("error reading package manifest: environment %s not found", env),
+				Remediation: fmt.Sprintf("To fix this error, add an [env.%s] section to the package manifest, or choose an environment listed by:\n\n\t$ %s", env, text.Bold("fastly compute env list")),
+			}
+		}
+	}
+
+	// The comment template is parsed up front, so that a mistake in it is
+	// found before anything is deployed.
+	if c.comment != "" && c.template != "" {
+		return fmt.Errorf("--comment and --version-comment-template can't be used together")
+	}
+	tmpl, err := parseVersionCommentTemplate(c.template)
+	if err != nil {
+		return err
+	}
+
+	// A package without a service ID, such as one created by init
+	// --local-only, is deployed to a new service. Its domain and backend are
+	// gathered before the progress output starts, and aren't prompted for
+	// when writing JSON events.
+	interactive := !c.jsonEvents
+	serviceID, source := c.manifest.ServiceID()
+	newService := source == manifest.SourceUndefined
+	if newService {
+		if _, source := c.Globals.Token(); source == config.SourceUndefined {
+			return errors.ErrNoToken
+		}
+		if c.version != 0 {
+			return fmt.Errorf("--version can't be used when the package has no service ID, as a new service will be created")
+		}
+
+		if interactive && (c.domain == "" || c.backend == "") {
+			text.Output(out, "The package manifest has no service ID, so a new service will be created for the package.")
+			text.Break(out)
+		}
+		if c.domain == "" {
+			c.domain, err = inputDomain(in, out, interactive)
+			if err != nil {
+				return err
+			}
+		}
+		c.backend, err = inputBackend(in, out, c.backend, interactive)
+		if err != nil {
+			return err
+		}
+		if interactive {
+			text.Break(out)
+		}
+	}
+
+	// JSON events replace all of the text output, so the messages written
+	// once the deploy is done are written as events too.
 	var progress text.Progress
-	if c.Globals.Verbose() {
+	switch {
+	case c.jsonEvents:
+		progress = text.NewJSONProgress(out)
+		out = progress
+	case c.Globals.Verbose():
 		progress = text.NewVerboseProgress(out)
-	} else {
+	default:
 		progress = text.NewQuietProgress(out)
 	}
 
@@ -59,6 +146,9 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		}
 	}()
 
+	undoStack := common.NewUndoStack()
+	defer func() { undoStack.RunIfError(out, err) }()
+
 	// If path flag was empty, default to package tar inside pkg directory
 	// and get filename from the manifest.
 	if c.path == "" {
@@ -74,15 +164,55 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 
 	progress.Step("Validating package...")
 
-	if err := validate(c.path); err != nil {
+	limits := validateLimits{maxPackageSize: c.upload.MaxSize, maxWasmSize: DefaultMaxWasmSize}
+	warnings, err := validate(c.path, limits)
+	if err != nil {
 		return err
 	}
 
-	serviceID, source := c.manifest.ServiceID()
-	if source == manifest.SourceUndefined {
-		return fmt.Errorf("error reading service: no service ID found. Please provide one via the --service-id flag or within your package manifest")
+	if newService {
+		name, _ := c.manifest.Name()
+		if name == "" {
+			return fmt.Errorf("error creating service: the package manifest has no name")
+		}
+
+		service, err := createService(c.Globals.Client, name, c.manifest.File.Description, progress, undoStack)
+		if err != nil {
+			return err
+		}
+		serviceID = service.ID
+		c.version = 1
+
+		if err := createDomainAndBackend(c.Globals.Client, serviceID, c.version, c.domain, c.backend, progress, undoStack); err != nil {
+			return err
+		}
 	}
 
+	// The token is only required to compare and upload the package, so we
+	// defer erroring on its absence until the upload.
+	token, tokenSource := c.Globals.Token()
+	endpoint, _ := c.Globals.Endpoint()
+	client := NewClient(c.client, endpoint, token)
+
+	// The package of a new service is always uploaded.
+	var hashSum string
+	if !c.force && !newService && tokenSource != config.SourceUndefined {
+		hashSum, err = getPackageHashSum(c.path)
+		if err != nil {
+			return fmt.Errorf("error hashing package: %w", err)
+		}
+	}
+
+	// Only a version this deploy created or cloned is commented, as any other
+	// may be locked or already have a comment of its own, unless a comment was
+	// asked for with --comment or --version-comment-template.
+	var (
+		unchanged bool
+		base      *deployBase
+		editable  = newService
+		current   *fastly.Version
+		commented = c.comment != "" || c.template != ""
+	)
 	if c.version == 0 {
 		progress.Step("Fetching latest version...")
 		versions, err := c.Globals.Client.ListVersions(&fastly.ListVersionsInput{
@@ -97,7 +227,27 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 			return fmt.Errorf("error finding latest service version")
 		}
 
-		if version.Active || version.Locked {
+		if hashSum != "" {
+			progress.Step("Comparing package...")
+			unchanged = packageUnchanged(progress, client, serviceID, version.Number, hashSum)
+		}
+
+		if version.Active && unchanged {
+			text.Artifact(progress, "package", c.path)
+			text.Artifact(progress, "service_id", serviceID)
+			text.Artifact(progress, "version", version.Number)
+			progress.Done()
+			text.Break(out)
+			printWarnings(out, warnings)
+			text.Success(out, "Package unchanged, nothing to deploy (service %s, version %v)", serviceID, version.Number)
+			return nil
+		}
+
+		// The versions are recorded before cloning, so that a deploy which
+		// ran at the same time can be detected before activating.
+		base = newDeployBase(versions, version)
+
+		if (version.Active || version.Locked) && !unchanged {
 			progress.Step("Cloning latest version...")
 			version, err = c.Globals.Client.CloneVersion(&fastly.CloneVersionInput{
 				Service: serviceID,
@@ -106,20 +256,129 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 			if err != nil {
 				return fmt.Errorf("error cloning latest service version: %w", err)
 			}
+			editable = true
+		} else {
+			// The version is deployed rather than cloned, so the upload
+			// changes when it was last updated.
+			base.updatedAt = nil
+			current = version
 		}
 
 		c.version = version.Number
+	} else {
+		if commented {
+			progress.Step("Fetching version...")
+			versions, err := c.Globals.Client.ListVersions(&fastly.ListVersionsInput{
+				Service: serviceID,
+			})
+			if err != nil {
+				return fmt.Errorf("error listing service versions: %w", err)
+			}
+			for _, v := range versions {
+				if v.Number == c.version {
+					current = v
+				}
+			}
+			if current == nil {
+				return fmt.Errorf("error finding version %d of service %s", c.version, serviceID)
+			}
+		}
+
+		if hashSum != "" {
+			progress.Step("Comparing package...")
+			unchanged = packageUnchanged(progress, client, serviceID, c.version, hashSum)
+		}
 	}
 
-	progress.Step("Uploading package...")
-	token, s := c.Globals.Token()
-	if s == config.SourceUndefined {
-		return errors.ErrNoToken
+	// The comment asked for can only be set on a version which is neither
+	// locked nor active, so the deploy stops before uploading to any other.
+	if commented && !editable {
+		if current.Locked || current.Active {
+			return errors.RemediationError{
+				Inner:       fmt.Errorf("version %d is locked or active, so its comment can't be set", c.version),
+				Remediation: "Deploy to a version which is neither locked nor active, such as by omitting --version so that the latest version is cloned, or omit --comment and --version-comment-template.",
+			}
+		}
+		editable = true
 	}
-	endpoint, _ := c.Globals.Endpoint()
-	client := NewClient(c.client, endpoint, token)
-	if err := client.UpdatePackage(serviceID, c.version, c.path); err != nil {
-		return err
+
+	if unchanged {
+		progress.Step("Skipping upload of unchanged package...")
+	} else {
+		progress.Step("Uploading package...")
+		if tokenSource == config.SourceUndefined {
+			return errors.ErrNoToken
+		}
+		c.upload.Backoff = uploadRetryBackoff
+		c.upload.Progress = progress
+		if err := client.UpdatePackage(serviceID, c.version, c.path, c.upload); err != nil {
+			return err
+		}
+	}
+
+	// The version active before this deploy is recorded, rather than
+	// inferred after activation, so that a rollback restores it exactly.
+	var previous int
+	if base != nil && !c.force {
+		progress.Step("Checking for concurrent deploys...")
+		versions, err := c.Globals.Client.ListVersions(&fastly.ListVersionsInput{
+			Service: serviceID,
+		})
+		if err != nil {
+			return fmt.Errorf("error listing service versions: %w", err)
+		}
+		if err := base.check(serviceID, c.version, versions); err != nil {
+			return err
+		}
+		previous = base.active
+	} else if c.verify.url != "" && !newService {
+		progress.Step("Recording active version...")
+		versions, err := c.Globals.Client.ListVersions(&fastly.ListVersionsInput{
+			Service: serviceID,
+		})
+		if err != nil {
+			return fmt.Errorf("error listing service versions: %w", err)
+		}
+		for _, v := range versions {
+			if v.Active {
+				previous = v.Number
+			}
+		}
+	}
+
+	if editable {
+		progress.Step("Setting version comment...")
+
+		comment := c.comment
+		if comment == "" {
+			data := versionCommentData{
+				PackageHash: hashSum,
+				CLIVersion:  version.AppVersion,
+				Timestamp:   time.Now(),
+			}
+			if data.PackageHash == "" {
+				data.PackageHash, err = getPackageHashSum(c.path)
+				if err != nil {
+					return fmt.Errorf("error hashing package: %w", err)
+				}
+			}
+			data.readGitState(".")
+
+			comment, err = versionComment(tmpl, data)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(progress, "Setting comment of version %d to %q...\n", c.version, comment)
+
+		_, err = c.Globals.Client.UpdateVersion(&fastly.UpdateVersionInput{
+			Service: serviceID,
+			Version: c.version,
+			Comment: comment,
+		})
+		if err != nil {
+			return fmt.Errorf("error setting version comment: %w", err)
+		}
 	}
 
 	progress.Step("Activating version...")
@@ -132,8 +391,21 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		return fmt.Errorf("error activating version: %w", err)
 	}
 
+	if c.verify.url != "" {
+		progress.Step(fmt.Sprintf("Verifying deployment for %s...", c.verify.duration))
+		report := verifyDeployment(c.client, c.Globals.RTSClient, serviceID, c.verify, progress)
+		if report.failed() {
+			return c.rollback(serviceID, previous, newService, report, progress, undoStack)
+		}
+	}
+
 	progress.Step("Updating package manifest...")
 
+	if newService {
+		fmt.Fprintf(progress, "Setting service ID in manifest to %q...\n", serviceID)
+		c.manifest.SetServiceID(serviceID)
+	}
+
 	fmt.Fprintf(progress, "Setting version in manifest to %d...\n", c.version)
 	c.manifest.File.Version = c.version
 
@@ -141,6 +413,9 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		return fmt.Errorf("error saving package manifest: %w", err)
 	}
 
+	text.Artifact(progress, "package", c.path)
+	text.Artifact(progress, "service_id", serviceID)
+	text.Artifact(progress, "version", c.version)
 	progress.Done()
 
 	text.Break(out)
@@ -154,10 +429,77 @@ func (c *DeployCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		text.Description(out, "View this service at", fmt.Sprintf("https://%s", domains[0].Name))
 	}
 
+	printWarnings(out, warnings)
+
 	text.Success(out, "Deployed package (service %s, version %v)", serviceID, c.version)
 	return nil
 }
 
+// rollback reactivates the version which was active before the deploy, after
+// verification of the deployed version failed, and returns an error reporting
+// why it failed. A new service is instead deactivated and deleted.
+func (c *DeployCommand) rollback(serviceID string, previous int, newService bool, report verifyReport, progress text.Progress, undoStack *common.UndoStack) error {
+	inner := fmt.Errorf("error verifying deployment of version %d:\n\n%s", c.version, report)
+
+	switch {
+	case newService:
+		// The undo stack can't delete the service while its version is
+		// active, and deleting the service deletes its domain and backend, so
+		// the service is deleted here instead.
+		for undoStack.Pop() != nil {
+		}
+
+		progress.Step("Verification failed, deleting new service...")
+		return errors.RemediationError{
+			Inner:       inner,
+			Remediation: c.deleteService(serviceID),
+		}
+	case previous == 0:
+		return errors.RemediationError{
+			Inner:       inner,
+			Remediation: fmt.Sprintf("There was no previously active version to roll back to, so version %d is still active.", c.version),
+		}
+	}
+
+	progress.Step(fmt.Sprintf("Verification failed, rolling back to version %d...", previous))
+	_, err := c.Globals.Client.ActivateVersion(&fastly.ActivateVersionInput{
+		Service: serviceID,
+		Version: previous,
+	})
+	if err != nil {
+		return fmt.Errorf("error rolling back to version %d: %w\n\n%v", previous, err, inner)
+	}
+
+	return errors.RemediationError{
+		Inner:       inner,
+		Remediation: fmt.Sprintf("Rolled back to version %d, which was active before the deploy.", previous),
+	}
+}
+
+// deleteService deactivates the version of a new service and deletes the
+// service, returning a remediation which reports what was done.
+func (c *DeployCommand) deleteService(serviceID string) string {
+	_, err := c.Globals.Client.DeactivateVersion(&fastly.DeactivateVersionInput{
+		Service: serviceID,
+		Version: c.version,
+	})
+	if err != nil {
+		return fmt.Sprintf("The new service %s couldn't be deleted, as deactivating version %d failed: %v\n\nVersion %d is still active. Deactivate it and delete the service with:\n\n\t$ %s\n\t$ %s", serviceID, c.version, err, c.version,
+			text.Bold(fmt.Sprintf("fastly service-version deactivate --service-id %s --version %d", serviceID, c.version)),
+			text.Bold(fmt.Sprintf("fastly service delete --service-id %s", serviceID)))
+	}
+
+	err = c.Globals.Client.DeleteService(&fastly.DeleteServiceInput{
+		ID: serviceID,
+	})
+	if err != nil {
+		return fmt.Sprintf("The new service %s couldn't be deleted: %v\n\nVersion %d was deactivated. Delete the service with:\n\n\t$ %s", serviceID, err, c.version,
+			text.Bold(fmt.Sprintf("fastly service delete --service-id %s", serviceID)))
+	}
+
+	return "The new service was deleted. Fix the package and deploy it again."
+}
+
 // Client wraps a HTTP client with an endpoint and token to make API requests.
 type Client struct {
 	client api.HTTPClient
@@ -175,40 +517,158 @@ func NewClient(client api.HTTPClient, endpoint, token string) *Client {
 	}
 }
 
+// Package models a package uploaded to a service version, as returned by the
+// package API.
+type Package struct {
+	ServiceID string `json:"service_id"`
+	Version   int    `json:"version"`
+	Metadata  struct {
+		Size    int64  `json:"size"`
+		HashSum string `json:"hashsum"`
+	} `json:"metadata"`
+}
+
+// GetPackage is an HTTP API client method to get the package uploaded to a
+// given service version. It returns an error if the version has no package.
+func (c *Client) GetPackage(serviceID string, v int) (*Package, error) {
+	fullurl := fmt.Sprintf("%s/service/%s/version/%d/package", strings.TrimSuffix(c.endpoint, "/"), serviceID, v)
+	req, err := http.NewRequest("GET", fullurl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing API request: %w", err)
+	}
+
+	req.Header.Set("Fastly-Key", c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error executing API request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error from API: %s", resp.Status)
+	}
+
+	var pkg Package
+	if err := json.NewDecoder(resp.Body).Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("error decoding API response: %w", err)
+	}
+
+	return &pkg, nil
+}
+
+const (
+	// uploadRetryBackoff is the delay before the first retry of a failed
+	// package upload, which doubles for each further retry.
+	uploadRetryBackoff = time.Second
+
+	// maxErrorBodySize is how much of the body of an API error response is
+	// read when decoding it.
+	maxErrorBodySize = 1 << 20
+)
+
+// UploadOptions configures how UpdatePackage uploads a package. MaxSize is the
+// largest package in bytes which is uploaded, or zero for no limit. A failed
+// upload is retried up to Retries times if the failure is transient, waiting
+// Backoff before the first retry and twice as long before each one after.
+// Progress, if set, is written the number of bytes uploaded.
+type UploadOptions struct {
+	MaxSize  int64
+	Retries  int
+	Backoff  time.Duration
+	Progress io.Writer
+}
+
 // UpdatePackage is an HTTP API client method to update a package on a given
-// service version. It reads the package from a given path and encodes it as
-// multi-part form data in the request with associated content-type.
+// service version. It streams the package from a given path as multi-part form
+// data in the request with associated content-type, retrying transient
+// failures as configured by the options.
 // TODO(ph): This should eventually be replaced by the equivilent method in
 // the go-fastly client.
-func (c *Client) UpdatePackage(serviceID string, v int, path string) error {
-	file, err := os.Open(filepath.Clean(path))
+func (c *Client) UpdatePackage(serviceID string, v int, path string, opts UploadOptions) error {
+	fi, err := os.Stat(filepath.Clean(path))
 	if err != nil {
 		return fmt.Errorf("error reading package: %w", err)
 	}
-	defer file.Close() // #nosec G307
+	if opts.MaxSize > 0 && fi.Size() > opts.MaxSize {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("error uploading package: package is %d bytes, which exceeds the limit of %d bytes", fi.Size(), opts.MaxSize),
+			Remediation: fmt.Sprintf("To fix this error, reduce the size of the package, such as by stripping its debug information:\n\n\t$ %s", text.Bold("fastly compute build --strip")),
+		}
+	}
 
-	var body bytes.Buffer
-	w := multipart.NewWriter(&body)
-	part, err := w.CreateFormFile("package", filepath.Base(path))
-	if err != nil {
-		return fmt.Errorf("error creating multipart form: %w", err)
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.uploadPackage(serviceID, v, path, fi.Size(), opts.Progress)
+		if err == nil || !retry || attempt >= opts.Retries {
+			return err
+		}
+		if opts.Progress != nil {
+			fmt.Fprintf(opts.Progress, "Upload failed, retrying in %s: %v\n", backoff, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
+}
 
-	_, err = io.Copy(part, file)
+// uploadPackage makes a single attempt at uploading a package, reporting
+// whether a failure is transient and so worth retrying. The package is
+// streamed through a pipe rather than read into memory.
+func (c *Client) uploadPackage(serviceID string, v int, path string, size int64, progress io.Writer) (retry bool, err error) {
+	file, err := os.Open(filepath.Clean(path))
 	if err != nil {
-		return fmt.Errorf("error copying package to multipart form: %w", err)
+		return false, fmt.Errorf("error reading package: %w", err)
 	}
+	defer file.Close() // #nosec G307
 
-	err = w.Close()
-	if err != nil {
-		return fmt.Errorf("error closing multipart form: %w", err)
+	// The multipart envelope is measured by encoding it around an empty
+	// file, so that the request has a length despite being streamed.
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	var envelope bytes.Buffer
+	ew := multipart.NewWriter(&envelope)
+	if err := ew.SetBoundary(w.Boundary()); err != nil {
+		return false, fmt.Errorf("error creating multipart form: %w", err)
+	}
+	if _, err := ew.CreateFormFile("package", filepath.Base(path)); err != nil {
+		return false, fmt.Errorf("error creating multipart form: %w", err)
+	}
+	if err := ew.Close(); err != nil {
+		return false, fmt.Errorf("error closing multipart form: %w", err)
 	}
 
+	var r io.Reader = file
+	if progress != nil {
+		r = io.TeeReader(file, &uploadProgress{w: progress, total: size})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		part, err := w.CreateFormFile("package", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer func() {
+		// Unblock the writer if the request finished without reading the
+		// whole body, such as when the API rejected it early.
+		pr.Close()
+		<-done
+	}()
+
 	fullurl := fmt.Sprintf("%s/service/%s/version/%d/package", strings.TrimSuffix(c.endpoint, "/"), serviceID, v)
-	req, err := http.NewRequest("PUT", fullurl, &body)
+	req, err := http.NewRequest("PUT", fullurl, pr)
 	if err != nil {
-		return fmt.Errorf("error constructing API request: %w", err)
+		return false, fmt.Errorf("error constructing API request: %w", err)
 	}
+	req.ContentLength = int64(envelope.Len()) + size
 
 	req.Header.Set("Fastly-Key", c.token)
 	req.Header.Set("Accept", "application/json")
@@ -217,15 +677,131 @@ func (c *Client) UpdatePackage(serviceID string, v int, path string) error {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error executing API request: %w", err)
+		return true, fmt.Errorf("error executing API request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode >= 500, decodeAPIError(resp)
+	}
+
+	return false, nil
+}
+
+// uploadProgress writes the number of bytes of a package uploaded so far to a
+// writer, each time another tenth of the package is uploaded.
+type uploadProgress struct {
+	w        io.Writer
+	total    int64
+	uploaded int64
+	reported int64
+}
+
+func (p *uploadProgress) Write(b []byte) (int, error) {
+	p.uploaded += int64(len(b))
+	if p.uploaded == p.total || (p.uploaded-p.reported)*10 >= p.total {
+		p.reported = p.uploaded
+		fmt.Fprintf(p.w, "Uploaded %d of %d bytes\n", p.uploaded, p.total)
+	}
+	return len(b), nil
+}
+
+// apiError models the JSON body of an error response from the Fastly API.
+type apiError struct {
+	Msg    string `json:"msg"`
+	Detail string `json:"detail"`
+}
+
+// decodeAPIError returns an error for an unsuccessful API response. If the
+// response has a JSON error body, the message it gives is included in the
+// error, and its detail, which explains why the request was rejected, is
+// given as the remediation.
+func decodeAPIError(resp *http.Response) error {
+	var e apiError
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err := json.Unmarshal(body, &e); err != nil || (e.Msg == "" && e.Detail == "") {
 		return fmt.Errorf("error from API: %s", resp.Status)
 	}
 
-	return nil
+	inner := fmt.Errorf("error from API: %s", resp.Status)
+	if e.Msg != "" {
+		inner = fmt.Errorf("error from API: %s: %s", resp.Status, e.Msg)
+	}
+	re := errors.RemediationError{
+		Inner:       inner,
+		Remediation: e.Detail,
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		re.Remediation = strings.TrimSpace(e.Detail + "\n\n" + errors.AuthRemediation)
+	}
+	return re
+}
+
+// deployBase records the versions of a service when a deploy starts: the
+// version the deploy is based on, when it was last updated, and the version
+// which was active. If any have changed by the time the deploy activates its
+// version, another deploy has raced with it.
+type deployBase struct {
+	number    int
+	updatedAt *time.Time
+	active    int
+}
+
+func newDeployBase(versions []*fastly.Version, base *fastly.Version) *deployBase {
+	b := &deployBase{
+		number:    base.Number,
+		updatedAt: base.UpdatedAt,
+	}
+	for _, v := range versions {
+		if v.Active {
+			b.active = v.Number
+		}
+	}
+	return b
+}
+
+// check returns a conflict error if the current versions of the service show
+// it has changed since the deploy of version started.
+func (b *deployBase) check(serviceID string, version int, versions []*fastly.Version) error {
+	var (
+		active  int
+		updated *time.Time
+	)
+	for _, v := range versions {
+		if v.Active {
+			active = v.Number
+		}
+		if v.Number == b.number {
+			updated = v.UpdatedAt
+		}
+	}
+
+	var inner error
+	switch {
+	case active != b.active:
+		inner = fmt.Errorf("error activating version %d: the active version of service %s changed from %d to %d during the deploy", version, serviceID, b.active, active)
+	case b.updatedAt != nil && (updated == nil || !updated.Equal(*b.updatedAt)):
+		inner = fmt.Errorf("error activating version %d: version %d of service %s, which the deploy is based on, changed during the deploy", version, b.number, serviceID)
+	default:
+		return nil
+	}
+
+	return errors.RemediationError{
+		Inner:       inner,
+		Remediation: fmt.Sprintf("This may be because someone else deployed to the service at the same time. To fix this error, deploy again on top of their changes:\n\n\t$ %s\n\nTo activate version %d anyway, replacing their changes, deploy with the --force flag.", text.Bold("fastly compute deploy"), version),
+	}
+}
+
+// packageUnchanged reports whether the package uploaded to a service version
+// has the given hash. Failing to fetch the package isn't fatal, as the package
+// will then be uploaded anyway.
+func packageUnchanged(out io.Writer, client *Client, serviceID string, version int, hashSum string) bool {
+	pkg, err := client.GetPackage(serviceID, version)
+	if err != nil {
+		fmt.Fprintf(out, "Unable to compare package with version %d: %v\n", version, err)
+		return false
+	}
+	return pkg.Metadata.HashSum == hashSum
 }
 
 // getLatestIdealVersion gets the most ideal service version using the following logic:
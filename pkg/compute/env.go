@@ -0,0 +1,73 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/text"
+)
+
+// EnvRootCommand is the parent command for the subcommands which manage the
+// environments of a package, which are the [env.<name>] sections of its
+// manifest selected with the --env flag.
+type EnvRootCommand struct {
+	common.Base
+	// no flags
+}
+
+// NewEnvRootCommand returns a new command registered in the parent.
+func NewEnvRootCommand(parent common.Registerer, globals *config.Data) *EnvRootCommand {
+	var c EnvRootCommand
+	c.Globals = globals
+	c.CmdClause = parent.Command("env", "Manage the environments of a Compute@Edge package")
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *EnvRootCommand) Exec(in io.Reader, out io.Writer) error {
+	panic("unreachable")
+}
+
+// EnvListCommand lists the environments configured in the package manifest.
+type EnvListCommand struct {
+	common.Base
+}
+
+// NewEnvListCommand returns a usable command registered under the parent.
+func NewEnvListCommand(parent common.Registerer, globals *config.Data) *EnvListCommand {
+	var c EnvListCommand
+	c.Globals = globals
+	c.CmdClause = parent.Command("list", "List the environments configured in the package manifest")
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *EnvListCommand) Exec(in io.Reader, out io.Writer) error {
+	var m manifest.File
+	if err := m.Read(ManifestFilename); err != nil {
+		return fmt.Errorf("error reading package manifest: %w", err)
+	}
+
+	if len(m.Env) == 0 {
+		text.Info(out, "No environments configured. Add an [env.<name>] section to %s to configure one.", ManifestFilename)
+		return nil
+	}
+
+	names := make([]string, 0, len(m.Env))
+	for name := range m.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := text.NewTable(out)
+	tw.AddHeader("NAME", "SERVICE ID")
+	for _, name := range names {
+		tw.AddLine(name, m.Env[name].ServiceID)
+	}
+	tw.Print()
+	return nil
+}
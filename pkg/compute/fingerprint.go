@@ -0,0 +1,99 @@
+package compute
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/ignore"
+	"github.com/fastly/cli/pkg/version"
+)
+
+// buildFingerprintPath returns the path of the file recording the fingerprint
+// of the inputs to the last successful build of the named package.
+func buildFingerprintPath(name string) string {
+	return filepath.Join("pkg", fmt.Sprintf("%s.fingerprint", name))
+}
+
+// buildFingerprint returns the SHA-256 hash of the inputs to a build of the
+// package: the files it is built from, the version of the toolchain, the
+// version of the CLI and the given build settings. The files of a Rust package
+// are those cargo reads to build it, otherwise every file in the package which
+// isn't ignored, along with the manifest, the .fastlyignore file and the build
+// output in the bin directory, so that a package whose output was changed or
+// removed since it was built isn't up to date.
+func buildFingerprint(language *Language, toolchainVersion string, settings ...string) (string, error) {
+	matcher, err := ignore.ReadFile(IgnoreFilePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s file: %w", IgnoreFilePath, err)
+	}
+
+	paths := []string{ManifestFilename, IgnoreFilePath, "."}
+	rust, isRust := language.Toolchain.(*Rust)
+	if isRust {
+		paths = paths[:2]
+	}
+	snapshot, err := snapshotFiles(paths)
+	if err != nil {
+		return "", err
+	}
+	files := make([]string, 0, len(snapshot))
+	for f := range snapshot {
+		files = append(files, f)
+	}
+
+	if isRust {
+		inputs, err := rust.buildInputs(matcher)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, inputs...)
+	}
+
+	if common.FileExists("bin") {
+		binFiles, err := getNonIgnoredFiles("bin", matcher)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, binFiles...)
+	}
+	sort.Strings(files)
+
+	metadata, err := newPackageMetadata(".", files)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "files %s\n", metadata.Hash)
+	fmt.Fprintf(h, "toolchain %s %s\n", language.Name, toolchainVersion)
+	fmt.Fprintf(h, "cli %s\n", version.AppVersion)
+	for _, s := range settings {
+		fmt.Fprintf(h, "setting %s\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readBuildFingerprint returns the fingerprint recorded by the last
+// successful build, or an empty string if there is none.
+func readBuildFingerprint(path string) string {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeBuildFingerprint records the fingerprint of a successful build.
+func writeBuildFingerprint(path, fingerprint string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(fingerprint+"\n"), 0600)
+}
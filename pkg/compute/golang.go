@@ -0,0 +1,259 @@
+package compute
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+const (
+	// TinyGoVersionConstraint is the semver constraint for the versions of the
+	// TinyGo compiler that we support.
+	TinyGoVersionConstraint = ">= 0.14.0"
+	// TinyGoWasiTarget is the TinyGo compilation target for Wasi capable Wasm.
+	TinyGoWasiTarget = "wasi"
+	// TinyGoWasmABIConstraint is the semver constraint for the versions of the
+	// TinyGo compiler which need the generic Wasm ABI to be selected, before
+	// it became the only ABI and the -wasm-abi flag was removed.
+	TinyGoWasmABIConstraint = "< 0.27.0"
+)
+
+// Go implements Toolchain for the Go language, which is compiled to Wasm using
+// the TinyGo compiler.
+type Go struct{}
+
+// Verify implements the Toolchain interface and verifies whether the Go
+// language toolchain is correctly configured on the host.
+func (g Go) Verify(out io.Writer) error {
+	// 1) Check `tinygo` is on $PATH
+	//
+	// The standard Go compiler can't produce WASI binaries, so we require the
+	// TinyGo compiler. We only check whether the binary exists on the users
+	// $PATH and error with installation help text.
+
+	fmt.Fprintf(out, "Checking if tinygo is installed...\n")
+
+	p, err := exec.LookPath("tinygo")
+	if err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("`tinygo` not found in $PATH"),
+			Remediation: fmt.Sprintf("To fix this error, install TinyGo %s by visiting:\n\n\t$ %s", TinyGoVersionConstraint, text.Bold("https://tinygo.org/getting-started/")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found tinygo at %s\n", p)
+
+	// 2) Check the tinygo version is supported
+	//
+	// We parse the output of `tinygo version`, which is of the form
+	// `tinygo version 0.15.0 linux/amd64 (using go version ...)`.
+
+	version, err := tinyGoVersion()
+	if err != nil {
+		return err
+	}
+
+	constraint, err := semver.NewConstraint(TinyGoVersionConstraint)
+	if err != nil {
+		return fmt.Errorf("error parsing tinygo version constraint: %w", err)
+	}
+
+	if !constraint.Check(version) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("tinygo version %s not supported", version),
+			Remediation: fmt.Sprintf("To fix this error, install TinyGo %s by visiting:\n\n\t$ %s", TinyGoVersionConstraint, text.Bold("https://tinygo.org/getting-started/")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found tinygo %s\n", version)
+
+	// 3) Check `wasi` target exists
+	//
+	// We stream the output of `tinygo targets` and look for the `wasi` value.
+	// If not found, TinyGo has been installed without its WASI support.
+
+	fmt.Fprintf(out, "Checking if %s target is installed...\n", TinyGoWasiTarget)
+
+	cmd := exec.Command("tinygo", "targets")
+	stdoutStderr, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error executing tinygo: %w", err)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(stdoutStderr)))
+	scanner.Split(bufio.ScanWords)
+	var found bool
+	for scanner.Scan() {
+		if scanner.Text() == TinyGoWasiTarget {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("tinygo target %s not found", TinyGoWasiTarget),
+			Remediation: fmt.Sprintf("To fix this error, reinstall TinyGo with WASI support by visiting:\n\n\t$ %s", text.Bold("https://tinygo.org/getting-started/")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found %s target\n", TinyGoWasiTarget)
+
+	// 4) Check go.mod file exists in $PWD
+	//
+	// A valid go.mod file is needed to resolve the package dependencies.
+	// Therefore, we assert whether one exists in the current $PWD.
+
+	fpath, err := filepath.Abs("go.mod")
+	if err != nil {
+		return fmt.Errorf("error getting go.mod path: %w", err)
+	}
+
+	if !common.FileExists(fpath) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("%s not found", fpath),
+			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold("go mod init")),
+		}
+	}
+
+	fmt.Fprintf(out, "Found go.mod at %s\n", fpath)
+
+	return nil
+}
+
+// Build implements the Toolchain interface and attempts to compile the package
+// Go source to a Wasm binary.
+func (g Go) Build(out io.Writer, verbose bool) error {
+	// Get working directory.
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	binDir := filepath.Join(dir, "bin")
+	if err := createBinDirectory(binDir); err != nil {
+		return err
+	}
+
+	version, err := tinyGoVersion()
+	if err != nil {
+		return err
+	}
+
+	args, err := tinyGoBuildArgs(version, filepath.Join(binDir, "main.wasm"), verbose)
+	if err != nil {
+		return err
+	}
+
+	// Call tinygo build with the Wasi target.
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the variables come from trusted sources.
+	/* #nosec */
+	cmd := exec.Command("tinygo", args...)
+
+	return streamCommand(cmd, out, verbose)
+}
+
+// BuildTests implements the Toolchain interface and compiles the tests of the
+// package in the current directory to a Go test binary in dir.
+func (g Go) BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error) {
+	path := filepath.Join(dir, "main.test.wasm")
+
+	args := []string{
+		"test",
+		"-target",
+		TinyGoWasiTarget,
+		"-c",
+		"-o",
+		path,
+	}
+	if verbose {
+		args = append(args, "-x")
+	}
+	args = append(args, ".")
+
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the variables come from trusted sources.
+	/* #nosec */
+	cmd := exec.Command("tinygo", args...)
+
+	if err := streamCommand(cmd, out, verbose); err != nil {
+		return nil, err
+	}
+
+	// A package without tests has no test binary.
+	if !common.FileExists(path) {
+		return nil, nil
+	}
+	return []TestBinary{{Name: "main", Path: path, Harness: goTestHarness{}}}, nil
+}
+
+// Version implements the Toolchain interface and returns the version of the
+// TinyGo compiler.
+func (g Go) Version() (string, error) {
+	version, err := tinyGoVersion()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tinygo %s", version), nil
+}
+
+// tinyGoBuildArgs returns the arguments to `tinygo build` which compile the
+// package in the current directory to the output Wasm binary. Versions of
+// TinyGo before the generic Wasm ABI became the only one must select it.
+func tinyGoBuildArgs(version *semver.Version, output string, verbose bool) ([]string, error) {
+	constraint, err := semver.NewConstraint(TinyGoWasmABIConstraint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tinygo version constraint: %w", err)
+	}
+
+	args := []string{
+		"build",
+		"-target",
+		TinyGoWasiTarget,
+	}
+	if constraint.Check(version) {
+		args = append(args, "-wasm-abi", "generic")
+	}
+	args = append(args, "-o", output)
+	if verbose {
+		args = append(args, "-x")
+	}
+	return append(args, "."), nil
+}
+
+// tinyGoVersion executes `tinygo version` and returns the compiler version.
+func tinyGoVersion() (*semver.Version, error) {
+	stdoutStderr, err := exec.Command("tinygo", "version").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error executing tinygo: %w", err)
+	}
+	return parseTinyGoVersion(string(stdoutStderr))
+}
+
+// parseTinyGoVersion parses the output of `tinygo version` and returns the
+// compiler version as a semver.Version.
+func parseTinyGoVersion(output string) (*semver.Version, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 3 || fields[0] != "tinygo" || fields[1] != "version" {
+		return nil, fmt.Errorf("error parsing tinygo version: unexpected output %q", strings.TrimSpace(output))
+	}
+
+	version, err := semver.NewVersion(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tinygo version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,762 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// status is the fastly_status value returned by every host function.
+type status uint32
+
+const (
+	statusOK             status = 0
+	statusError          status = 1
+	statusInval          status = 2
+	statusBadf           status = 3
+	statusBuflen         status = 4
+	statusUnsupported    status = 5
+	statusHTTPInvalid    status = 7
+	statusHTTPIncomplete status = 9
+	statusNone           status = 10
+)
+
+// bodyWriteFront is the body_write_end value for writes which are prepended to
+// a body rather than appended.
+const bodyWriteFront = 1
+
+// hostFunc is the Go implementation of a host function. Parameters are read
+// from params in the order they are declared by the ABI.
+type hostFunc func(s *session, m memory, params []uint64) status
+
+// goFunction adapts a hostFunc to the wazero calling convention, looking up
+// the session from the context of the call.
+func (f hostFunc) goFunction(results int) api.GoModuleFunction {
+	return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		st := statusError
+		if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+			st = f(s, memory{mod.Memory()}, stack)
+		}
+		if results > 0 {
+			stack[0] = uint64(st)
+		}
+	})
+}
+
+// unsupported is linked to every imported host function which isn't
+// implemented locally.
+func unsupported(*session, memory, []uint64) status {
+	return statusUnsupported
+}
+
+// abi maps module and function names to their implementations.
+var abi = map[string]map[string]hostFunc{
+	"fastly_abi": {
+		"init": func(*session, memory, []uint64) status { return statusOK },
+	},
+	"fastly_log": {
+		"endpoint_get": logEndpointGet,
+		"write":        logWrite,
+	},
+	"fastly_http_body": {
+		"new":    bodyNew,
+		"append": bodyAppend,
+		"read":   bodyRead,
+		"write":  bodyWrite,
+		"close":  bodyClose,
+	},
+	"fastly_http_req": {
+		"body_downstream_get":          reqBodyDownstreamGet,
+		"downstream_client_ip_addr":    reqDownstreamClientIPAddr,
+		"new":                          reqNew,
+		"close":                        reqClose,
+		"method_get":                   reqMethodGet,
+		"method_set":                   reqMethodSet,
+		"uri_get":                      reqURIGet,
+		"uri_set":                      reqURISet,
+		"version_get":                  reqVersionGet,
+		"version_set":                  reqVersionSet,
+		"header_names_get":             reqHeaderNamesGet,
+		"header_value_get":             reqHeaderValueGet,
+		"header_values_get":            reqHeaderValuesGet,
+		"header_values_set":            reqHeaderValuesSet,
+		"header_insert":                reqHeaderInsert,
+		"header_append":                reqHeaderAppend,
+		"header_remove":                reqHeaderRemove,
+		"original_header_names_get":    reqOriginalHeaderNamesGet,
+		"original_header_count":        reqOriginalHeaderCount,
+		"send":                         reqSend,
+		"send_async":                   reqSendAsync,
+		"send_async_streaming":         reqSendAsync,
+		"pending_req_poll":             reqPendingPoll,
+		"pending_req_wait":             reqPendingWait,
+		"cache_override_set":           ignored,
+		"cache_override_v2_set":        ignored,
+		"auto_decompress_response_set": ignored,
+		"framing_headers_mode_set":     ignored,
+	},
+	"fastly_http_resp": {
+		"new":               respNew,
+		"close":             respClose,
+		"status_get":        respStatusGet,
+		"status_set":        respStatusSet,
+		"version_get":       respVersionGet,
+		"version_set":       respVersionSet,
+		"header_names_get":  respHeaderNamesGet,
+		"header_value_get":  respHeaderValueGet,
+		"header_values_get": respHeaderValuesGet,
+		"header_values_set": respHeaderValuesSet,
+		"header_insert":     respHeaderInsert,
+		"header_append":     respHeaderAppend,
+		"header_remove":     respHeaderRemove,
+		"send_downstream":   respSendDownstream,
+	},
+}
+
+// ignored is used for host functions which only affect platform behavior
+// that doesn't exist locally, such as caching.
+func ignored(*session, memory, []uint64) status {
+	return statusOK
+}
+
+// memory wraps the guest's linear memory with helpers for the pointer and
+// length pairs used throughout the ABI.
+type memory struct {
+	api.Memory
+}
+
+func (m memory) bytes(ptr, n uint64) ([]byte, bool) {
+	return m.Read(uint32(ptr), uint32(n))
+}
+
+func (m memory) string(ptr, n uint64) (string, bool) {
+	b, ok := m.bytes(ptr, n)
+	return string(b), ok
+}
+
+func (m memory) putU32(ptr uint64, v uint32) bool {
+	return m.WriteUint32Le(uint32(ptr), v)
+}
+
+// putBuffer writes v to a guest buffer of size n, and the number of bytes
+// written (or required, if the buffer is too small) to nwritten.
+func (m memory) putBuffer(v []byte, ptr, n, nwritten uint64) status {
+	if len(v) > int(uint32(n)) {
+		m.putU32(nwritten, uint32(len(v)))
+		return statusBuflen
+	}
+	if !m.Write(uint32(ptr), v) || !m.putU32(nwritten, uint32(len(v))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+// putMultiValue writes the NUL-terminated items starting at cursor to a guest
+// buffer, in as many calls as the guest needs. The next cursor is written to
+// ending, or -1 once all items have been written.
+func (m memory) putMultiValue(items []string, ptr, n, cursor, ending, nwritten uint64) status {
+	var out []byte
+	next := int64(-1)
+	for i := int(uint32(cursor)); i < len(items); i++ {
+		item := append([]byte(items[i]), 0)
+		if len(out)+len(item) > int(uint32(n)) {
+			if len(out) == 0 {
+				return statusBuflen
+			}
+			next = int64(i)
+			break
+		}
+		out = append(out, item...)
+	}
+	if !m.Write(uint32(ptr), out) ||
+		!m.WriteUint64Le(uint32(ending), uint64(next)) ||
+		!m.putU32(nwritten, uint32(len(out))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func logEndpointGet(s *session, m memory, p []uint64) status {
+	name, ok := m.string(p[0], p[1])
+	if !ok {
+		return statusInval
+	}
+	s.endpoints = append(s.endpoints, name)
+	if !m.putU32(p[2], uint32(len(s.endpoints)-1)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func logWrite(s *session, m memory, p []uint64) status {
+	h := uint32(p[0])
+	if int(h) >= len(s.endpoints) {
+		return statusBadf
+	}
+	msg, ok := m.bytes(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	fmt.Fprintf(s.cfg.Stdout, "%s | %s\n", s.endpoints[h], strings.TrimRight(string(msg), "\n"))
+	if !m.putU32(p[3], uint32(len(msg))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func bodyNew(s *session, m memory, p []uint64) status {
+	if !m.putU32(p[0], s.newBody(&body{})) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func bodyAppend(s *session, m memory, p []uint64) status {
+	dst, ok := s.bodies[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	src, ok := s.bodies[uint32(p[1])]
+	if !ok {
+		return statusBadf
+	}
+	delete(s.bodies, uint32(p[1]))
+	return writeBody(dst, src.buf.Bytes())
+}
+
+func bodyRead(s *session, m memory, p []uint64) status {
+	b, ok := s.bodies[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	chunk := b.buf.Next(int(uint32(p[2])))
+	if !m.Write(uint32(p[1]), chunk) || !m.putU32(p[3], uint32(len(chunk))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func bodyWrite(s *session, m memory, p []uint64) status {
+	b, ok := s.bodies[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	data, ok := m.bytes(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	var st status
+	if uint32(p[3]) == bodyWriteFront && b.stream == nil {
+		rest := append([]byte(nil), b.buf.Bytes()...)
+		b.buf.Reset()
+		b.buf.Write(data)
+		b.buf.Write(rest)
+		st = statusOK
+	} else {
+		st = writeBody(b, data)
+	}
+	if st != statusOK {
+		return st
+	}
+	if !m.putU32(p[4], uint32(len(data))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func writeBody(b *body, data []byte) status {
+	if b.stream != nil {
+		if _, err := b.stream.Write(data); err != nil {
+			return statusError
+		}
+		flush(b.stream)
+		return statusOK
+	}
+	b.buf.Write(data)
+	return statusOK
+}
+
+func bodyClose(s *session, m memory, p []uint64) status {
+	if _, ok := s.bodies[uint32(p[0])]; !ok {
+		return statusBadf
+	}
+	delete(s.bodies, uint32(p[0]))
+	return statusOK
+}
+
+func reqBodyDownstreamGet(s *session, m memory, p []uint64) status {
+	r, b, err := s.downstreamRequest()
+	if err != nil {
+		fmt.Fprintf(s.cfg.Stderr, "Error: %v\n", err)
+		return statusError
+	}
+	if !m.putU32(p[0], s.newRequest(r)) || !m.putU32(p[1], s.newBody(b)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqDownstreamClientIPAddr(s *session, m memory, p []uint64) status {
+	host, _, err := net.SplitHostPort(s.downstream.RemoteAddr)
+	if err != nil {
+		host = s.downstream.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return statusNone
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if !m.Write(uint32(p[0]), ip) || !m.putU32(p[1], uint32(len(ip))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqNew(s *session, m memory, p []uint64) status {
+	r := &request{
+		method:  http.MethodGet,
+		uri:     "/",
+		header:  make(http.Header),
+		version: 2,
+	}
+	if !m.putU32(p[0], s.newRequest(r)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqClose(s *session, m memory, p []uint64) status {
+	if _, ok := s.requests[uint32(p[0])]; !ok {
+		return statusBadf
+	}
+	delete(s.requests, uint32(p[0]))
+	return statusOK
+}
+
+func reqMethodGet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	return m.putBuffer([]byte(r.method), p[1], p[2], p[3])
+}
+
+func reqMethodSet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	method, ok := m.string(p[1], p[2])
+	if !ok || method == "" {
+		return statusInval
+	}
+	r.method = method
+	return statusOK
+}
+
+func reqURIGet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	return m.putBuffer([]byte(r.uri), p[1], p[2], p[3])
+}
+
+func reqURISet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	uri, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	r.uri = uri
+	return statusOK
+}
+
+func reqVersionGet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	if !m.putU32(p[1], r.version) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqVersionSet(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	r.version = uint32(p[1])
+	return statusOK
+}
+
+func reqHeader(s *session, h uint64) (http.Header, bool) {
+	r, ok := s.requests[uint32(h)]
+	if !ok {
+		return nil, false
+	}
+	return r.header, true
+}
+
+func reqHeaderNamesGet(s *session, m memory, p []uint64) status {
+	return headerNamesGet(reqHeader, s, m, p)
+}
+
+func reqHeaderValueGet(s *session, m memory, p []uint64) status {
+	return headerValueGet(reqHeader, s, m, p)
+}
+
+func reqHeaderValuesGet(s *session, m memory, p []uint64) status {
+	return headerValuesGet(reqHeader, s, m, p)
+}
+
+func reqHeaderValuesSet(s *session, m memory, p []uint64) status {
+	return headerValuesSet(reqHeader, s, m, p)
+}
+
+func reqHeaderInsert(s *session, m memory, p []uint64) status {
+	return headerInsert(reqHeader, s, m, p)
+}
+
+func reqHeaderAppend(s *session, m memory, p []uint64) status {
+	return headerAppend(reqHeader, s, m, p)
+}
+
+func reqHeaderRemove(s *session, m memory, p []uint64) status {
+	return headerRemove(reqHeader, s, m, p)
+}
+
+func reqOriginalHeaderNamesGet(s *session, m memory, p []uint64) status {
+	return m.putMultiValue(headerNames(s.downstream.Header), p[0], p[1], p[2], p[3], p[4])
+}
+
+func reqOriginalHeaderCount(s *session, m memory, p []uint64) status {
+	if !m.putU32(p[0], uint32(len(s.downstream.Header))) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqSend(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	b, ok := s.bodies[uint32(p[1])]
+	if !ok {
+		return statusBadf
+	}
+	backend, ok := m.string(p[2], p[3])
+	if !ok {
+		return statusInval
+	}
+	delete(s.bodies, uint32(p[1]))
+
+	resp, rb, st := s.send(r, b, backend)
+	if st != statusOK {
+		return st
+	}
+	if !m.putU32(p[4], s.newResponse(resp)) || !m.putU32(p[5], s.newBody(rb)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+// reqSendAsync sends the request immediately and stores the result, as there
+// is no concurrency within a single guest locally.
+func reqSendAsync(s *session, m memory, p []uint64) status {
+	r, ok := s.requests[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	b, ok := s.bodies[uint32(p[1])]
+	if !ok {
+		return statusBadf
+	}
+	backend, ok := m.string(p[2], p[3])
+	if !ok {
+		return statusInval
+	}
+	delete(s.bodies, uint32(p[1]))
+
+	resp, rb, st := s.send(r, b, backend)
+	h := s.handle()
+	s.pending[h] = &pending{resp: resp, body: rb, err: st}
+	if !m.putU32(p[4], h) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func reqPendingPoll(s *session, m memory, p []uint64) status {
+	if _, ok := s.pending[uint32(p[0])]; !ok {
+		return statusBadf
+	}
+	if !m.putU32(p[1], 1) {
+		return statusInval
+	}
+	return reqPendingWait(s, m, []uint64{p[0], p[2], p[3]})
+}
+
+func reqPendingWait(s *session, m memory, p []uint64) status {
+	pr, ok := s.pending[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	delete(s.pending, uint32(p[0]))
+	if pr.err != statusOK {
+		return pr.err
+	}
+	if !m.putU32(p[1], s.newResponse(pr.resp)) || !m.putU32(p[2], s.newBody(pr.body)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func respNew(s *session, m memory, p []uint64) status {
+	r := &response{
+		status:  http.StatusOK,
+		header:  make(http.Header),
+		version: 2,
+	}
+	if !m.putU32(p[0], s.newResponse(r)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func respClose(s *session, m memory, p []uint64) status {
+	if _, ok := s.responses[uint32(p[0])]; !ok {
+		return statusBadf
+	}
+	delete(s.responses, uint32(p[0]))
+	return statusOK
+}
+
+func respStatusGet(s *session, m memory, p []uint64) status {
+	r, ok := s.responses[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	if !m.WriteUint16Le(uint32(p[1]), uint16(r.status)) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func respStatusSet(s *session, m memory, p []uint64) status {
+	r, ok := s.responses[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	code := int(uint32(p[1]))
+	if code < 100 || code > 999 {
+		return statusInval
+	}
+	r.status = code
+	return statusOK
+}
+
+func respVersionGet(s *session, m memory, p []uint64) status {
+	r, ok := s.responses[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	if !m.putU32(p[1], r.version) {
+		return statusInval
+	}
+	return statusOK
+}
+
+func respVersionSet(s *session, m memory, p []uint64) status {
+	r, ok := s.responses[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	r.version = uint32(p[1])
+	return statusOK
+}
+
+func respHeader(s *session, h uint64) (http.Header, bool) {
+	r, ok := s.responses[uint32(h)]
+	if !ok {
+		return nil, false
+	}
+	return r.header, true
+}
+
+func respHeaderNamesGet(s *session, m memory, p []uint64) status {
+	return headerNamesGet(respHeader, s, m, p)
+}
+
+func respHeaderValueGet(s *session, m memory, p []uint64) status {
+	return headerValueGet(respHeader, s, m, p)
+}
+
+func respHeaderValuesGet(s *session, m memory, p []uint64) status {
+	return headerValuesGet(respHeader, s, m, p)
+}
+
+func respHeaderValuesSet(s *session, m memory, p []uint64) status {
+	return headerValuesSet(respHeader, s, m, p)
+}
+
+func respHeaderInsert(s *session, m memory, p []uint64) status {
+	return headerInsert(respHeader, s, m, p)
+}
+
+func respHeaderAppend(s *session, m memory, p []uint64) status {
+	return headerAppend(respHeader, s, m, p)
+}
+
+func respHeaderRemove(s *session, m memory, p []uint64) status {
+	return headerRemove(respHeader, s, m, p)
+}
+
+func respSendDownstream(s *session, m memory, p []uint64) status {
+	r, ok := s.responses[uint32(p[0])]
+	if !ok {
+		return statusBadf
+	}
+	b, ok := s.bodies[uint32(p[1])]
+	if !ok {
+		return statusBadf
+	}
+	streaming := uint32(p[2]) != 0
+	if !streaming {
+		delete(s.bodies, uint32(p[1]))
+	}
+	return s.sendDownstream(r, b, streaming)
+}
+
+// headerLookup resolves a request or response handle to its headers.
+type headerLookup func(s *session, h uint64) (http.Header, bool)
+
+// headerNames returns the lowercase names of all headers in sorted order, so
+// that the cursor used by the guest is stable between calls.
+func headerNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func headerNamesGet(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	return m.putMultiValue(headerNames(header), p[1], p[2], p[3], p[4], p[5])
+}
+
+func headerValueGet(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	values := header.Values(name)
+	if len(values) == 0 {
+		return statusNone
+	}
+	return m.putBuffer([]byte(values[0]), p[3], p[4], p[5])
+}
+
+func headerValuesGet(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	return m.putMultiValue(header.Values(name), p[3], p[4], p[5], p[6], p[7])
+}
+
+func headerValuesSet(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	values, ok := m.string(p[3], p[4])
+	if !ok {
+		return statusInval
+	}
+	header.Del(name)
+	for _, v := range strings.Split(strings.TrimSuffix(values, "\x00"), "\x00") {
+		header.Add(name, v)
+	}
+	return statusOK
+}
+
+func headerInsert(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	value, ok := m.string(p[3], p[4])
+	if !ok {
+		return statusInval
+	}
+	header.Set(name, value)
+	return statusOK
+}
+
+func headerAppend(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	value, ok := m.string(p[3], p[4])
+	if !ok {
+		return statusInval
+	}
+	header.Add(name, value)
+	return statusOK
+}
+
+func headerRemove(lookup headerLookup, s *session, m memory, p []uint64) status {
+	header, ok := lookup(s, p[0])
+	if !ok {
+		return statusBadf
+	}
+	name, ok := m.string(p[1], p[2])
+	if !ok {
+		return statusInval
+	}
+	if len(header.Values(name)) == 0 {
+		return statusInval
+	}
+	header.Del(name)
+	return statusOK
+}
@@ -0,0 +1,69 @@
+package host
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Command is a Wasm binary which is run as a WASI command, such as a test
+// binary, rather than once for every request. The host functions it imports
+// are linked, but fail as there's no request being handled.
+type Command struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	count   uint64
+}
+
+// NewCommand compiles the Wasm binary and links it against WASI and the host
+// ABI, as New does. Instances of the command are stopped when the context
+// passed to Run is done.
+func NewCommand(ctx context.Context, wasm []byte) (*Command, error) {
+	r, module, err := compile(ctx, wasm, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if err != nil {
+		return nil, err
+	}
+	return &Command{runtime: r, module: module}, nil
+}
+
+// Run runs a fresh instance of the command with the arguments, the first of
+// which is the program name, and returns its exit code. An error is returned
+// if the instance traps, such as when Rust code panics, as panics abort the
+// program in Wasm, or if the context is done before the instance exits.
+func (c *Command) Run(ctx context.Context, args []string, stdout, stderr io.Writer) (uint32, error) {
+	n := atomic.AddUint64(&c.count, 1)
+	config := wazero.NewModuleConfig().
+		WithName(fmt.Sprintf("command-%d", n)).
+		WithArgs(args...).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	mod, err := c.runtime.InstantiateModule(ctx, c.module, config)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		return 0, ctxErr
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// Close releases all resources held by the runtime.
+func (c *Command) Close(ctx context.Context) error {
+	return c.runtime.Close(ctx)
+}
@@ -0,0 +1,3 @@
+// Package host implements a subset of the Compute@Edge host ABI on top of an
+// embedded, pure-Go WebAssembly runtime. It is used to run packages locally.
+package host
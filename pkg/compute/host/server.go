@@ -0,0 +1,180 @@
+package host
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Config configures the environment a package is executed in.
+type Config struct {
+	// Backends maps the backend names used by the package to the base URL
+	// which requests sent to that backend are forwarded to.
+	Backends map[string]string
+
+	// Client is used to send requests to backends. If nil, a client which
+	// doesn't follow redirects is used.
+	Client *http.Client
+
+	// Stdout and Stderr receive the output of the package, including writes to
+	// log endpoints. If nil, output is discarded.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Server is an http.Handler which executes a compiled package once for every
+// request it receives, in the same way the Compute@Edge platform does.
+type Server struct {
+	cfg     Config
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	count   uint64
+}
+
+// New compiles the Wasm binary and links it against the host ABI. Imports from
+// Fastly host modules which aren't implemented are linked to stubs returning
+// an unsupported status, so packages using them still run.
+func New(ctx context.Context, wasm []byte, cfg Config) (*Server, error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	if cfg.Stdout == nil {
+		cfg.Stdout = ioutil.Discard
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = ioutil.Discard
+	}
+
+	r, module, err := compile(ctx, wasm, wazero.NewRuntimeConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		cfg:     cfg,
+		runtime: r,
+		module:  module,
+	}, nil
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sess := newSession(&s.cfg, w, r)
+	ctx := context.WithValue(r.Context(), sessionKey{}, sess)
+
+	// Each request gets a fresh instance of the module, which is named so
+	// concurrent requests don't collide in the runtime's namespace.
+	n := atomic.AddUint64(&s.count, 1)
+	config := wazero.NewModuleConfig().
+		WithName(fmt.Sprintf("request-%d", n)).
+		WithArgs("compute-app").
+		WithStdout(s.cfg.Stdout).
+		WithStderr(s.cfg.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	mod, err := s.runtime.InstantiateModule(ctx, s.module, config)
+	if mod != nil {
+		mod.Close(ctx)
+	}
+
+	var exitErr *sys.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 0) {
+		fmt.Fprintf(s.cfg.Stderr, "Error executing package: %v\n", err)
+		if !sess.sent {
+			http.Error(w, "error executing package, see the server output for details", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !sess.sent {
+		http.Error(w, "package did not send a response", http.StatusInternalServerError)
+	}
+}
+
+// Close releases all resources held by the runtime.
+func (s *Server) Close(ctx context.Context) error {
+	return s.runtime.Close(ctx)
+}
+
+// compile returns a runtime with WASI and the host ABI, and the Wasm binary
+// compiled and linked against them.
+func compile(ctx context.Context, wasm []byte, config wazero.RuntimeConfig) (wazero.Runtime, wazero.CompiledModule, error) {
+	r := wazero.NewRuntimeWithConfig(ctx, config)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx)
+		return nil, nil, fmt.Errorf("error instantiating WASI: %w", err)
+	}
+
+	module, err := r.CompileModule(ctx, wasm)
+	if err != nil {
+		r.Close(ctx)
+		return nil, nil, fmt.Errorf("error compiling Wasm binary: %w", err)
+	}
+
+	if err := linkHostModules(ctx, r, module); err != nil {
+		r.Close(ctx)
+		return nil, nil, err
+	}
+
+	return r, module, nil
+}
+
+// linkHostModules instantiates a host module for each Fastly module imported
+// by the compiled module.
+func linkHostModules(ctx context.Context, r wazero.Runtime, module wazero.CompiledModule) error {
+	imports := make(map[string][]api.FunctionDefinition)
+	for _, def := range module.ImportedFunctions() {
+		moduleName, _, _ := def.Import()
+		if moduleName == wasi_snapshot_preview1.ModuleName {
+			continue
+		}
+		if !strings.HasPrefix(moduleName, "fastly_") {
+			return fmt.Errorf("error linking Wasm binary: unsupported import module %q", moduleName)
+		}
+		imports[moduleName] = append(imports[moduleName], def)
+	}
+
+	names := make([]string, 0, len(imports))
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, moduleName := range names {
+		b := r.NewHostModuleBuilder(moduleName)
+		for _, def := range imports[moduleName] {
+			_, name, _ := def.Import()
+			fn, ok := abi[moduleName][name]
+			if !ok {
+				fn = unsupported
+			}
+			b.NewFunctionBuilder().
+				WithGoModuleFunction(fn.goFunction(len(def.ResultTypes())), def.ParamTypes(), def.ResultTypes()).
+				Export(name)
+		}
+		if _, err := b.Instantiate(ctx); err != nil {
+			return fmt.Errorf("error linking host module %s: %w", moduleName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fastly/cli/pkg/testutil"
+)
+
+func TestServer(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Origin", "true")
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.RequestURI())
+	}))
+	defer origin.Close()
+
+	for _, testcase := range []struct {
+		name       string
+		wasm       []byte
+		backends   map[string]string
+		wantStatus int
+		wantHeader http.Header
+		wantBody   string
+		wantError  string
+	}{
+		{
+			name: "synthetic response",
+			wasm: testModule(
+				[]testImport{
+					{"fastly_http_resp", "new", 1},
+					{"fastly_http_body", "new", 1},
+					{"fastly_http_resp", "status_set", 2},
+					{"fastly_http_resp", "header_insert", 5},
+					{"fastly_http_body", "write", 5},
+					{"fastly_http_resp", "send_downstream", 3},
+					{"fastly_geo", "lookup", 4},
+				},
+				map[int]string{16: "hello", 32: "x-test", 48: "ok"},
+				call(0, i32(0)),
+				call(1, i32(4)),
+				call(2, load(0), i32(201)),
+				call(3, load(0), i32(32), i32(6), i32(48), i32(2)),
+				call(4, load(4), i32(16), i32(5), i32(0), i32(8)),
+				call(5, load(0), load(4), i32(0)),
+			),
+			wantStatus: http.StatusCreated,
+			wantHeader: http.Header{"X-Test": []string{"ok"}},
+			wantBody:   "hello",
+		},
+		{
+			name: "backend request",
+			wasm: testModule(
+				[]testImport{
+					{"fastly_http_req", "body_downstream_get", 2},
+					{"fastly_http_req", "send", 6},
+					{"fastly_http_resp", "send_downstream", 3},
+				},
+				map[int]string{32: "origin"},
+				call(0, i32(0), i32(4)),
+				call(1, load(0), load(4), i32(32), i32(6), i32(8), i32(12)),
+				call(2, load(8), load(12), i32(0)),
+			),
+			backends:   map[string]string{"origin": origin.URL},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"X-Origin": []string{"true"}},
+			wantBody:   "GET /path?q=1",
+		},
+		{
+			name: "undefined backend",
+			wasm: testModule(
+				[]testImport{
+					{"fastly_http_req", "body_downstream_get", 2},
+					{"fastly_http_req", "send", 6},
+					{"fastly_http_resp", "send_downstream", 3},
+				},
+				map[int]string{32: "origin"},
+				call(0, i32(0), i32(4)),
+				call(1, load(0), load(4), i32(32), i32(6), i32(8), i32(12)),
+				call(2, load(8), load(12), i32(0)),
+			),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "package did not send a response\n",
+		},
+		{
+			name: "unsupported import module",
+			wasm: testModule(
+				[]testImport{{"env", "abort", 0}},
+				nil,
+			),
+			wantError: `unsupported import module "env"`,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			ctx := context.Background()
+			var stderr bytes.Buffer
+			s, err := New(ctx, testcase.wasm, Config{
+				Backends: testcase.backends,
+				Stderr:   &stderr,
+			})
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err != nil {
+				return
+			}
+			defer s.Close(ctx)
+
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/path?q=1", nil))
+
+			testutil.AssertEqual(t, testcase.wantStatus, rec.Code)
+			testutil.AssertString(t, testcase.wantBody, rec.Body.String())
+			for k := range testcase.wantHeader {
+				testutil.AssertString(t, testcase.wantHeader.Get(k), rec.Header().Get(k))
+			}
+		})
+	}
+}
+
+// testImport is an imported function taking i32 parameters and returning an
+// i32 status, or nothing if it takes no parameters.
+type testImport struct {
+	module string
+	name   string
+	params int
+}
+
+// testModule assembles a Wasm binary which imports the given functions,
+// exports its memory initialized with data, and exports a _start function
+// running the given instructions.
+func testModule(imports []testImport, data map[int]string, instrs ...[]byte) []byte {
+	var types, imps, datas [][]byte
+	for i, imp := range imports {
+		params := bytes.Repeat([]byte{0x7f}, imp.params)
+		var results []byte
+		if imp.params > 0 {
+			results = []byte{0x7f}
+		}
+		types = append(types, cat([]byte{0x60}, vec(len(params), params), vec(len(results), results)))
+		imps = append(imps, cat(name(imp.module), name(imp.name), []byte{0x00}, uleb(uint32(i))))
+	}
+	types = append(types, []byte{0x60, 0x00, 0x00})
+	for offset, s := range data {
+		datas = append(datas, cat([]byte{0x00}, i32(int32(offset)), []byte{0x0b}, name(s)))
+	}
+
+	code := cat(append([]byte{0x00}, cat(instrs...)...), []byte{0x0b})
+	exports := [][]byte{
+		cat(name("_start"), []byte{0x00}, uleb(uint32(len(imports)))),
+		cat(name("memory"), []byte{0x02, 0x00}),
+	}
+
+	return cat(
+		[]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1, vec(len(types), types...)),
+		section(2, vec(len(imps), imps...)),
+		section(3, vec(1, uleb(uint32(len(types)-1)))),
+		section(5, vec(1, []byte{0x00, 0x01})),
+		section(7, vec(len(exports), exports...)),
+		section(10, vec(1, cat(uleb(uint32(len(code))), code))),
+		section(11, vec(len(datas), datas...)),
+	)
+}
+
+// call invokes an imported function and drops its status.
+func call(fn int, args ...[]byte) []byte {
+	return cat(cat(args...), []byte{0x10}, uleb(uint32(fn)), []byte{0x1a})
+}
+
+// load reads the i32 stored at addr.
+func load(addr int32) []byte {
+	return cat(i32(addr), []byte{0x28, 0x02, 0x00})
+}
+
+// i32 pushes the constant v.
+func i32(v int32) []byte {
+	return cat([]byte{0x41}, sleb(v))
+}
+
+func sleb(v int32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func uleb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func name(s string) []byte {
+	return cat(uleb(uint32(len(s))), []byte(s))
+}
+
+func vec(n int, items ...[]byte) []byte {
+	return cat(uleb(uint32(n)), cat(items...))
+}
+
+func section(id byte, content []byte) []byte {
+	return cat([]byte{id}, uleb(uint32(len(content))), content)
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// sessionKey is the context key a session is stored under, so host functions
+// can find the state of the request they're executing on behalf of.
+type sessionKey struct{}
+
+// request is a guest-owned HTTP request.
+type request struct {
+	method  string
+	uri     string
+	header  http.Header
+	version uint32
+}
+
+// response is a guest-owned HTTP response.
+type response struct {
+	status  int
+	header  http.Header
+	version uint32
+}
+
+// body is a guest-owned HTTP body. Bodies are fully buffered, unless they are
+// being streamed to the downstream client.
+type body struct {
+	buf    bytes.Buffer
+	stream http.ResponseWriter
+}
+
+// pending is the result of an asynchronous backend request.
+type pending struct {
+	resp *response
+	body *body
+	err  status
+}
+
+// session holds the state of a single downstream request, including every
+// handle the guest has been given.
+type session struct {
+	cfg        *Config
+	downstream *http.Request
+	w          http.ResponseWriter
+	sent       bool
+
+	next      uint32
+	requests  map[uint32]*request
+	responses map[uint32]*response
+	bodies    map[uint32]*body
+	pending   map[uint32]*pending
+	endpoints []string
+}
+
+func newSession(cfg *Config, w http.ResponseWriter, r *http.Request) *session {
+	return &session{
+		cfg:        cfg,
+		downstream: r,
+		w:          w,
+		requests:   make(map[uint32]*request),
+		responses:  make(map[uint32]*response),
+		bodies:     make(map[uint32]*body),
+		pending:    make(map[uint32]*pending),
+	}
+}
+
+// handle allocates a new handle. Handles are unique across all types, which
+// makes use of a handle of the wrong type easy to detect.
+func (s *session) handle() uint32 {
+	s.next++
+	return s.next
+}
+
+func (s *session) newRequest(r *request) uint32 {
+	h := s.handle()
+	s.requests[h] = r
+	return h
+}
+
+func (s *session) newResponse(r *response) uint32 {
+	h := s.handle()
+	s.responses[h] = r
+	return h
+}
+
+func (s *session) newBody(b *body) uint32 {
+	h := s.handle()
+	s.bodies[h] = b
+	return h
+}
+
+// downstreamRequest converts the request received by the server into a guest
+// request and body.
+func (s *session) downstreamRequest() (*request, *body, error) {
+	r := s.downstream
+
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	b := &body{}
+	if r.Body != nil {
+		if _, err := b.buf.ReadFrom(r.Body); err != nil {
+			return nil, nil, fmt.Errorf("error reading request body: %w", err)
+		}
+	}
+
+	header := r.Header.Clone()
+	header.Set("Host", r.Host)
+
+	return &request{
+		method:  r.Method,
+		uri:     u.String(),
+		header:  header,
+		version: httpVersion(r.ProtoMajor, r.ProtoMinor),
+	}, b, nil
+}
+
+// send forwards a guest request to the address of the named backend, returning
+// the buffered backend response.
+func (s *session) send(req *request, b *body, backend string) (*response, *body, status) {
+	base, ok := s.cfg.Backends[backend]
+	if !ok {
+		fmt.Fprintf(s.cfg.Stderr, "Error sending request: backend %q is not defined in the [local_server] section of the package manifest\n", backend)
+		return nil, nil, statusInval
+	}
+
+	target, err := backendURL(base, req.uri)
+	if err != nil {
+		fmt.Fprintf(s.cfg.Stderr, "Error sending request to backend %q: %v\n", backend, err)
+		return nil, nil, statusInval
+	}
+
+	var payload []byte
+	if b != nil {
+		payload = b.buf.Bytes()
+	}
+
+	out, err := http.NewRequest(req.method, target.String(), bytes.NewReader(payload))
+	if err != nil {
+		fmt.Fprintf(s.cfg.Stderr, "Error sending request to backend %q: %v\n", backend, err)
+		return nil, nil, statusHTTPInvalid
+	}
+	out.Header = req.header.Clone()
+	out.Header.Del("Host")
+	out.Host = target.Host
+
+	resp, err := s.cfg.Client.Do(out)
+	if err != nil {
+		fmt.Fprintf(s.cfg.Stderr, "Error sending request to backend %q: %v\n", backend, err)
+		return nil, nil, statusError
+	}
+	defer resp.Body.Close() // #nosec G307
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(s.cfg.Stderr, "Error reading response from backend %q: %v\n", backend, err)
+		return nil, nil, statusHTTPIncomplete
+	}
+
+	rb := &body{}
+	rb.buf.Write(data)
+
+	return &response{
+		status:  resp.StatusCode,
+		header:  resp.Header.Clone(),
+		version: httpVersion(resp.ProtoMajor, resp.ProtoMinor),
+	}, rb, statusOK
+}
+
+// sendDownstream writes a guest response to the downstream client. If
+// streaming is set the body remains writable, and subsequent writes are sent
+// to the client as they happen.
+func (s *session) sendDownstream(resp *response, b *body, streaming bool) status {
+	if s.sent {
+		return statusError
+	}
+	s.sent = true
+
+	header := s.w.Header()
+	for k, v := range resp.header {
+		header[k] = v
+	}
+	s.w.WriteHeader(resp.status)
+
+	if _, err := s.w.Write(b.buf.Bytes()); err != nil {
+		return statusError
+	}
+	b.buf.Reset()
+
+	if streaming {
+		b.stream = s.w
+		flush(s.w)
+	}
+
+	return statusOK
+}
+
+// backendURL resolves the URI of a guest request against the base URL of a
+// backend, keeping the path and query of the request.
+func backendURL(base, uri string) (*url.URL, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend URL %q: %w", base, err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URI %q: %w", uri, err)
+	}
+
+	target := *b
+	target.Path = path.Join("/", b.Path, u.Path)
+	if strings.HasSuffix(u.Path, "/") && !strings.HasSuffix(target.Path, "/") {
+		target.Path += "/"
+	}
+	target.RawQuery = u.RawQuery
+	return &target, nil
+}
+
+// httpVersion converts a protocol version to the ABI http_version enum.
+func httpVersion(major, minor int) uint32 {
+	switch {
+	case major == 0:
+		return 0
+	case major == 1 && minor == 0:
+		return 1
+	case major == 1:
+		return 2
+	case major == 2:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,184 @@
+// Package ignore matches file paths against the patterns of an ignore file,
+// such as .fastlyignore, using the same rules as .gitignore files.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher reports whether paths are ignored by a set of patterns. Paths are
+// relative to the directory containing the ignore file.
+type Matcher struct {
+	patterns []pattern
+}
+
+// pattern is a single line of an ignore file.
+type pattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// Parse reads the patterns of an ignore file from r.
+//
+// Blank lines and lines starting with # are skipped. A leading ! re-includes
+// a path excluded by an earlier pattern, and a trailing / only matches
+// directories. Patterns containing a / other than a trailing one are anchored
+// to the root, otherwise they match a name at any depth. As well as the * ?
+// and [...] wildcards, a ** segment matches any number of directories.
+func Parse(r io.Reader) (*Matcher, error) {
+	var m Matcher
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := parsePattern(scanner.Text()); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ReadFile reads the patterns of the ignore file at filename. If the file
+// doesn't exist, the returned Matcher ignores nothing.
+func ReadFile(filename string) (*Matcher, error) {
+	// gosec flagged this:
+	// G304 (CWE-22): Potential file inclusion via variable
+	// Disabling as the filename is that of the ignore file in the package
+	// root, provided by the caller.
+	/* #nosec */
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // #nosec G307
+
+	return Parse(f)
+}
+
+// Match reports whether the path, which must be relative to the root of the
+// ignore file, is ignored. As with git, a path inside an ignored directory is
+// ignored and can't be re-included by a negated pattern.
+func (m *Matcher) Match(p string, isDir bool) bool {
+	p = filepath.ToSlash(filepath.Clean(p))
+	if p == "." || len(m.patterns) == 0 {
+		return false
+	}
+
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(parts[:i], true) {
+			return true
+		}
+	}
+	return m.match(parts, isDir)
+}
+
+// match reports whether the last pattern matching the path, given as its
+// segments, excludes it.
+func (m *Matcher) match(parts []string, isDir bool) bool {
+	var ignored bool
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, parts) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// parsePattern parses a line of an ignore file, returning false if the line
+// doesn't contain a pattern.
+func parsePattern(line string) (pattern, bool) {
+	var p pattern
+
+	line = trimTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+
+	// A pattern without a slash matches at any depth, which is the same as
+	// an anchored pattern with a leading ** segment.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	p.segments = strings.Split(line, "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+
+	return p, true
+}
+
+// trimTrailingSpace removes trailing spaces from a line unless they are
+// escaped with a backslash.
+func trimTrailingSpace(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-2] + " "
+	}
+	return line
+}
+
+// matchSegments reports whether the path segments match the pattern segments,
+// where a ** segment matches zero or more path segments.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Consecutive ** segments are equivalent to a single one.
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				// A trailing ** matches everything inside a directory, but
+				// not the directory itself.
+				return len(parts) > 0
+			}
+			for i := 0; i < len(parts); i++ {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], parts[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,151 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fastly/cli/pkg/testutil"
+)
+
+func TestMatch(t *testing.T) {
+	for _, testcase := range []struct {
+		name     string
+		patterns string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{
+			name:     "no patterns",
+			patterns: "",
+			path:     "bin/main.wasm",
+		},
+		{
+			name:     "comment",
+			patterns: "# main.wasm",
+			path:     "main.wasm",
+		},
+		{
+			name:     "escaped comment",
+			patterns: `\#main.wasm`,
+			path:     "#main.wasm",
+			want:     true,
+		},
+		{
+			name:     "unanchored name",
+			patterns: "main.wasm",
+			path:     "bin/main.wasm",
+			want:     true,
+		},
+		{
+			name:     "unanchored glob",
+			patterns: "*.wasm",
+			path:     "bin/debug/main.wasm",
+			want:     true,
+		},
+		{
+			name:     "anchored name",
+			patterns: "/main.wasm",
+			path:     "bin/main.wasm",
+		},
+		{
+			name:     "anchored path",
+			patterns: "bin/main.wasm",
+			path:     "bin/main.wasm",
+			want:     true,
+		},
+		{
+			name:     "anchored path in subdirectory",
+			patterns: "bin/main.wasm",
+			path:     "src/bin/main.wasm",
+		},
+		{
+			name:     "star does not match slash",
+			patterns: "bin/*.wasm",
+			path:     "bin/debug/main.wasm",
+		},
+		{
+			name:     "leading double star",
+			patterns: "**/debug/*.wasm",
+			path:     "bin/debug/main.wasm",
+			want:     true,
+		},
+		{
+			name:     "middle double star",
+			patterns: "src/**/test.rs",
+			path:     "src/test.rs",
+			want:     true,
+		},
+		{
+			name:     "trailing double star",
+			patterns: "src/**",
+			path:     "src/a/b/c.rs",
+			want:     true,
+		},
+		{
+			name:     "trailing double star does not match directory",
+			patterns: "src/**",
+			path:     "src",
+			isDir:    true,
+		},
+		{
+			name:     "directory pattern matches directory",
+			patterns: "debug/",
+			path:     "bin/debug",
+			isDir:    true,
+			want:     true,
+		},
+		{
+			name:     "directory pattern matches contents",
+			patterns: "debug/",
+			path:     "bin/debug/main.wasm",
+			want:     true,
+		},
+		{
+			name:     "directory pattern does not match file",
+			patterns: "debug/",
+			path:     "bin/debug",
+		},
+		{
+			name:     "negation",
+			patterns: "*.wasm\n!keep.wasm",
+			path:     "bin/keep.wasm",
+		},
+		{
+			name:     "last pattern wins",
+			patterns: "!keep.wasm\n*.wasm",
+			path:     "bin/keep.wasm",
+			want:     true,
+		},
+		{
+			name:     "negation inside ignored directory",
+			patterns: "bin/\n!bin/keep.wasm",
+			path:     "bin/keep.wasm",
+			want:     true,
+		},
+		{
+			name:     "escaped negation",
+			patterns: `\!important`,
+			path:     "!important",
+			want:     true,
+		},
+		{
+			name:     "trailing spaces",
+			patterns: "main.wasm   ",
+			path:     "main.wasm",
+			want:     true,
+		},
+		{
+			name:     "character class",
+			patterns: "main.[ow]*",
+			path:     "main.wasm",
+			want:     true,
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			m, err := Parse(strings.NewReader(testcase.patterns))
+			testutil.AssertNoError(t, err)
+			testutil.AssertBool(t, testcase.want, m.Match(testcase.path, testcase.isDir))
+		})
+	}
+}
@@ -14,26 +14,29 @@ import (
 	"time"
 
 	"github.com/dustinkirkland/golang-petname"
+	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
 	"github.com/fastly/cli/pkg/compute/manifest"
 	"github.com/fastly/cli/pkg/config"
 	"github.com/fastly/cli/pkg/errors"
 	"github.com/fastly/cli/pkg/text"
 	"github.com/fastly/go-fastly/fastly"
-	"gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
 type template struct {
-	Name string
-	Path string
+	Name   string
+	Path   string
+	Branch string
 }
 
 const (
-	defaultTemplate       = "https://github.com/fastly/fastly-template-rust-default.git"
-	defaultTemplateBranch = "0.3.0"
-	defaultTopLevelDomain = "edgecompute.app"
-	manageServiceBaseURL  = "https://manage.fastly.com/configure/services/"
+	defaultTemplate               = "https://github.com/fastly/fastly-template-rust-default.git"
+	defaultTemplateBranch         = "0.3.0"
+	defaultAssemblyScriptTemplate = "https://github.com/fastly/compute-starter-kit-assemblyscript-default.git"
+	defaultGoTemplate             = "https://github.com/fastly/compute-starter-kit-go-default.git"
+	defaultJavaScriptTemplate     = "https://github.com/fastly/compute-starter-kit-javascript-default.git"
+	defaultTopLevelDomain         = "edgecompute.app"
+	manageServiceBaseURL          = "https://manage.fastly.com/configure/services/"
 )
 
 var (
@@ -41,63 +44,84 @@ var (
 	domainNameRegEx           = regexp.MustCompile(`(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9][a-z0-9-]{0,61}[a-z0-9]`)
 	fastlyOrgRegEx            = regexp.MustCompile(`^https:\/\/github\.com\/fastly`)
 	fastlyFileIgnoreListRegEx = regexp.MustCompile(`\.github|LICENSE|SECURITY\.md`)
-	defaultTemplates          = map[int]template{
-		1: {
-			Name: "Starter kit",
-			Path: defaultTemplate,
-		},
-	}
 )
 
 // InitCommand initializes a Compute@Edge project package on the local machine.
 type InitCommand struct {
 	common.Base
-	serviceID   string
-	name        string
-	description string
-	author      string
-	from        string
-	branch      string
-	path        string
-	domain      string
-	backend     string
+	client         api.HTTPClient
+	serviceID      string
+	name           string
+	description    string
+	author         string
+	language       string
+	from           string
+	branch         string
+	path           string
+	domain         string
+	backend        string
+	nonInteractive bool
+	acceptDefaults bool
+	localOnly      bool
+	jsonEvents     bool
 }
 
 // NewInitCommand returns a usable command registered under the parent.
-func NewInitCommand(parent common.Registerer, globals *config.Data) *InitCommand {
+func NewInitCommand(parent common.Registerer, client api.HTTPClient, globals *config.Data) *InitCommand {
 	var c InitCommand
 	c.Globals = globals
+	c.client = client
 	c.CmdClause = parent.Command("init", "Initialize a new Compute@Edge package locally")
 	c.CmdClause.Flag("service-id", "Existing service ID to use. By default, this command creates a new service").Short('s').StringVar(&c.serviceID)
 	c.CmdClause.Flag("name", "Name of package, defaulting to directory name of the --path destination").Short('n').StringVar(&c.name)
 	c.CmdClause.Flag("description", "Description of the package").Short('d').StringVar(&c.description)
 	c.CmdClause.Flag("author", "Author of the package").Short('a').StringVar(&c.author)
-	c.CmdClause.Flag("from", "Git repository containing package template").Short('f').StringVar(&c.from)
+	c.CmdClause.Flag("language", "Language of the package").Short('l').StringVar(&c.language)
+	c.CmdClause.Flag("from", "Package template: a Git repository URL, local directory, .tar.gz archive, or org/repo[/subdir]@tag on GitHub").Short('f').StringVar(&c.from)
 	c.CmdClause.Flag("branch", "Git branch name to clone from package template repository").Hidden().StringVar(&c.branch)
 	c.CmdClause.Flag("path", "Destination to write the new package, defaulting to the current directory").Short('p').StringVar(&c.path)
-	c.CmdClause.Flag("domain", "The name of the domain associated to the package").StringVar(&c.path)
-	c.CmdClause.Flag("backend", "A hostname, IPv4, or IPv6 address for the package backend").StringVar(&c.path)
+	c.CmdClause.Flag("domain", "The name of the domain associated to the package").StringVar(&c.domain)
+	c.CmdClause.Flag("backend", "A hostname, IPv4, or IPv6 address for the package backend").StringVar(&c.backend)
+	c.CmdClause.Flag("non-interactive", "Don't prompt for input, using defaults for values which aren't provided, except the language or template").BoolVar(&c.nonInteractive)
+	c.CmdClause.Flag("accept-defaults", "Don't prompt for input, using defaults for every value which isn't provided").BoolVar(&c.acceptDefaults)
+	c.CmdClause.Flag("local-only", "Only create the package locally, deferring creating the service until the package is first deployed").BoolVar(&c.localOnly)
+	c.CmdClause.Flag("json-events", "Write progress as newline-delimited JSON events, for CI systems, instead of text, without prompting for input").BoolVar(&c.jsonEvents)
 
 	return &c
 }
 
 // Exec implements the command interface.
 func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
-	// Exit early if no token configured.
+	if c.localOnly && c.serviceID != "" {
+		return fmt.Errorf("--local-only can't be used with --service-id, as the package would use an existing service")
+	}
+
+	// Exit early if no token configured. A local only package doesn't
+	// require one until it is deployed.
 	_, source := c.Globals.Token()
-	if source == config.SourceUndefined {
+	if source == config.SourceUndefined && !c.localOnly {
 		return errors.ErrNoToken
 	}
 
-	text.Output(out, "This utility will walk you through creating a Compute@Edge project. It only covers the most common items, and tries to guess sensible defaults.")
-	text.Break(out)
-	text.Output(out, "Press ^C at any time to quit.")
-	text.Break(out)
+	interactive := !c.nonInteractive && !c.acceptDefaults && !c.jsonEvents
 
+	if interactive {
+		text.Output(out, "This utility will walk you through creating a Compute@Edge project. It only covers the most common items, and tries to guess sensible defaults.")
+		text.Break(out)
+		text.Output(out, "Press ^C at any time to quit.")
+		text.Break(out)
+	}
+
+	// JSON events replace all of the text output, so the messages written
+	// once the package is initialized are written as events too.
 	var progress text.Progress
-	if c.Globals.Verbose() {
+	switch {
+	case c.jsonEvents:
+		progress = text.NewJSONProgress(out)
+		out = progress
+	case c.Globals.Verbose():
 		progress = text.NewVerboseProgress(out)
-	} else {
+	default:
 		// Use a null progress writer whilst gathering input.
 		progress = text.NewNullProgress()
 	}
@@ -136,6 +160,17 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		c.path = path
 	}
 
+	languages := newLanguages(toolchainOptions{})
+
+	var language *Language
+	if c.language != "" {
+		l, ok := getLanguage(languages, c.language)
+		if !ok {
+			return fmt.Errorf("unsupported language %s", c.language)
+		}
+		language = l
+	}
+
 	abspath, err := verifyDestination(c.path, progress)
 	if err != nil {
 		return err
@@ -146,16 +181,18 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		c.name = filepath.Base(c.path)
 		fmt.Fprintf(progress, "--name not specified, using %s\n\n", c.name)
 
-		name, err := text.Input(out, fmt.Sprintf("Name: [%s] ", c.name), in)
-		if err != nil {
-			return fmt.Errorf("error reading input: %w", err)
-		}
-		if name != "" {
-			c.name = name
+		if interactive {
+			name, err := text.Input(out, fmt.Sprintf("Name: [%s] ", c.name), in)
+			if err != nil {
+				return fmt.Errorf("error reading input: %w", err)
+			}
+			if name != "" {
+				c.name = name
+			}
 		}
 	}
 
-	if c.description == "" {
+	if c.description == "" && interactive {
 		c.description, err = text.Input(out, "Description: ", in)
 		if err != nil {
 			return fmt.Errorf("error reading input: %w", err)
@@ -170,21 +207,53 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 			label = fmt.Sprintf("%s[%s] ", label, email)
 		}
 
-		c.author, err = text.Input(out, label, in)
-		if err != nil {
-			return fmt.Errorf("error reading input %w", err)
+		if interactive {
+			c.author, err = text.Input(out, label, in)
+			if err != nil {
+				return fmt.Errorf("error reading input %w", err)
+			}
 		}
 		if c.author == "" {
 			c.author = defaultEmail
 		}
 	}
 
+	if language == nil && c.from == "" && !interactive {
+		if !c.acceptDefaults {
+			return errors.RemediationError{
+				Inner:       fmt.Errorf("a language or template is required when not prompting for input"),
+				Remediation: fmt.Sprintf("To fix this error, provide the --language or --from flag, or accept the default language:\n\n\t$ %s", text.Bold("fastly compute init --accept-defaults")),
+			}
+		}
+		language = languages[0]
+	}
+
+	if language == nil && c.from == "" {
+		text.Output(out, "%s", text.Bold("Language:"))
+		for i, l := range languages {
+			text.Output(out, "[%d] %s", i+1, l.DisplayName)
+		}
+		option, err := text.Input(out, "Choose option: [1] ", in, validateLanguageOption(languages))
+		if err != nil {
+			return fmt.Errorf("error reading input %w", err)
+		}
+		if option == "" {
+			option = "1"
+		}
+		i, _ := strconv.Atoi(option)
+		language = languages[i-1]
+	}
+
+	if c.from == "" && !interactive {
+		c.from = language.StarterKits[0].Path
+	}
+
 	if c.from == "" {
 		text.Output(out, "%s", text.Bold("Template:"))
-		for i, template := range defaultTemplates {
-			text.Output(out, "[%d] %s (%s)", i, template.Name, template.Path)
+		for i, kit := range language.StarterKits {
+			text.Output(out, "[%d] %s (%s)", i+1, kit.Name, kit.Path)
 		}
-		template, err := text.Input(out, "Choose option or type URL: [1] ", in, validateTemplateOptionOrURL)
+		template, err := text.Input(out, "Choose option or type URL: [1] ", in, validateTemplateOptionOrURL(language.StarterKits))
 		if err != nil {
 			return fmt.Errorf("error reading input %w", err)
 		}
@@ -192,36 +261,37 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 			template = "1"
 		}
 		if i, err := strconv.Atoi(template); err == nil {
-			template = defaultTemplates[i].Path
+			template = language.StarterKits[i-1].Path
 		}
 		c.from = template
 	}
 
-	if c.domain == "" {
-		mathRand.Seed(time.Now().UnixNano())
-		defaultDomain := fmt.Sprintf("%s.%s", petname.Generate(3, "-"), defaultTopLevelDomain)
-		c.domain, err = text.Input(out, fmt.Sprintf("Domain: [%s] ", defaultDomain), in, validateDomain)
+	// The domain and backend of a local only package are chosen when its
+	// service is created by the first deploy.
+	if c.domain == "" && !c.localOnly {
+		c.domain, err = inputDomain(in, out, interactive)
 		if err != nil {
-			return fmt.Errorf("error reading input %w", err)
-		}
-		if c.domain == "" {
-			c.domain = defaultDomain
+			return err
 		}
 	}
 
-	if c.backend == "" {
-		c.backend, err = text.Input(out, "Backend (originless, hostname or IP address): [originless] ", in, validateBackend)
+	if !c.localOnly {
+		c.backend, err = inputBackend(in, out, c.backend, interactive)
 		if err != nil {
-			return fmt.Errorf("error reading input %w", err)
-		}
-		if c.backend == "" || c.backend == "originless" {
-			c.backend = "127.0.0.1"
+			return err
 		}
 	}
 
-	text.Break(out)
+	tmpl, err := parseTemplateSource(c.from)
+	if err != nil {
+		return fmt.Errorf("invalid template %s: %w", c.from, err)
+	}
+
+	if interactive {
+		text.Break(out)
+	}
 
-	if !c.Globals.Verbose() {
+	if !c.Globals.Verbose() && !c.jsonEvents {
 		progress = text.NewQuietProgress(out)
 	}
 
@@ -252,58 +322,23 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		}
 
 		version = v.Number
-	} else {
-		progress.Step("Creating service...")
-		service, err = c.Globals.Client.CreateService(&fastly.CreateServiceInput{
-			Name:    c.name,
-			Type:    "wasm",
-			Comment: c.description,
-		})
+	} else if !c.localOnly {
+		service, err = createService(c.Globals.Client, c.name, c.description, progress, undoStack)
 		if err != nil {
-			return fmt.Errorf("error creating service: %w", err)
+			return err
 		}
 		version = 1
-		undoStack.Push(func() error {
-			return c.Globals.Client.DeleteService(&fastly.DeleteServiceInput{
-				ID: service.ID,
-			})
-		})
 	}
 
-	progress.Step("Creating domain...")
-	_, err = c.Globals.Client.CreateDomain(&fastly.CreateDomainInput{
-		Service: service.ID,
-		Version: version,
-		Name:    c.domain,
-	})
-	if err != nil {
-		return fmt.Errorf("error creating domain: %w", err)
-	}
-	undoStack.Push(func() error {
-		return c.Globals.Client.DeleteDomain(&fastly.DeleteDomainInput{
-			Service: service.ID,
-			Version: version,
-			Name:    c.domain,
-		})
-	})
+	// A local only package has no service until it is deployed.
+	var serviceID string
+	if service != nil {
+		serviceID = service.ID
 
-	progress.Step("Creating backend...")
-	_, err = c.Globals.Client.CreateBackend(&fastly.CreateBackendInput{
-		Service: service.ID,
-		Version: version,
-		Name:    c.backend,
-		Address: c.backend,
-	})
-	if err != nil {
-		return fmt.Errorf("error creating backend: %w", err)
+		if err := createDomainAndBackend(c.Globals.Client, serviceID, version, c.domain, c.backend, progress, undoStack); err != nil {
+			return err
+		}
 	}
-	undoStack.Push(func() error {
-		return c.Globals.Client.DeleteBackend(&fastly.DeleteBackendInput{
-			Service: service.ID,
-			Version: version,
-			Name:    c.backend,
-		})
-	})
 
 	progress.Step("Fetching package template...")
 	tempdir, err := tempDir("package-init")
@@ -312,35 +347,40 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 	}
 	defer os.RemoveAll(tempdir)
 
-	var ref plumbing.ReferenceName
-	if c.from == defaultTemplate {
-		ref = plumbing.NewBranchReferenceName(defaultTemplateBranch)
+	// Starter kits are pinned to a known good branch, unless one is provided.
+	if c.branch == "" {
+		for _, l := range languages {
+			for _, kit := range l.StarterKits {
+				if kit.Path == c.from {
+					c.branch = kit.Branch
+				}
+			}
+		}
 	}
+
 	if c.branch != "" {
-		ref = plumbing.NewBranchReferenceName(c.branch)
+		tmpl.Ref = c.branch
 	}
 
-	if _, err := git.PlainClone(tempdir, false, &git.CloneOptions{
-		URL:           c.from,
-		ReferenceName: ref,
-		Depth:         1,
-		Progress:      progress,
-	}); err != nil {
+	root, err := tmpl.fetch(c.client, tempdir, progress)
+	if err != nil {
 		return fmt.Errorf("error fetching package template: %w", err)
 	}
 
-	if err := os.RemoveAll(filepath.Join(tempdir, ".git")); err != nil {
-		return fmt.Errorf("error removing git metadata from package template: %w", err)
-	}
+	variables := templateVariables(c.name, serviceID, c.author)
 
-	if err := filepath.Walk(tempdir, func(path string, info os.FileInfo, err error) error {
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err // abort
 		}
 		if info.IsDir() {
+			// Skip the Git metadata of the package template.
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
 			return nil // descend
 		}
-		rel, err := filepath.Rel(tempdir, path)
+		rel, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
@@ -352,7 +392,7 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
 			return err
 		}
-		if err := common.CopyFile(path, dst); err != nil {
+		if err := copyTemplateFile(path, dst, variables); err != nil {
 			return err
 		}
 		return nil
@@ -380,8 +420,18 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		m.Authors = []string{c.author}
 	}
 
-	fmt.Fprintf(progress, "Setting service ID in manifest to %q...\n", service.ID)
-	m.ServiceID = service.ID
+	// A [language.<name>] section of settings in the template manifest
+	// already sets the language, so it is kept.
+	if language != nil && m.Language != language.Name {
+		fmt.Fprintf(progress, "Setting language in manifest to %s...\n", language.Name)
+		m.Language, m.LanguageConfig = language.Name, nil
+	}
+
+	// A local only package has no service ID until it is first deployed.
+	if serviceID != "" {
+		fmt.Fprintf(progress, "Setting service ID in manifest to %q...\n", serviceID)
+	}
+	m.ServiceID = serviceID
 
 	fmt.Fprintf(progress, "Setting version in manifest to 1...\n")
 	m.Version = 1
@@ -390,19 +440,130 @@ func (c *InitCommand) Exec(in io.Reader, out io.Writer) (err error) {
 		return fmt.Errorf("error saving package manifest: %w", err)
 	}
 
+	text.Artifact(progress, "path", abspath)
+	if serviceID != "" {
+		text.Artifact(progress, "service_id", serviceID)
+		text.Artifact(progress, "version", version)
+	}
 	progress.Done()
 
 	text.Break(out)
 
 	text.Description(out, fmt.Sprintf("Initialized package %s to", text.Bold(m.Name)), abspath)
-	text.Description(out, "Manage this service at", fmt.Sprintf("%s%s", manageServiceBaseURL, service.ID))
+
+	if serviceID == "" {
+		text.Description(out, "To compile the package, run", "fastly compute build")
+		text.Description(out, "To create a service and deploy the package, run", "fastly compute deploy")
+
+		text.Success(out, "Initialized package %s locally", m.Name)
+		return nil
+	}
+
+	text.Description(out, "Manage this service at", fmt.Sprintf("%s%s", manageServiceBaseURL, serviceID))
 	text.Description(out, "To compile the package, run", "fastly compute build")
 	text.Description(out, "To deploy the package, run", "fastly compute deploy")
 
-	text.Success(out, "Initialized service %s", service.ID)
+	text.Success(out, "Initialized service %s", serviceID)
 	return nil
 }
 
+// createService creates a Compute@Edge service, pushing its deletion onto
+// the undo stack.
+func createService(client api.Interface, name, description string, progress text.Progress, undoStack *common.UndoStack) (*fastly.Service, error) {
+	progress.Step("Creating service...")
+	service, err := client.CreateService(&fastly.CreateServiceInput{
+		Name:    name,
+		Type:    "wasm",
+		Comment: description,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating service: %w", err)
+	}
+	undoStack.Push(func() error {
+		return client.DeleteService(&fastly.DeleteServiceInput{
+			ID: service.ID,
+		})
+	})
+	return service, nil
+}
+
+// createDomainAndBackend creates the domain and backend of a service version,
+// pushing their deletion onto the undo stack.
+func createDomainAndBackend(client api.Interface, serviceID string, version int, domain, backend string, progress text.Progress, undoStack *common.UndoStack) error {
+	progress.Step("Creating domain...")
+	_, err := client.CreateDomain(&fastly.CreateDomainInput{
+		Service: serviceID,
+		Version: version,
+		Name:    domain,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating domain: %w", err)
+	}
+	undoStack.Push(func() error {
+		return client.DeleteDomain(&fastly.DeleteDomainInput{
+			Service: serviceID,
+			Version: version,
+			Name:    domain,
+		})
+	})
+
+	progress.Step("Creating backend...")
+	_, err = client.CreateBackend(&fastly.CreateBackendInput{
+		Service: serviceID,
+		Version: version,
+		Name:    backend,
+		Address: backend,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating backend: %w", err)
+	}
+	undoStack.Push(func() error {
+		return client.DeleteBackend(&fastly.DeleteBackendInput{
+			Service: serviceID,
+			Version: version,
+			Name:    backend,
+		})
+	})
+
+	return nil
+}
+
+// inputDomain returns the domain of a new service, prompting for it if
+// interactive. The default is a random subdomain of edgecompute.app.
+func inputDomain(in io.Reader, out io.Writer, interactive bool) (string, error) {
+	mathRand.Seed(time.Now().UnixNano())
+	defaultDomain := fmt.Sprintf("%s.%s", petname.Generate(3, "-"), defaultTopLevelDomain)
+	if !interactive {
+		return defaultDomain, nil
+	}
+
+	domain, err := text.Input(out, fmt.Sprintf("Domain: [%s] ", defaultDomain), in, validateDomain)
+	if err != nil {
+		return "", fmt.Errorf("error reading input %w", err)
+	}
+	if domain == "" {
+		domain = defaultDomain
+	}
+	return domain, nil
+}
+
+// inputBackend returns the backend address of a new service, prompting for
+// it if interactive and not already provided. The default is originless,
+// which is represented by 127.0.0.1.
+func inputBackend(in io.Reader, out io.Writer, backend string, interactive bool) (string, error) {
+	if backend == "" && interactive {
+		var err error
+		backend, err = text.Input(out, "Backend (originless, hostname or IP address): [originless] ", in, validateBackend)
+		if err != nil {
+			return "", fmt.Errorf("error reading input %w", err)
+		}
+	}
+	if backend == "" || backend == "originless" {
+		backend = "127.0.0.1"
+	}
+	return backend, nil
+}
+
 func verifyDestination(path string, verbose io.Writer) (abspath string, err error) {
 	abspath, err = filepath.Abs(path)
 	if err != nil {
@@ -468,21 +629,35 @@ func tempDir(prefix string) (abspath string, err error) {
 	return abspath, nil
 }
 
-func validateTemplateOptionOrURL(input string) error {
-	msg := "must be a valid option or Git URL"
-	if input == "" {
-		return nil
+func validateLanguageOption(languages []*Language) func(string) error {
+	return func(input string) error {
+		if input == "" {
+			return nil
+		}
+		if option, err := strconv.Atoi(input); err == nil && option > 0 && option <= len(languages) {
+			return nil
+		}
+		return fmt.Errorf("must be a valid option")
 	}
-	if option, err := strconv.Atoi(input); err == nil {
-		if _, ok := defaultTemplates[option]; !ok {
+}
+
+func validateTemplateOptionOrURL(kits []template) func(string) error {
+	return func(input string) error {
+		msg := "must be a valid option, Git URL, local directory, .tar.gz archive, or org/repo[/subdir]@tag"
+		if input == "" {
+			return nil
+		}
+		if option, err := strconv.Atoi(input); err == nil {
+			if option < 1 || option > len(kits) {
+				return fmt.Errorf(msg)
+			}
+			return nil
+		}
+		if _, err := parseTemplateSource(input); err != nil {
 			return fmt.Errorf(msg)
 		}
 		return nil
 	}
-	if !gitRepositoryRegEx.MatchString(input) {
-		return fmt.Errorf(msg)
-	}
-	return nil
 }
 
 func validateBackend(input string) error {
@@ -0,0 +1,229 @@
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/compute/wasm"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+	"github.com/kennygrant/sanitize"
+	"github.com/mholt/archiver/v3"
+)
+
+// inspection is a report of what takes up the space in a package, and the
+// host calls its Wasm binary imports.
+type inspection struct {
+	Path             string         `json:"path"`
+	CompressedSize   int64          `json:"compressed_size"`
+	UncompressedSize int64          `json:"uncompressed_size"`
+	WasmPath         string         `json:"wasm_path"`
+	WasmSize         int            `json:"wasm_size"`
+	DebugSize        int            `json:"debug_size"`
+	Sections         []sectionSize  `json:"sections"`
+	Functions        []functionSize `json:"functions"`
+	Imports          []hostCall     `json:"imports"`
+}
+
+// sectionSize is the size of a section of the Wasm binary, including its
+// header. Name is set for custom sections, and Debug for those holding DWARF
+// debug information.
+type sectionSize struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Size  int    `json:"size"`
+	Debug bool   `json:"debug,omitempty"`
+}
+
+// functionSize is the size of the body of a function defined by the Wasm
+// binary. Name is taken from the name section, if present.
+type functionSize struct {
+	Index uint32 `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Size  int    `json:"size"`
+}
+
+// hostCall is a function imported by the Wasm binary from the host.
+type hostCall struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+}
+
+// inspectPackage reads the package archive at path and reports the sizes of
+// the package, the sections of its Wasm binary and its largest functions, of
+// which at most top are reported.
+func inspectPackage(path string, top int) (*inspection, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading package: %w", err)
+	}
+
+	report := inspection{
+		Path:           path,
+		CompressedSize: fi.Size(),
+	}
+
+	err = archiver.Walk(path, func(f archiver.File) error {
+		if _, ok := packageFilePath(f); ok {
+			report.UncompressedSize += f.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading package: %w", err)
+	}
+
+	var data []byte
+	report.WasmPath, data, err = readPackageWasm(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := wasm.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: malformed Wasm module: %w", report.WasmPath, err)
+	}
+	report.WasmSize = len(data)
+
+	report.Sections = make([]sectionSize, 0, len(m.Sections))
+	for _, s := range m.Sections {
+		size := sectionSize{ID: s.ID.String(), Name: s.Name, Size: s.Size}
+		if s.ID == wasm.SectionCustom && strings.HasPrefix(s.Name, ".debug_") {
+			size.Debug = true
+			report.DebugSize += s.Size
+		}
+		report.Sections = append(report.Sections, size)
+	}
+
+	functions := make([]wasm.Function, len(m.Functions))
+	copy(functions, m.Functions)
+	sort.SliceStable(functions, func(i, j int) bool {
+		return functions[i].Size > functions[j].Size
+	})
+	if len(functions) > top {
+		functions = functions[:top]
+	}
+	report.Functions = make([]functionSize, 0, len(functions))
+	for _, fn := range functions {
+		report.Functions = append(report.Functions, functionSize{fn.Index, fn.Name, fn.Size})
+	}
+
+	report.Imports = []hostCall{}
+	for _, imp := range m.Imports {
+		if imp.Kind == wasm.KindFunction {
+			report.Imports = append(report.Imports, hostCall{imp.Module, imp.Name})
+		}
+	}
+
+	return &report, nil
+}
+
+// percent formats size as a percentage of total.
+func percent(size, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(size)/float64(total)*100)
+}
+
+// InspectCommand reports what takes up the space in a package.
+type InspectCommand struct {
+	common.Base
+	path   string
+	top    int
+	format string
+}
+
+// NewInspectCommand returns a usable command registered under the parent.
+func NewInspectCommand(parent common.Registerer, globals *config.Data) *InspectCommand {
+	var c InspectCommand
+	c.Globals = globals
+	c.CmdClause = parent.Command("inspect", "Report the size and host calls of a Compute@Edge package")
+	c.CmdClause.Flag("path", "Path to package").Short('p').StringVar(&c.path)
+	c.CmdClause.Flag("top", "Number of the largest functions to report").Default("10").IntVar(&c.top)
+	c.CmdClause.Flag("format", "Output format (json)").EnumVar(&c.format, "json")
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *InspectCommand) Exec(in io.Reader, out io.Writer) error {
+	if c.top < 1 {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("invalid --top value %d", c.top),
+			Remediation: "Set the --top flag to the number of the largest functions to report, which must be at least 1.",
+		}
+	}
+
+	// If path flag was empty, default to package tar inside pkg directory
+	// and get filename from the manifest.
+	if c.path == "" {
+		var m manifest.File
+		if err := m.Read(ManifestFilename); err != nil {
+			return fmt.Errorf("error reading package manifest: %w", err)
+		}
+		c.path = filepath.Join("pkg", fmt.Sprintf("%s.tar.gz", sanitize.BaseName(m.Name)))
+	}
+
+	report, err := inspectPackage(c.path, c.top)
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		if err := json.NewEncoder(out).Encode(report); err != nil {
+			return fmt.Errorf("error writing package inspection: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(out, "Package: %s\n", report.Path)
+	fmt.Fprintf(out, "Compressed size: %d bytes\n", report.CompressedSize)
+	fmt.Fprintf(out, "Uncompressed size: %d bytes\n", report.UncompressedSize)
+	fmt.Fprintf(out, "Wasm binary: %s (%d bytes, of which %d bytes are debug information)\n", report.WasmPath, report.WasmSize, report.DebugSize)
+
+	text.Break(out)
+	tw := text.NewTable(out)
+	tw.AddHeader("SECTION", "NAME", "SIZE", "%")
+	for _, s := range report.Sections {
+		tw.AddLine(s.ID, s.Name, s.Size, percent(s.Size, report.WasmSize))
+	}
+	tw.Print()
+
+	text.Break(out)
+	if len(report.Functions) == 0 {
+		text.Info(out, "The Wasm binary defines no functions.")
+	} else {
+		tw = text.NewTable(out)
+		tw.AddHeader("INDEX", "FUNCTION", "SIZE", "%")
+		for _, fn := range report.Functions {
+			name := fn.Name
+			if name == "" {
+				name = "-"
+			}
+			tw.AddLine(fn.Index, name, fn.Size, percent(fn.Size, report.WasmSize))
+		}
+		tw.Print()
+	}
+
+	text.Break(out)
+	if len(report.Imports) == 0 {
+		text.Info(out, "The Wasm binary imports no host calls.")
+	} else {
+		tw = text.NewTable(out)
+		tw.AddHeader("MODULE", "HOST CALL")
+		for _, imp := range report.Imports {
+			tw.AddLine(imp.Module, imp.Name)
+		}
+		tw.Print()
+	}
+
+	return nil
+}
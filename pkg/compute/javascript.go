@@ -0,0 +1,107 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+const (
+	// JSComputeVersionConstraint is the semver constraint for the versions of
+	// the @fastly/js-compute package, which provides the JavaScript runtime
+	// and compiler, that we support.
+	JSComputeVersionConstraint = ">= 0.2.0"
+	// JavaScriptEntrypoint is the source file the JavaScript compiler builds
+	// the package from.
+	JavaScriptEntrypoint = "src/index.js"
+)
+
+// JavaScript implements Toolchain for the JavaScript language, which is
+// compiled to Wasm together with a JavaScript engine by js-compute-runtime.
+type JavaScript struct{}
+
+// Verify implements the Toolchain interface and verifies whether the
+// JavaScript language toolchain is correctly configured on the host.
+func (j JavaScript) Verify(out io.Writer) error {
+	// 1) Check Node.js, npm and package.json
+	//
+	// The JavaScript compiler is installed locally to the package by npm.
+
+	if err := verifyNPM(out); err != nil {
+		return err
+	}
+
+	// 2) Verify the `@fastly/js-compute` package version
+	//
+	// We use npm to list the packages installed locally and assert that a
+	// version of the compiler matching our constraint is present.
+
+	fmt.Fprintf(out, "Checking if @fastly/js-compute is installed...\n")
+
+	return verifyNPMPackage(out, "@fastly/js-compute", JSComputeVersionConstraint)
+}
+
+// Build implements the Toolchain interface and attempts to compile the package
+// JavaScript source to a Wasm binary.
+func (j JavaScript) Build(out io.Writer, verbose bool) error {
+	// Get working directory.
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	binDir := filepath.Join(dir, "bin")
+	if err := createBinDirectory(binDir); err != nil {
+		return err
+	}
+
+	// The compiler is installed by npm along with the @fastly/js-compute
+	// package, so it is missing if the package dependencies weren't installed.
+	compiler := filepath.Join(dir, "node_modules", ".bin", "js-compute-runtime")
+	if !common.FileExists(compiler) {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("js-compute-runtime not found in %s", filepath.Join("node_modules", ".bin")),
+			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s", text.Bold("npm install")),
+		}
+	}
+
+	args := []string{
+		JavaScriptEntrypoint,
+		filepath.Join(binDir, "main.wasm"),
+	}
+
+	// Call the locally installed js-compute-runtime compiler.
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the variables come from trusted sources.
+	/* #nosec */
+	cmd := exec.Command(compiler, args...)
+
+	return streamCommand(cmd, out, verbose)
+}
+
+// BuildTests implements the Toolchain interface. JavaScript has no test
+// harness which can be compiled to a WASI binary, so it isn't supported.
+func (j JavaScript) BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error) {
+	return nil, errTestsUnsupported("javascript")
+}
+
+// Version implements the Toolchain interface and returns the version of the
+// @fastly/js-compute package installed locally to the package.
+func (j JavaScript) Version() (string, error) {
+	stdout, err := exec.Command("npm", "list", "--json", "--depth", "0", "@fastly/js-compute").Output()
+	if err != nil {
+		return "", fmt.Errorf("error executing npm list: %w", err)
+	}
+	version, err := getNPMPackageVersion(stdout, "@fastly/js-compute")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("@fastly/js-compute %s", version), nil
+}
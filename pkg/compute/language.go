@@ -0,0 +1,115 @@
+package compute
+
+import (
+	"strings"
+
+	"github.com/fastly/cli/pkg/api"
+)
+
+// Language models a Compute@Edge source language, including the toolchain
+// used to build it and the files which make up a package of it.
+type Language struct {
+	Name            string
+	DisplayName     string
+	StarterKits     []template
+	SourceDirectory string
+	IncludeFiles    []string
+
+	Toolchain
+}
+
+// toolchainOptions configures the toolchains of the supported languages.
+// Offline toolchains don't check remote sources, such as crates.io, for the
+// latest versions of dependencies. The Rust binary and package choose what
+// to build from a Cargo workspace.
+type toolchainOptions struct {
+	client         api.HTTPClient
+	cratesEndpoint string
+	crateCacheFile string
+	offline        bool
+	rustToolchain  string
+	rustBin        string
+	rustPackage    string
+}
+
+// newLanguages returns all of the supported source languages, in the order
+// they are offered by the init command.
+func newLanguages(opts toolchainOptions) []*Language {
+	return []*Language{
+		{
+			Name:        "rust",
+			DisplayName: "Rust",
+			StarterKits: []template{
+				{
+					Name:   "Starter kit",
+					Path:   defaultTemplate,
+					Branch: defaultTemplateBranch,
+				},
+			},
+			SourceDirectory: "src",
+			IncludeFiles:    []string{"Cargo.toml"},
+			Toolchain: &Rust{
+				crates: crateRegistry{
+					client:    opts.client,
+					endpoint:  opts.cratesEndpoint,
+					cacheFile: opts.crateCacheFile,
+				},
+				offline:   opts.offline,
+				toolchain: opts.rustToolchain,
+				bin:       opts.rustBin,
+				pkg:       opts.rustPackage,
+			},
+		},
+		{
+			Name:        "assemblyscript",
+			DisplayName: "AssemblyScript",
+			StarterKits: []template{
+				{
+					Name: "Starter kit",
+					Path: defaultAssemblyScriptTemplate,
+				},
+			},
+			SourceDirectory: "assembly",
+			IncludeFiles:    []string{"package.json"},
+			Toolchain:       &AssemblyScript{},
+		},
+		{
+			Name:        "go",
+			DisplayName: "Go",
+			StarterKits: []template{
+				{
+					Name: "Starter kit",
+					Path: defaultGoTemplate,
+				},
+			},
+			SourceDirectory: ".",
+			IncludeFiles:    []string{"go.mod"},
+			Toolchain:       &Go{},
+		},
+		{
+			Name:        "javascript",
+			DisplayName: "JavaScript",
+			StarterKits: []template{
+				{
+					Name: "Starter kit",
+					Path: defaultJavaScriptTemplate,
+				},
+			},
+			SourceDirectory: "src",
+			IncludeFiles:    []string{"package.json"},
+			Toolchain:       &JavaScript{},
+		},
+	}
+}
+
+// getLanguage returns the language with the given name, which is matched
+// case-insensitively.
+func getLanguage(languages []*Language, name string) (*Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, l := range languages {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return nil, false
+}
@@ -1,6 +1,9 @@
 package manifest
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 
 	"github.com/BurntSushi/toml"
@@ -45,12 +48,22 @@ func (d *Data) Name() (string, Source) {
 	return "", SourceUndefined
 }
 
-// ServiceID yields a ServiceID.
+// ServiceID yields a ServiceID. When an environment is selected, the service
+// ID is taken from its [env.<name>] section rather than the top level of the
+// manifest file, so that a missing or misspelt environment never falls back to
+// another environment's service.
 func (d *Data) ServiceID() (string, Source) {
 	if d.Flag.ServiceID != "" {
 		return d.Flag.ServiceID, SourceFlag
 	}
 
+	if d.Flag.Env != "" {
+		if env, ok := d.File.Env[d.Flag.Env]; ok && env.ServiceID != "" {
+			return env.ServiceID, SourceFile
+		}
+		return "", SourceUndefined
+	}
+
 	if d.File.ServiceID != "" {
 		return d.File.ServiceID, SourceFile
 	}
@@ -58,19 +71,162 @@ func (d *Data) ServiceID() (string, Source) {
 	return "", SourceUndefined
 }
 
+// SetServiceID sets the service ID in the manifest file, in the [env.<name>]
+// section of the selected environment if there is one.
+func (d *Data) SetServiceID(serviceID string) {
+	if d.Flag.Env == "" {
+		d.File.ServiceID = serviceID
+		return
+	}
+
+	if d.File.Env == nil {
+		d.File.Env = make(map[string]Env)
+	}
+	env := d.File.Env[d.Flag.Env]
+	env.ServiceID = serviceID
+	d.File.Env[d.Flag.Env] = env
+}
+
 // File represents all of the configuration parameters in the fastly.toml
 // manifest file schema.
+//
+// The language is either set by name, such as `language = "rust"`, or by a
+// table of settings for it, such as a [language.rust] section, in which case
+// the table name is the language name and LanguageConfig holds its settings.
 type File struct {
-	Version     int      `toml:"version"`
-	Name        string   `toml:"name"`
-	Description string   `toml:"description"`
-	Authors     []string `toml:"authors"`
-	Language    string   `toml:"language"`
-	ServiceID   string   `toml:"service_id"`
+	Version        int                    `toml:"version"`
+	Name           string                 `toml:"name"`
+	Description    string                 `toml:"description"`
+	Authors        []string               `toml:"authors"`
+	Language       string                 `toml:"language,omitempty"`
+	LanguageConfig map[string]interface{} `toml:"-"`
+	ServiceID      string                 `toml:"service_id,omitempty"`
+	LocalServer    *LocalServer           `toml:"local_server"`
+	Scripts        *Scripts               `toml:"scripts"`
+	Build          *Build                 `toml:"build,omitempty"`
+	Env            map[string]Env         `toml:"env,omitempty"`
+}
+
+// language decodes the language of the manifest, which is either a name or
+// a single [language.<name>] table of settings.
+type language struct {
+	name   string
+	config map[string]interface{}
+}
+
+// UnmarshalTOML implements the toml.Unmarshaler interface.
+func (l *language) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		l.name = v
+		return nil
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for name, config := range v {
+				table, ok := config.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("language.%s must be a table", name)
+				}
+				l.name, l.config = name, table
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("language must be a name or a single [language.<name>] section")
+}
+
+// Decode decodes the TOML data of a manifest into f, returning the metadata
+// of the keys which were decoded. The keys of a [language.<name>] section are
+// settings of the language, which aren't decoded.
+func Decode(data string, f *File) (toml.MetaData, error) {
+	// The language field shadows the one in the embedded File, so that it
+	// can be decoded from either form.
+	d := struct {
+		File
+		Language language `toml:"language"`
+	}{File: *f}
+	md, err := toml.Decode(data, &d)
+	if err != nil {
+		return md, err
+	}
+	*f = d.File
+	f.Language, f.LanguageConfig = d.Language.name, d.Language.config
+	return md, nil
+}
+
+// Env represents an [env.<name>] section of the manifest, which overrides
+// settings of the package when the environment is selected with --env, such as
+// to deploy the same package to staging and production services.
+type Env struct {
+	ServiceID string `toml:"service_id,omitempty"`
+}
+
+// RustSettings returns the settings of the [language.rust] section of the
+// manifest, which are empty if the section isn't present.
+func (f *File) RustSettings() (RustSettings, error) {
+	var settings RustSettings
+	if f.Language != "rust" {
+		return settings, nil
+	}
+
+	for key, value := range f.LanguageConfig {
+		var ok bool
+		switch key {
+		case "toolchain":
+			if settings.Toolchain, ok = value.(string); !ok {
+				return settings, fmt.Errorf("language.rust.toolchain must be a string")
+			}
+		default:
+			return settings, fmt.Errorf("unknown key %q in language.rust", key)
+		}
+	}
+
+	return settings, nil
+}
+
+// RustSettings represents the [language.rust] section of the fastly.toml
+// manifest. Toolchain is the rustup toolchain used to build the package, such
+// as 1.46.0 or stable.
+type RustSettings struct {
+	Toolchain string
+}
+
+// LocalServer represents the [local_server] section of the fastly.toml
+// manifest, which configures the environment used by `compute serve`.
+type LocalServer struct {
+	Backends map[string]LocalBackend `toml:"backends"`
+}
+
+// LocalBackend maps a backend name used by the package to a local address,
+// such as http://127.0.0.1:8080, which requests are forwarded to.
+type LocalBackend struct {
+	URL string `toml:"url"`
+}
+
+// Scripts represents the [scripts] section of the fastly.toml manifest, which
+// defines shell commands run by `compute build`. If Build is set it replaces
+// the language toolchain, and PreBuild and PostBuild run either side of it.
+type Scripts struct {
+	Build     string `toml:"build"`
+	PreBuild  string `toml:"pre_build"`
+	PostBuild string `toml:"post_build"`
+}
+
+// Build represents the [build] section of the fastly.toml manifest, which
+// configures how `compute build` packages the Wasm binary. Strip removes its
+// debug information and other custom sections, and KeepDebug keeps the
+// unstripped binary beside the package.
+type Build struct {
+	Strip     bool `toml:"strip"`
+	KeepDebug bool `toml:"keep_debug"`
 }
 
 func (f *File) Read(filename string) error {
-	_, err := toml.DecodeFile(filename, f)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	_, err = Decode(string(data), f)
 	return err
 }
 
@@ -79,7 +235,7 @@ func (f *File) Write(filename string) error {
 	if err != nil {
 		return err
 	}
-	if err := toml.NewEncoder(fp).Encode(f); err != nil {
+	if err := encode(fp, f); err != nil {
 		return err
 	}
 	if err := fp.Sync(); err != nil {
@@ -91,8 +247,29 @@ func (f *File) Write(filename string) error {
 	return nil
 }
 
+// encode writes the manifest as TOML. A language with settings is written as
+// its [language.<name>] section, after the rest of the manifest.
+func encode(w io.Writer, f *File) error {
+	if f.LanguageConfig == nil {
+		return toml.NewEncoder(w).Encode(f)
+	}
+
+	m := *f
+	m.Language = ""
+	if err := toml.NewEncoder(w).Encode(m); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+	return toml.NewEncoder(w).Encode(map[string]interface{}{
+		"language": map[string]interface{}{f.Language: f.LanguageConfig},
+	})
+}
+
 // Flag represents all of the manifest parameters that can be set with explicit
 // flags. Consumers should bind their flag values to these fields directly.
 type Flag struct {
 	ServiceID string
+	Env       string
 }
@@ -0,0 +1,89 @@
+package compute
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PackageMetadataFilename is the name of the file embedded in the root of a
+// package archive which records the hashes of the package contents.
+const PackageMetadataFilename = "package-metadata.json"
+
+// PackageMetadata models the metadata file embedded in a package archive. Files
+// maps each file path, relative to the package root and using forward slashes,
+// to the SHA-256 hash of its contents. Hash is the SHA-256 hash of the package
+// as a whole, which is derived from the file hashes.
+type PackageMetadata struct {
+	Hash  string            `json:"hash"`
+	Files map[string]string `json:"files"`
+}
+
+// newPackageMetadata hashes the given files, which are relative to the root
+// directory on the local disk, and returns the metadata for a package made up
+// of them.
+func newPackageMetadata(root string, files []string) (PackageMetadata, error) {
+	m := PackageMetadata{Files: make(map[string]string)}
+	for _, f := range files {
+		h, err := hashFile(filepath.Join(root, f), sha256.New())
+		if err != nil {
+			return m, fmt.Errorf("error hashing file: %w", err)
+		}
+		m.Files[filepath.ToSlash(filepath.Clean(f))] = h
+	}
+	m.Hash = m.packageHash()
+	return m, nil
+}
+
+// packageHash returns the hash of the package as a whole, which is the
+// SHA-256 hash of each file path and hash in path order.
+func (m PackageMetadata) packageHash() string {
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s  %s\n", m.Files[p], p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Write writes the metadata as JSON to the given filename.
+func (m PackageMetadata) Write(filename string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0600)
+}
+
+// getPackageHashSum returns the hex encoded SHA-512 hash of the package archive
+// at path, which is the hash the Fastly API reports for uploaded packages.
+func getPackageHashSum(path string) (string, error) {
+	return hashFile(path, sha512.New())
+}
+
+// hashFile returns the hex encoded hash of the contents of the file at path.
+func hashFile(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // #nosec G307
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
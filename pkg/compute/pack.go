@@ -0,0 +1,143 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+	"github.com/kennygrant/sanitize"
+)
+
+// PackCommand produces a deployable package from a Wasm binary built outside
+// of the CLI, without running any language toolchain.
+type PackCommand struct {
+	common.Base
+	wasmBinary   string
+	manifestPath string
+	name         string
+}
+
+// NewPackCommand returns a usable command registered under the parent.
+func NewPackCommand(parent common.Registerer, globals *config.Data) *PackCommand {
+	var c PackCommand
+	c.Globals = globals
+	c.CmdClause = parent.Command("pack", "Package a prebuilt Wasm binary as a Compute@Edge package")
+	c.CmdClause.Flag("wasm-binary", "Path to the Wasm binary").Required().StringVar(&c.wasmBinary)
+	c.CmdClause.Flag("manifest", "Path to the package manifest, which is generated from the package name if it doesn't exist").Default(ManifestFilename).StringVar(&c.manifestPath)
+	c.CmdClause.Flag("name", "Package name, which must match the name in the package manifest if it sets one").StringVar(&c.name)
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *PackCommand) Exec(in io.Reader, out io.Writer) (err error) {
+	var progress text.Progress
+	if c.Globals.Verbose() {
+		progress = text.NewVerboseProgress(out)
+	} else {
+		progress = text.NewQuietProgress(out)
+	}
+
+	defer func() {
+		if err != nil {
+			progress.Fail() // progress.Done is handled inline
+		}
+	}()
+
+	progress.Step("Reading package manifest...")
+
+	// A missing manifest is only an error if its path was given explicitly,
+	// as a package built outside of the CLI may not have one.
+	var (
+		m            manifest.File
+		manifestData []byte
+	)
+	if common.FileExists(c.manifestPath) {
+		manifestData, err = ioutil.ReadFile(c.manifestPath)
+		if err != nil {
+			return fmt.Errorf("error reading package manifest: %w", err)
+		}
+		if err := m.Read(c.manifestPath); err != nil {
+			return fmt.Errorf("error reading package manifest: %w", err)
+		}
+	} else if c.manifestPath != ManifestFilename {
+		return fmt.Errorf("error reading package manifest: %s not found", c.manifestPath)
+	}
+
+	// The name is read from the manifest in the package by deploy, so a
+	// different name given by flag would be ignored there.
+	if c.name != "" && m.Name != "" && c.name != m.Name {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("--name %s doesn't match the name %s in the package manifest", c.name, m.Name),
+			Remediation: fmt.Sprintf("Remove the --name flag, or set the name in %s to %s.", text.Bold(c.manifestPath), c.name),
+		}
+	}
+
+	var name string
+	if c.name != "" {
+		name = c.name
+	} else if m.Name != "" {
+		name = m.Name
+	} else {
+		return fmt.Errorf("name cannot be empty, please provide a name")
+	}
+
+	progress.Step("Creating package archive...")
+
+	// The package files are staged in the layout createPackageArchive
+	// expects, with the manifest at the root and the binary in bin/.
+	root, err := ioutil.TempDir("", "fastly-pack")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(root)
+
+	if m.Name == "" || manifestData == nil {
+		fmt.Fprintf(progress, "Setting name in manifest to %s...\n", name)
+		m.Name = name
+		if err := m.Write(filepath.Join(root, ManifestFilename)); err != nil {
+			return fmt.Errorf("error writing package manifest: %w", err)
+		}
+	} else if err := ioutil.WriteFile(filepath.Join(root, ManifestFilename), manifestData, 0600); err != nil {
+		return fmt.Errorf("error writing package manifest: %w", err)
+	}
+
+	wasmPath := filepath.Join("bin", "main.wasm")
+	if err := common.CopyFile(c.wasmBinary, filepath.Join(root, wasmPath)); err != nil {
+		return fmt.Errorf("error copying Wasm binary: %w", err)
+	}
+
+	dest := filepath.Join("pkg", fmt.Sprintf("%s.tar.gz", sanitize.BaseName(name)))
+	if err := createPackageArchive(root, []string{ManifestFilename, wasmPath}, dest); err != nil {
+		return fmt.Errorf("error creating package archive: %w", err)
+	}
+
+	progress.Step("Validating package...")
+
+	problems, err := validatePackage(dest, defaultValidateLimits)
+	if err != nil {
+		return err
+	}
+	errs, warnings := splitProblems(problems)
+	if len(errs) > 0 {
+		os.Remove(dest)
+		return problemsError(errs, packRemediation)
+	}
+
+	progress.Done()
+
+	printWarnings(out, warnings)
+
+	text.Success(out, "Packed package %s (%s)", name, dest)
+	return nil
+}
+
+// packRemediation suggests fixing a prebuilt Wasm binary or manifest which
+// doesn't make a valid package.
+var packRemediation = fmt.Sprintf("To fix this error, fix the problems found in the Wasm binary or package manifest and pack it again:\n\n\t$ %s", text.Bold("fastly compute pack --wasm-binary <path>"))
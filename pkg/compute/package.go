@@ -0,0 +1,54 @@
+package compute
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mholt/archiver/v3"
+)
+
+// packageWasmFilename is the name of the Wasm binary in a package archive.
+const packageWasmFilename = "main.wasm"
+
+// readPackageWasm returns the path relative to the package root and the
+// contents of the Wasm binary in the package archive at path, which is the
+// first regular file named main.wasm.
+func readPackageWasm(path string) (string, []byte, error) {
+	var (
+		name string
+		data []byte
+	)
+	err := archiver.Walk(path, func(f archiver.File) error {
+		p, ok := packageFilePath(f)
+		if !ok || f.Name() != packageWasmFilename || data != nil {
+			return nil
+		}
+		b, err := ioutil.ReadAll(f)
+		name, data = p, b
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading package: %w", err)
+	}
+	if data == nil {
+		return "", nil, fmt.Errorf("error reading package: package must contain a %s file", packageWasmFilename)
+	}
+	return name, data, nil
+}
+
+// packageFilePath returns the path of a regular file in a package archive
+// relative to the package root, which is the top-level directory of the
+// archive.
+func packageFilePath(f archiver.File) (string, bool) {
+	hdr, ok := f.Header.(*tar.Header)
+	if !ok || hdr.Typeflag != tar.TypeReg {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(hdr.Name, "./"), "/", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	return parts[1], true
+}
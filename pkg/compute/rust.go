@@ -13,20 +13,23 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/Masterminds/semver/v3"
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/ignore"
 	"github.com/fastly/cli/pkg/errors"
 	"github.com/fastly/cli/pkg/text"
 )
 
 const (
 	// RustToolchainVersion is the `rustup` toolchain string for the compiler
-	// that we support
+	// that we support, which is used unless the package sets a toolchain.
 	RustToolchainVersion = "1.43.0"
+	// RustToolchainConstraint is the range of compiler versions that we
+	// support, which a toolchain set by the package must satisfy.
+	RustToolchainConstraint = ">= 1.43.0"
 	// WasmWasiTarget is the Rust compilation target for Wasi capable Wasm.
 	WasmWasiTarget = "wasm32-wasi"
 )
@@ -38,6 +41,28 @@ type CargoPackage struct {
 	Name         string         `toml:"name" json:"name"`
 	Version      string         `toml:"version" json:"version"`
 	Dependencies []CargoPackage `toml:"-" json:"dependencies"`
+	ID           string         `toml:"-" json:"id"`
+	Source       string         `toml:"-" json:"source"`
+	ManifestPath string         `toml:"-" json:"manifest_path"`
+	Targets      []CargoTarget  `toml:"-" json:"targets"`
+}
+
+// CargoTarget models a target of a Rust Cargo package, such as a binary or
+// library, read from `cargo metadata` command output.
+type CargoTarget struct {
+	Name    string   `json:"name"`
+	Kind    []string `json:"kind"`
+	SrcPath string   `json:"src_path"`
+}
+
+// isBin reports whether the target is a binary.
+func (t CargoTarget) isBin() bool {
+	for _, k := range t.Kind {
+		if k == "bin" {
+			return true
+		}
+	}
+	return false
 }
 
 // CargoManifest models the package confuiguration properties of a Rust Cargo
@@ -55,13 +80,35 @@ func (m *CargoManifest) Read(filename string) error {
 
 // CargoMetadata models information about the workspace members and resolved
 // dependencies of the current package via `cargo metadata` command output.
+// The target directory is where cargo writes build output, which is set by
+// the workspace or the CARGO_TARGET_DIR environment variable.
 type CargoMetadata struct {
-	Package []CargoPackage `json:"packages"`
+	Package          []CargoPackage `json:"packages"`
+	WorkspaceMembers []string       `json:"workspace_members"`
+	WorkspaceRoot    string         `json:"workspace_root"`
+	TargetDirectory  string         `json:"target_directory"`
 }
 
-// Read the contents of the Cargo.lock file from filename.
-func (m *CargoMetadata) Read() error {
-	cmd := exec.Command("cargo", "metadata", "--format-version", "1")
+// Read the contents of the Cargo.lock file from filename. Offline reads don't
+// access the network to resolve dependencies.
+func (m *CargoMetadata) Read(offline bool) error {
+	args := []string{"metadata", "--format-version", "1"}
+	if offline {
+		args = append(args, "--offline")
+	}
+	return m.read(args)
+}
+
+// ReadWorkspace reads the metadata of the workspace members only, without
+// resolving their dependencies.
+func (m *CargoMetadata) ReadWorkspace() error {
+	return m.read([]string{"metadata", "--format-version", "1", "--no-deps"})
+}
+
+func (m *CargoMetadata) read(args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("cargo", args...)
+	cmd.Stderr = &stderr
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
@@ -70,6 +117,10 @@ func (m *CargoMetadata) Read() error {
 		return err
 	}
 	if err := json.NewDecoder(stdout).Decode(&m); err != nil {
+		cmd.Wait() // #nosec G104
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+		}
 		return err
 	}
 	if err := cmd.Wait(); err != nil {
@@ -78,9 +129,80 @@ func (m *CargoMetadata) Read() error {
 	return nil
 }
 
-// Rust is an implments Toolchain for the Rust lanaguage.
+// cargoBinary is a binary target of a package in a Cargo workspace.
+type cargoBinary struct {
+	Package string
+	Name    string
+}
+
+// binaries returns the binary targets which cargo builds from the directory
+// of the manifest at manifestPath: those of the named package if set,
+// otherwise of the package at manifestPath if it's a workspace member, and
+// otherwise of every workspace member, as for the root of a virtual
+// workspace.
+func (m CargoMetadata) binaries(pkg, manifestPath string) ([]cargoBinary, error) {
+	members := make(map[string]bool, len(m.WorkspaceMembers))
+	for _, id := range m.WorkspaceMembers {
+		members[id] = true
+	}
+
+	var packages []CargoPackage
+	for _, p := range m.Package {
+		if !members[p.ID] {
+			continue
+		}
+		if pkg != "" && p.Name == pkg {
+			packages = []CargoPackage{p}
+			break
+		}
+		if pkg == "" && sameFile(p.ManifestPath, manifestPath) {
+			packages = []CargoPackage{p}
+			break
+		}
+		if pkg == "" {
+			packages = append(packages, p)
+		}
+	}
+	if pkg != "" && len(packages) == 0 {
+		return nil, fmt.Errorf("package %s isn't a member of the cargo workspace", pkg)
+	}
+
+	var bins []cargoBinary
+	for _, p := range packages {
+		for _, t := range p.Targets {
+			if t.isBin() {
+				bins = append(bins, cargoBinary{Package: p.Name, Name: t.Name})
+			}
+		}
+	}
+	return bins, nil
+}
+
+// sameFile reports whether the paths are of the same file, which cargo may
+// report by a different path, such as one with symlinks resolved.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+// Rust is an implments Toolchain for the Rust lanaguage. The binary built is
+// chosen by bin and pkg if there is more than one in the Cargo workspace.
 type Rust struct {
-	client api.HTTPClient
+	crates    crateRegistry
+	offline   bool
+	toolchain string
+	bin       string
+	pkg       string
 }
 
 // Verify implments the Toolchain interface and verifies whether the Rust
@@ -105,12 +227,18 @@ func (r Rust) Verify(out io.Writer) error {
 
 	fmt.Fprintf(out, "Found rustup at %s\n", p)
 
-	// 2) Check that the `1.43.0` toolchain is installed
+	// 2) Check that the toolchain is installed
 	//
-	// We use rustup to assert that the toolchain is installed by streaming the output of
-	// `rustup toolchain list` and looking for a toolchain whose prefix matches our desired
-	// version.
-	fmt.Fprintf(out, "Checking if Rust %s is installed...\n", RustToolchainVersion)
+	// The toolchain is set by the package, otherwise we default to `1.43.0`.
+	// We use rustup to assert that the toolchain is installed by streaming the
+	// output of `rustup toolchain list` and looking for a toolchain whose name
+	// matches our desired toolchain.
+	toolchain, source, err := r.resolveToolchain()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Checking if Rust %s is installed...\n", toolchain)
 
 	cmd := exec.Command("rustup", "toolchain", "list")
 	stdoutStderr, err := cmd.CombinedOutput()
@@ -122,7 +250,7 @@ func (r Rust) Verify(out io.Writer) error {
 	scanner.Split(bufio.ScanLines)
 	var found bool
 	for scanner.Scan() {
-		if strings.HasPrefix(scanner.Text(), RustToolchainVersion) {
+		if name := strings.Fields(scanner.Text()); len(name) > 0 && (name[0] == toolchain || strings.HasPrefix(name[0], toolchain+"-")) {
 			found = true
 			break
 		}
@@ -130,11 +258,32 @@ func (r Rust) Verify(out io.Writer) error {
 
 	if !found {
 		return errors.RemediationError{
-			Inner:       fmt.Errorf("rust toolchain %s not found", RustToolchainVersion),
-			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s\n", text.Bold("rustup toolchain install "+RustToolchainVersion)),
+			Inner:       fmt.Errorf("rust toolchain %s not found", toolchain),
+			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s\n", text.Bold("rustup toolchain install "+toolchain)),
 		}
 	}
 
+	// 2.1) Check the toolchain is a supported version
+	//
+	// A toolchain set by the package may be a channel, such as `stable`, so we
+	// ask its compiler for the version.
+	cmd = exec.Command("rustc", "+"+toolchain, "--version")
+	stdoutStderr, err = cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error executing rustc: %w", err)
+	}
+
+	version, err := parseRustcVersion(string(stdoutStderr))
+	if err != nil {
+		return err
+	}
+
+	if err := checkRustToolchainVersion(toolchain, source, version); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Found Rust %s (%s)\n", toolchain, version)
+
 	// 3) Check `wasm32-wasi` target exists
 	//
 	// We use rustup to assert that the target is installed for our toolchain by streaming the
@@ -143,7 +292,7 @@ func (r Rust) Verify(out io.Writer) error {
 
 	fmt.Fprintf(out, "Checking if %s target is installed...\n", WasmWasiTarget)
 
-	cmd = exec.Command("rustup", "target", "list", "--installed", "--toolchain", RustToolchainVersion)
+	cmd = exec.Command("rustup", "target", "list", "--installed", "--toolchain", toolchain)
 	stdoutStderr, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error executing rustup: %w", err)
@@ -162,7 +311,7 @@ func (r Rust) Verify(out io.Writer) error {
 	if !found {
 		return errors.RemediationError{
 			Inner:       fmt.Errorf("rust target %s not found", WasmWasiTarget),
-			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s\n", text.Bold(fmt.Sprintf("rustup target add %s --toolchain %s", WasmWasiTarget, RustToolchainVersion))),
+			Remediation: fmt.Sprintf("To fix this error, run the following command:\n\n\t$ %s\n", text.Bold(fmt.Sprintf("rustup target add %s --toolchain %s", WasmWasiTarget, toolchain))),
 		}
 	}
 
@@ -192,16 +341,16 @@ func (r Rust) Verify(out io.Writer) error {
 	}
 
 	var metadata CargoMetadata
-	if err := metadata.Read(); err != nil {
+	if err := metadata.Read(r.offline); err != nil {
 		return fmt.Errorf("error reading cargo metadata: %w", err)
 	}
 
 	// Fetch the latest crate versions from cargo.io API.
-	latestFastly, err := getLatestCrateVersion(r.client, "fastly")
+	latestFastly, err := r.latestCrateVersion(out, metadata, "fastly")
 	if err != nil {
 		return fmt.Errorf("error fetching latest crate version: %w", err)
 	}
-	latestFastlySys, err := getLatestCrateVersion(r.client, "fastly-sys")
+	latestFastlySys, err := r.latestCrateVersion(out, metadata, "fastly-sys")
 	if err != nil {
 		return fmt.Errorf("error fetching latest crate version: %w", err)
 	}
@@ -250,30 +399,46 @@ func (r Rust) Verify(out io.Writer) error {
 // Build implments the Toolchain interface and attempts to compile the package
 // Rust source to a Wasm binary.
 func (r Rust) Build(out io.Writer, verbose bool) error {
-	// Get binary name from Cargo.toml.
-	var m CargoManifest
-	if err := m.Read("Cargo.toml"); err != nil {
-		return fmt.Errorf("error reading Cargo.toml manifest: %w", err)
+	// The binary to build is chosen from the targets of the Cargo workspace,
+	// which may have more than one package and binary.
+	var metadata CargoMetadata
+	if err := metadata.ReadWorkspace(); err != nil {
+		return fmt.Errorf("error reading cargo metadata: %w", err)
+	}
+
+	bin, err := r.resolveBinary(metadata)
+	if err != nil {
+		return err
 	}
-	binName := m.Package.Name
 
-	// Specify the toolchain using the `cargo +<version>` syntax.
-	toolchain := fmt.Sprintf("+%s", RustToolchainVersion)
+	toolchain, _, err := r.resolveToolchain()
+	if err != nil {
+		return err
+	}
 
+	// Specify the toolchain using the `cargo +<version>` syntax. Cargo writes
+	// its build messages as JSON, which report the path of the Wasm binary.
 	args := []string{
-		toolchain,
+		"+" + toolchain,
 		"build",
 		"--bin",
-		binName,
+		bin.Name,
+		"--package",
+		bin.Package,
 		"--release",
 		"--target",
 		WasmWasiTarget,
 		"--color",
 		"always",
+		"--message-format",
+		"json-diagnostic-rendered-ansi",
 	}
 	if verbose {
 		args = append(args, "--verbose")
 	}
+	if r.offline {
+		args = append(args, "--offline")
+	}
 
 	// Call cargo build with Wasm Wasi target and release flags.
 	// gosec flagged this:
@@ -288,77 +453,482 @@ func (r Rust) Build(out io.Writer, verbose bool) error {
 		`RUSTFLAGS=-C debuginfo=2`,
 	)
 
-	// Pipe the child process stdout and stderr to our own writer.
-	var stdoutBuf, stderrBuf bytes.Buffer
-	stdoutIn, _ := cmd.StdoutPipe()
-	stderrIn, _ := cmd.StderrPipe()
-	stdout := io.MultiWriter(out, &stdoutBuf)
-	stderr := io.MultiWriter(out, &stderrBuf)
+	messages := &cargoMessages{out: out, bin: bin.Name}
+	if err := streamCommandOutput(cmd, messages, out, verbose); err != nil {
+		// The compiler errors are reported by the build messages rather
+		// than stderr, so they're returned as the error instead.
+		if !verbose && messages.errors.Len() > 0 {
+			return fmt.Errorf("error during compilation process:\n%s", strings.TrimSpace(messages.errors.String()))
+		}
+		return err
+	}
 
-	// Start the command.
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start compilation process: %w", err)
+	// Cargo reports the binary of every build, even if it was up to date,
+	// but the path in the target directory is used if it wasn't reported.
+	src := messages.artifact
+	if src == "" {
+		src = filepath.Join(metadata.TargetDirectory, WasmWasiTarget, "release", fmt.Sprintf("%s.wasm", bin.Name))
+	}
+
+	// Get working directory.
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
 	}
+	dst := filepath.Join(dir, "bin", "main.wasm")
 
-	var errStdout, errStderr error
-	var wg sync.WaitGroup
-	wg.Add(1)
+	if err := createBinDirectory(filepath.Join(dir, "bin")); err != nil {
+		return err
+	}
 
-	go func() {
-		_, errStdout = io.Copy(stdout, stdoutIn)
-		wg.Done()
-	}()
+	err = common.CopyFile(src, dst)
+	if err != nil {
+		return fmt.Errorf("error copying wasm binary: %w", err)
+	}
 
-	_, errStderr = io.Copy(stderr, stderrIn)
-	wg.Wait()
+	return nil
+}
 
-	if errStdout != nil {
-		return fmt.Errorf("error streaming stdout output from child process: %w", errStdout)
+// BuildTests implements the Toolchain interface and compiles the tests of the
+// package, or of the workspace members if it's the root of a virtual
+// workspace, to libtest binaries. Cargo writes them to its target directory,
+// so dir isn't used.
+func (r Rust) BuildTests(out io.Writer, dir string, verbose bool) ([]TestBinary, error) {
+	toolchain, _, err := r.resolveToolchain()
+	if err != nil {
+		return nil, err
 	}
-	if errStderr != nil {
-		return fmt.Errorf("error streaming stderr output from child process: %w", errStderr)
+
+	args := []string{
+		"+" + toolchain,
+		"test",
+		"--no-run",
+		"--target",
+		WasmWasiTarget,
+		"--color",
+		"always",
+		"--message-format",
+		"json-diagnostic-rendered-ansi",
+	}
+	if r.pkg != "" {
+		args = append(args, "--package", r.pkg)
+	}
+	if verbose {
+		args = append(args, "--verbose")
+	}
+	if r.offline {
+		args = append(args, "--offline")
 	}
 
-	// Wait for the command to exit.
-	if err := cmd.Wait(); err != nil {
-		// If we're not in verbose mode return the bufferred stderr output
-		// from cargo as the error.
-		if !verbose && stderrBuf.Len() > 0 {
-			return fmt.Errorf("error during compilation process:\n%s", strings.TrimSpace(stderrBuf.String()))
+	// gosec flagged this:
+	// G204 (CWE-78): Subprocess launched with variable
+	// Disabling as the variables come from trusted sources.
+	/* #nosec */
+	cmd := exec.Command("cargo", args...)
+
+	messages := &cargoMessages{out: out}
+	if err := streamCommandOutput(cmd, messages, out, verbose); err != nil {
+		if !verbose && messages.errors.Len() > 0 {
+			return nil, fmt.Errorf("error during compilation process:\n%s", strings.TrimSpace(messages.errors.String()))
 		}
-		return fmt.Errorf("error during compilation process")
+		return nil, err
 	}
 
-	// Get working directory.
-	dir, err := os.Getwd()
+	return messages.tests, nil
+}
+
+// resolveBinary returns the binary target to build, which is the only one of
+// the package in the current directory, or of the workspace members if it's
+// the root of a virtual workspace, unless chosen by the bin and pkg options.
+func (r Rust) resolveBinary(metadata CargoMetadata) (cargoBinary, error) {
+	manifestPath, err := filepath.Abs("Cargo.toml")
 	if err != nil {
-		return fmt.Errorf("error getting current working directory: %w", err)
+		return cargoBinary{}, fmt.Errorf("error getting Cargo.toml path: %w", err)
+	}
+	return selectBinary(metadata, r.pkg, r.bin, manifestPath)
+}
+
+// selectBinary returns the binary target named bin, or the only one if bin
+// is empty, of those cargo builds from the directory of the manifest at
+// manifestPath.
+func selectBinary(metadata CargoMetadata, pkg, bin, manifestPath string) (cargoBinary, error) {
+	bins, err := metadata.binaries(pkg, manifestPath)
+	if err != nil {
+		return cargoBinary{}, errors.RemediationError{
+			Inner:       err,
+			Remediation: "To fix this error, set the --package flag to the name of a package in the workspace.",
+		}
+	}
+
+	var (
+		matches []cargoBinary
+		names   []string
+	)
+	for _, b := range bins {
+		names = append(names, b.Name)
+		if bin == "" || b.Name == bin {
+			matches = append(matches, b)
+		}
 	}
-	src := filepath.Join(dir, "target", WasmWasiTarget, "release", "deps", fmt.Sprintf("%s.wasm", binName))
-	dst := filepath.Join(dir, "bin", "main.wasm")
 
-	// Check if bin directory exists and create if not.
-	binDir := filepath.Join(dir, "bin")
-	fi, err := os.Stat(binDir)
 	switch {
-	case err == nil && fi.IsDir():
-		// no problem
-	case err == nil && !fi.IsDir():
-		return fmt.Errorf("error creating bin directory: target already exists as a regular file")
-	case os.IsNotExist(err):
-		if err := os.MkdirAll(binDir, 0750); err != nil {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(bins) == 0 && pkg != "":
+		return cargoBinary{}, fmt.Errorf("package %s has no binary targets", pkg)
+	case len(bins) == 0:
+		return cargoBinary{}, fmt.Errorf("no binary targets found in the cargo workspace")
+	case len(matches) == 0:
+		return cargoBinary{}, errors.RemediationError{
+			Inner:       fmt.Errorf("binary target %s not found in the cargo workspace", bin),
+			Remediation: fmt.Sprintf("To fix this error, set the --bin flag to one of the binary targets: %s.", strings.Join(names, ", ")),
+		}
+	default:
+		return cargoBinary{}, errors.RemediationError{
+			Inner:       fmt.Errorf("the cargo workspace has more than one binary target: %s", strings.Join(names, ", ")),
+			Remediation: fmt.Sprintf("To fix this error, choose the binary to build with the --bin flag, such as:\n\n\t$ %s", text.Bold(fmt.Sprintf("fastly compute build --bin %s", names[0]))),
+		}
+	}
+}
+
+// cargoMessage models the build messages which cargo writes as JSON, of which
+// we are interested in compiler diagnostics and the artifacts of targets.
+type cargoMessage struct {
+	Reason     string      `json:"reason"`
+	Target     CargoTarget `json:"target"`
+	Filenames  []string    `json:"filenames"`
+	Executable string      `json:"executable"`
+	Profile    struct {
+		Test bool `json:"test"`
+	} `json:"profile"`
+	Message struct {
+		Level    string `json:"level"`
+		Rendered string `json:"rendered"`
+	} `json:"message"`
+}
+
+// cargoMessages is an io.Writer which parses the build messages cargo writes
+// to stdout with --message-format json. It writes the rendered compiler
+// diagnostics to out, also keeping the errors, and records the path of the
+// Wasm binary built for the bin target and of every test binary. Lines which
+// aren't build messages are written to out unchanged.
+type cargoMessages struct {
+	out      io.Writer
+	bin      string
+	buf      bytes.Buffer
+	errors   bytes.Buffer
+	artifact string
+	tests    []TestBinary
+}
+
+// Write implements the io.Writer interface.
+func (m *cargoMessages) Write(p []byte) (int, error) {
+	m.buf.Write(p)
+	for {
+		line, err := m.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line until it's completed.
+			m.buf.Reset()
+			m.buf.Write(line)
+			return len(p), nil
+		}
+
+		var msg cargoMessage
+		if err := json.Unmarshal(line, &msg); err != nil || msg.Reason == "" {
+			m.out.Write(line) // #nosec G104
+			continue
+		}
+
+		switch msg.Reason {
+		case "compiler-message":
+			fmt.Fprint(m.out, msg.Message.Rendered)
+			if msg.Message.Level == "error" {
+				m.errors.WriteString(msg.Message.Rendered)
+			}
+		case "compiler-artifact":
+			if msg.Profile.Test && msg.Executable != "" {
+				m.tests = append(m.tests, TestBinary{
+					Name:    fmt.Sprintf("%s (%s)", msg.Target.Name, strings.Join(msg.Target.Kind, ", ")),
+					Path:    msg.Executable,
+					Harness: libtestHarness{},
+				})
+				continue
+			}
+			if msg.Target.Name != m.bin || !msg.Target.isBin() {
+				continue
+			}
+			m.artifact = msg.Executable
+			for _, f := range msg.Filenames {
+				if m.artifact == "" && filepath.Ext(f) == ".wasm" {
+					m.artifact = f
+				}
+			}
+		}
+	}
+}
+
+// Version implements the Toolchain interface and returns the version of the
+// Rust compiler of the toolchain used to build the package.
+func (r Rust) Version() (string, error) {
+	toolchain, _, err := r.resolveToolchain()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := exec.Command("rustc", "+"+toolchain, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("error executing rustc: %w", err)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// buildInputs returns the paths, relative to the package root, of the files
+// read by a cargo build of the package, as found from its metadata: the files
+// of every workspace member and path dependency, including build scripts and
+// other targets outside of their directories, the workspace manifest and lock
+// file, and the cargo configuration files of the package directory, its
+// parents and the cargo home directory. Hidden files and the target directory
+// are skipped, as are the build output and the files ignored by the matcher
+// within the package.
+func (r Rust) buildInputs(matcher *ignore.Matcher) ([]string, error) {
+	var metadata CargoMetadata
+	if err := metadata.Read(true); err != nil {
+		return nil, fmt.Errorf("error reading cargo metadata: %w", err)
+	}
+
+	// Cargo reports paths with symlinks resolved, so the package root is
+	// too, to find which of them are within it.
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error getting current working directory: %w", err)
+	}
+	root, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	inputs := make(map[string]bool)
+	add := func(path string) error {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
 			return err
 		}
-	case err != nil:
-		return err
+		inputs[rel] = true
+		return nil
 	}
 
-	err = common.CopyFile(src, dst)
+	for _, p := range metadata.Package {
+		// Workspace members and path dependencies are the packages without
+		// a source, unlike those from a registry or git repository.
+		if p.Source != "" {
+			continue
+		}
+
+		dir := filepath.Dir(p.ManifestPath)
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == dir {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			skip := strings.HasPrefix(info.Name(), ".") || filepath.Clean(path) == filepath.Clean(metadata.TargetDirectory)
+			if withinDirectory(".", rel) && (isBuildOutput(rel) || matcher.Match(rel, info.IsDir())) {
+				skip = true
+			}
+			if skip {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+			return add(path)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading files of cargo package %s: %w", p.Name, err)
+		}
+
+		for _, t := range p.Targets {
+			if err := add(t.SrcPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	files := []string{
+		filepath.Join(metadata.WorkspaceRoot, "Cargo.toml"),
+		filepath.Join(metadata.WorkspaceRoot, "Cargo.lock"),
+	}
+	var configDirs []string
+	for dir := root; ; dir = filepath.Dir(dir) {
+		configDirs = append(configDirs, filepath.Join(dir, ".cargo"))
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if home := os.Getenv("CARGO_HOME"); home != "" {
+		configDirs = append(configDirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		configDirs = append(configDirs, filepath.Join(home, ".cargo"))
+	}
+	for _, dir := range configDirs {
+		files = append(files, filepath.Join(dir, "config"), filepath.Join(dir, "config.toml"))
+	}
+	for _, f := range files {
+		if info, err := os.Stat(f); err != nil || info.IsDir() {
+			continue
+		}
+		if err := add(f); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(inputs))
+	for p := range inputs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Files which set the rustup toolchain of a package.
+const (
+	rustToolchainFile     = "rust-toolchain"
+	rustToolchainTOMLFile = "rust-toolchain.toml"
+)
+
+// resolveToolchain returns the rustup toolchain used to build the package and
+// where it was set. The toolchain is set by the [language.rust] section of the
+// package manifest, otherwise by a rust-toolchain or rust-toolchain.toml file
+// in the package directory, with the same precedence as rustup, and otherwise
+// is RustToolchainVersion.
+func (r Rust) resolveToolchain() (toolchain, source string, err error) {
+	if r.toolchain != "" {
+		return r.toolchain, ManifestFilename, nil
+	}
+
+	for _, filename := range []string{rustToolchainFile, rustToolchainTOMLFile} {
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("error reading %s: %w", filename, err)
+		}
+		toolchain, err := parseRustToolchainFile(filename, data)
+		if err != nil {
+			return "", "", err
+		}
+		return toolchain, filename, nil
+	}
+
+	return RustToolchainVersion, "", nil
+}
+
+// parseRustToolchainFile returns the toolchain channel set by a rust-toolchain
+// or rust-toolchain.toml file. The legacy rust-toolchain file may instead
+// contain only the channel name.
+func parseRustToolchainFile(filename string, data []byte) (string, error) {
+	if filename == rustToolchainFile && !strings.Contains(string(data), "[toolchain]") {
+		if channel := strings.TrimSpace(string(data)); channel != "" && !strings.ContainsAny(channel, " \t\r\n") {
+			return channel, nil
+		}
+		return "", fmt.Errorf("error parsing %s: expected a single toolchain name", filename)
+	}
+
+	var f struct {
+		Toolchain struct {
+			Channel string `toml:"channel"`
+		} `toml:"toolchain"`
+	}
+	if _, err := toml.Decode(string(data), &f); err != nil {
+		return "", fmt.Errorf("error parsing %s: %w", filename, err)
+	}
+	if f.Toolchain.Channel == "" {
+		return "", fmt.Errorf("error parsing %s: no toolchain channel set", filename)
+	}
+	return f.Toolchain.Channel, nil
+}
+
+// parseRustcVersion parses the output of `rustc --version`, such as
+// `rustc 1.43.0 (4fb7144ed 2020-04-20)`, and returns the compiler version.
+func parseRustcVersion(output string) (*semver.Version, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 2 || fields[0] != "rustc" {
+		return nil, fmt.Errorf("error parsing rustc version: unexpected output %q", strings.TrimSpace(output))
+	}
+	version, err := semver.NewVersion(fields[1])
 	if err != nil {
-		return fmt.Errorf("error copying wasm binary: %w", err)
+		return nil, fmt.Errorf("error parsing rustc version: %w", err)
 	}
+	return version, nil
+}
 
-	return nil
+// checkRustToolchainVersion checks the compiler version of a toolchain set by
+// source satisfies RustToolchainConstraint. The pre-release of nightly and
+// beta versions is ignored, so that they satisfy the constraint as their
+// corresponding stable version would.
+func checkRustToolchainVersion(toolchain, source string, version *semver.Version) error {
+	constraint, err := semver.NewConstraint(RustToolchainConstraint)
+	if err != nil {
+		return fmt.Errorf("error parsing rust toolchain constraint: %w", err)
+	}
+
+	release, err := version.SetPrerelease("")
+	if err != nil {
+		return fmt.Errorf("error parsing rustc version: %w", err)
+	}
+
+	if constraint.Check(&release) {
+		return nil
+	}
+
+	remediation := fmt.Sprintf("To fix this error, set a supported toolchain in the [language.rust] section of %s:\n\n\t%s\n", text.Bold(ManifestFilename), text.Bold(fmt.Sprintf(`toolchain = "%s"`, RustToolchainVersion)))
+	if source != ManifestFilename && source != "" {
+		remediation = fmt.Sprintf("To fix this error, set a supported toolchain in %s, such as:\n\n\t%s\n", text.Bold(source), text.Bold(RustToolchainVersion))
+	}
+
+	return errors.RemediationError{
+		Inner:       fmt.Errorf("rust toolchain %s (%s) doesn't meet the supported version constraint %s", toolchain, version, RustToolchainConstraint),
+		Remediation: remediation,
+	}
+}
+
+// latestCrateVersion returns the latest version of a crate from crates.io.
+// Offline, or if crates.io can't be reached, the cached latest version is
+// used instead, falling back to the version locked by the package, and a
+// warning is written to out.
+func (r Rust) latestCrateVersion(out io.Writer, metadata CargoMetadata, name string) (*semver.Version, error) {
+	if !r.offline {
+		version, stale, err := r.crates.latestVersion(name)
+		if err != nil {
+			return nil, err
+		}
+		if stale {
+			text.Warning(out, "unable to fetch the latest version of the %s crate, using the cached version %s", name, version)
+		}
+		return version, nil
+	}
+
+	if version, err := r.crates.cachedVersion(name); err == nil {
+		text.Warning(out, "offline, using the cached latest version %s of the %s crate", version, name)
+		return version, nil
+	}
+
+	version, err := getCrateVersionFromMetadata(metadata, name)
+	if err != nil {
+		return nil, fmt.Errorf("offline and no cached or locked version of the %s crate is available: %w", name, err)
+	}
+	text.Warning(out, "offline, unable to check the latest version of the %s crate, using the locked version %s", name, version)
+	return version, nil
 }
 
 // CargoCrateVersion models a Cargo crate version returned by the crates.io API.
@@ -372,9 +942,9 @@ type CargoCrateVersions struct {
 }
 
 // getLatestCrateVersion fetches all versions of a given Rust crate from the
-// crates.io HTTP API and returns the latest valid semver version.
-func getLatestCrateVersion(client api.HTTPClient, name string) (*semver.Version, error) {
-	url := fmt.Sprintf("https://crates.io/api/v1/crates/%s/versions", name)
+// crates.io HTTP API at endpoint and returns the latest valid semver version.
+func getLatestCrateVersion(client api.HTTPClient, endpoint, name string) (*semver.Version, error) {
+	url := fmt.Sprintf("%s/api/v1/crates/%s/versions", strings.TrimSuffix(endpoint, "/"), name)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
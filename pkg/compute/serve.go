@@ -0,0 +1,161 @@
+package compute
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/host"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+// ServeCommand builds a package and runs it on a local HTTP server.
+type ServeCommand struct {
+	common.Base
+	build     *BuildCommand
+	addr      string
+	file      string
+	skipBuild bool
+	force     bool
+}
+
+// NewServeCommand returns a usable command registered under the parent.
+func NewServeCommand(parent common.Registerer, globals *config.Data, build *BuildCommand) *ServeCommand {
+	var c ServeCommand
+	c.Globals = globals
+	c.build = build
+	c.CmdClause = parent.Command("serve", "Build and run a Compute@Edge package locally")
+	c.CmdClause.Flag("addr", "The address to serve the package on").Default("127.0.0.1:7676").StringVar(&c.addr)
+	c.CmdClause.Flag("file", "The Wasm file to run").Default(filepath.Join("bin", "main.wasm")).StringVar(&c.file)
+	c.CmdClause.Flag("skip-build", "Skip building the package before serving it").BoolVar(&c.skipBuild)
+	c.CmdClause.Flag("force", "Skip verification steps and force build").BoolVar(&c.force)
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *ServeCommand) Exec(in io.Reader, out io.Writer) error {
+	if !c.skipBuild {
+		// The build command is copied so that the serve flags don't change
+		// how compute build itself runs.
+		b := *c.build
+		b.force = c.force
+		if err := b.Exec(in, out); err != nil {
+			return err
+		}
+		text.Break(out)
+	}
+
+	var m manifest.File
+	if err := m.Read(ManifestFilename); err != nil {
+		return fmt.Errorf("error reading package manifest: %w", err)
+	}
+
+	backends, err := localBackends(m)
+	if err != nil {
+		return err
+	}
+
+	wasm, err := ioutil.ReadFile(filepath.Clean(c.file))
+	if err != nil {
+		return fmt.Errorf("error reading Wasm binary: %w", err)
+	}
+
+	ctx := context.Background()
+	server, err := host.New(ctx, wasm, host.Config{
+		Backends: backends,
+		Stdout:   out,
+		Stderr:   out,
+	})
+	if err != nil {
+		return err
+	}
+	defer server.Close(ctx)
+
+	ln, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("error listening on %s: %w", c.addr, err),
+			Remediation: "Provide a different address with the --addr flag.",
+		}
+	}
+
+	if len(backends) == 0 {
+		text.Info(out, "No backends are defined in the [local_server] section of the package manifest, requests sent to backends will fail.")
+		text.Break(out)
+	} else {
+		names := make([]string, 0, len(backends))
+		for name := range backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			text.Description(out, fmt.Sprintf("Backend %s is served by", text.Bold(name)), backends[name])
+		}
+	}
+
+	text.Description(out, "Listening on", fmt.Sprintf("http://%s", ln.Addr()))
+	text.Output(out, "Press ^C to stop the server.")
+	text.Break(out)
+
+	srv := &http.Server{Handler: server}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("error serving package: %w", err)
+	case <-sig:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error stopping server: %w", err)
+	}
+
+	text.Break(out)
+	text.Success(out, "Stopped serving package")
+	return nil
+}
+
+// localBackends validates the backends declared in the [local_server] section
+// of the package manifest and returns a map of backend name to URL.
+func localBackends(m manifest.File) (map[string]string, error) {
+	backends := make(map[string]string)
+	if m.LocalServer == nil {
+		return backends, nil
+	}
+
+	for name, b := range m.LocalServer.Backends {
+		u, err := url.Parse(b.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.RemediationError{
+				Inner:       fmt.Errorf("invalid URL %q for local backend %s", b.URL, name),
+				Remediation: fmt.Sprintf("Edit the [local_server.backends.%s] section of %s with a URL such as http://127.0.0.1:8080.", name, text.Bold(ManifestFilename)),
+			}
+		}
+		backends[name] = b.URL
+	}
+
+	return backends, nil
+}
@@ -0,0 +1,284 @@
+package compute
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/wasm"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+// frameOffsetRegexps match the frames of a Wasm backtrace, capturing the
+// offset of the frame's instruction within the Wasm binary. They match the
+// frames printed by Wasmtime, such as
+//
+//	0:   0x65 - <unknown>!<wasm function 1>
+//
+// and by V8, such as
+//
+//	at wasm://wasm/1e2b3c4d:wasm-function[1]:0x65
+var frameOffsetRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*\d+:\s+0x([0-9a-fA-F]+) - `),
+	regexp.MustCompile(`wasm-function\[\d+\]:0x([0-9a-fA-F]+)`),
+}
+
+// frameOffset returns the offset within the Wasm binary of the backtrace
+// frame on the line, if it is one.
+func frameOffset(line string) (int, bool) {
+	for _, re := range frameOffsetRegexps {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		offset, err := strconv.ParseUint(m[1], 16, 32)
+		if err != nil {
+			return 0, false
+		}
+		return int(offset), true
+	}
+	return 0, false
+}
+
+// frameLocation is the source location of a backtrace frame. File and Line
+// are empty if the DWARF line table doesn't cover the frame, and Line is zero
+// for code the compiler generated rather than taken from a source line.
+type frameLocation struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+}
+
+// String formats the location as "function (file:line:column)".
+func (l frameLocation) String() string {
+	if l.File == "" {
+		return l.Function
+	}
+	if l.Line == 0 {
+		return fmt.Sprintf("%s (%s)", l.Function, l.File)
+	}
+	return fmt.Sprintf("%s (%s:%d:%d)", l.Function, l.File, l.Line, l.Column)
+}
+
+// symbolizer maps offsets within a Wasm binary to source locations, using
+// its DWARF debug information.
+type symbolizer struct {
+	module *wasm.Module
+	dwarf  *dwarf.Data
+	code   int
+}
+
+// newSymbolizer decodes the Wasm binary and its DWARF debug information.
+func newSymbolizer(b []byte) (*symbolizer, error) {
+	m, err := wasm.Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("malformed Wasm module: %w", err)
+	}
+	code, ok := m.CodeOffset()
+	if !ok {
+		return nil, fmt.Errorf("the Wasm module has no code section")
+	}
+	d, err := m.DWARF()
+	if err == wasm.ErrNoDebugInfo {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed DWARF debug information: %w", err)
+	}
+	return &symbolizer{module: m, dwarf: d, code: code}, nil
+}
+
+// locate returns the source location of the instruction at the offset within
+// the Wasm binary. The function is named from the name section if possible,
+// as it holds the full path of Rust functions, falling back to the DWARF
+// subprogram and then the function index.
+func (s *symbolizer) locate(offset int) (frameLocation, bool) {
+	fn, ok := s.module.FunctionAt(offset)
+	if !ok {
+		return frameLocation{}, false
+	}
+	loc := frameLocation{Function: fn.Name}
+	pc := uint64(offset - s.code)
+
+	r := s.dwarf.Reader()
+	cu, err := r.SeekPC(pc)
+	if err == nil {
+		if lr, err := s.dwarf.LineReader(cu); err == nil && lr != nil {
+			var entry dwarf.LineEntry
+			if err := lr.SeekPC(pc, &entry); err == nil && entry.File != nil {
+				loc.File = entry.File.Name
+				loc.Line = entry.Line
+				loc.Column = entry.Column
+			}
+		}
+		if loc.Function == "" {
+			loc.Function = s.subprogramName(r, pc)
+		}
+	}
+
+	if loc.Function == "" {
+		loc.Function = fmt.Sprintf("<wasm function %d>", fn.Index)
+	}
+	return loc, true
+}
+
+// subprogramName returns the name of the subprogram containing pc, reading
+// the children of the compilation unit r is positioned at.
+func (s *symbolizer) subprogramName(r *dwarf.Reader, pc uint64) string {
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == dwarf.TagCompileUnit {
+			return ""
+		}
+		if e.Tag != dwarf.TagSubprogram {
+			continue
+		}
+		ranges, err := s.dwarf.Ranges(e)
+		if err != nil {
+			continue
+		}
+		for _, rng := range ranges {
+			if pc >= rng[0] && pc < rng[1] {
+				return s.entryName(e)
+			}
+		}
+	}
+}
+
+// entryName returns the name of a DWARF entry, following its specification
+// or abstract origin if it isn't named itself.
+func (s *symbolizer) entryName(e *dwarf.Entry) string {
+	for i := 0; e != nil && i < 4; i++ {
+		if name, ok := e.Val(dwarf.AttrName).(string); ok {
+			return name
+		}
+		if name, ok := e.Val(dwarf.AttrLinkageName).(string); ok {
+			return name
+		}
+		ref, ok := e.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		if !ok {
+			if ref, ok = e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset); !ok {
+				return ""
+			}
+		}
+		r := s.dwarf.Reader()
+		r.Seek(ref)
+		if e, _ = r.Next(); e == nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// symbolicate copies the backtrace from in to out, following each frame with
+// its source location. It returns the number of frames it located.
+func (s *symbolizer) symbolicate(in io.Reader, out io.Writer) (int, error) {
+	var located int
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(out, line)
+
+		offset, ok := frameOffset(line)
+		if !ok {
+			continue
+		}
+		loc, ok := s.locate(offset)
+		if !ok {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		fmt.Fprintf(out, "%s    at %s\n", indent, loc)
+		located++
+	}
+	if err := scanner.Err(); err != nil {
+		return located, fmt.Errorf("error reading backtrace: %w", err)
+	}
+	return located, nil
+}
+
+// SymbolicateCommand maps the Wasm offsets in a backtrace to functions,
+// source files and lines.
+type SymbolicateCommand struct {
+	common.Base
+	wasmBinary string
+	pkg        string
+	file       string
+}
+
+// NewSymbolicateCommand returns a usable command registered under the parent.
+func NewSymbolicateCommand(parent common.Registerer, globals *config.Data) *SymbolicateCommand {
+	var c SymbolicateCommand
+	c.Globals = globals
+	c.CmdClause = parent.Command("symbolicate", "Map the frames of a Wasm backtrace to functions, source files and lines")
+	c.CmdClause.Flag("wasm-binary", "Path to the Wasm binary the backtrace is from").Default(filepath.Join("bin", "main.wasm")).StringVar(&c.wasmBinary)
+	c.CmdClause.Flag("package", "Path to the package the backtrace is from, instead of a Wasm binary").StringVar(&c.pkg)
+	c.CmdClause.Flag("file", "Path to the backtrace, which is otherwise read from stdin").StringVar(&c.file)
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *SymbolicateCommand) Exec(in io.Reader, out io.Writer) error {
+	var (
+		data []byte
+		path = c.wasmBinary
+		err  error
+	)
+	if c.pkg != "" {
+		path = c.pkg
+		_, data, err = readPackageWasm(c.pkg)
+	} else {
+		data, err = ioutil.ReadFile(c.wasmBinary)
+		if err != nil {
+			err = fmt.Errorf("error reading Wasm binary: %w", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s, err := newSymbolizer(data)
+	if err == wasm.ErrNoDebugInfo {
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("error reading %s: %w", path, err),
+			Remediation: symbolicateRemediation,
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	if c.file != "" {
+		f, err := os.Open(c.file)
+		if err != nil {
+			return fmt.Errorf("error reading backtrace: %w", err)
+		}
+		defer f.Close() // #nosec G307
+		in = f
+	}
+
+	located, err := s.symbolicate(in, out)
+	if err != nil {
+		return err
+	}
+	if located == 0 {
+		text.Warning(out, "None of the backtrace frames could be located in %s.", path)
+	}
+	return nil
+}
+
+// symbolicateRemediation suggests symbolicating against a Wasm binary which
+// still has its debug information.
+var symbolicateRemediation = fmt.Sprintf("To fix this error, symbolicate against the unstripped Wasm binary, which is kept when stripping with:\n\n\t$ %s", text.Bold("fastly compute build --strip --keep-debug"))
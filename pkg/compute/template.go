@@ -0,0 +1,215 @@
+package compute
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fastly/cli/pkg/api"
+	"github.com/fastly/cli/pkg/common"
+	"github.com/mholt/archiver/v3"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// gitHubShorthandRegEx matches the org/repo[/subdir][@tag] shorthand for a
+// template in a GitHub repository.
+var gitHubShorthandRegEx = regexp.MustCompile(`^([\w.-]+)/([\w.-]+)((?:/[\w.-]+)*)(?:@([\w./-]+))?$`)
+
+// templateSource is a location which a package template is fetched from,
+// which is either a Git repository, a local directory, or a .tar.gz archive
+// on the local machine or at an HTTP(S) URL. Subdir is the directory within
+// the source which contains the template.
+type templateSource struct {
+	Repository string
+	Ref        string
+	Directory  string
+	Archive    string
+	Subdir     string
+}
+
+// parseTemplateSource parses the --from flag, or template prompt, into a
+// template source. A path to an existing local directory or file takes
+// precedence over the org/repo shorthand, which refers to a GitHub repository.
+func parseTemplateSource(from string) (templateSource, error) {
+	if strings.HasSuffix(from, ".tar.gz") || strings.HasSuffix(from, ".tgz") {
+		if strings.HasPrefix(from, "http://") || strings.HasPrefix(from, "https://") || common.FileExists(from) {
+			return templateSource{Archive: from}, nil
+		}
+		return templateSource{}, fmt.Errorf("template archive %s not found", from)
+	}
+
+	// Any other URL is treated as a Git repository.
+	if gitRepositoryRegEx.MatchString(from) || strings.Contains(from, "://") {
+		return templateSource{Repository: from}, nil
+	}
+
+	if fi, err := os.Stat(from); err == nil && fi.IsDir() {
+		abspath, err := filepath.Abs(from)
+		if err != nil {
+			return templateSource{}, err
+		}
+		return templateSource{Directory: abspath}, nil
+	}
+
+	if m := gitHubShorthandRegEx.FindStringSubmatch(from); m != nil {
+		return templateSource{
+			Repository: fmt.Sprintf("https://github.com/%s/%s.git", m[1], strings.TrimSuffix(m[2], ".git")),
+			Ref:        m[4],
+			Subdir:     strings.TrimPrefix(m[3], "/"),
+		}, nil
+	}
+
+	return templateSource{}, fmt.Errorf("must be a Git URL, local directory, .tar.gz archive, or org/repo[/subdir]@tag")
+}
+
+// fetch writes the template into dir, unless it is a local directory, and
+// returns the directory containing the template files.
+func (s templateSource) fetch(client api.HTTPClient, dir string, progress io.Writer) (string, error) {
+	root := dir
+	switch {
+	case s.Directory != "":
+		root = s.Directory
+	case s.Archive != "":
+		if err := fetchTemplateArchive(client, s.Archive, dir); err != nil {
+			return "", err
+		}
+		root = archiveRoot(dir)
+	default:
+		if err := cloneTemplate(s.Repository, s.Ref, dir, progress); err != nil {
+			return "", err
+		}
+	}
+
+	if s.Subdir != "" {
+		root = filepath.Join(root, filepath.FromSlash(s.Subdir))
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			return "", fmt.Errorf("directory %s not found in template", s.Subdir)
+		}
+	}
+
+	return root, nil
+}
+
+// cloneTemplate clones a Git repository into dir. A ref may name either a
+// branch or a tag, and a branch takes precedence.
+func cloneTemplate(url, ref, dir string, progress io.Writer) error {
+	clone := func(name plumbing.ReferenceName) error {
+		_, err := git.PlainClone(dir, false, &git.CloneOptions{
+			URL:           url,
+			ReferenceName: name,
+			Depth:         1,
+			Progress:      progress,
+		})
+		return err
+	}
+
+	if ref == "" {
+		return clone("")
+	}
+
+	err := clone(plumbing.NewBranchReferenceName(ref))
+	if err == nil {
+		return nil
+	}
+
+	// A failed clone leaves a partial repository behind.
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	return clone(plumbing.NewTagReferenceName(ref))
+}
+
+// fetchTemplateArchive extracts a .tar.gz archive, which is downloaded first
+// if it is a URL, into dir.
+func fetchTemplateArchive(client api.HTTPClient, archive, dir string) error {
+	if strings.HasPrefix(archive, "http://") || strings.HasPrefix(archive, "https://") {
+		req, err := http.NewRequest("GET", archive, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("error downloading template archive: %s", resp.Status)
+		}
+
+		f, err := ioutil.TempFile("", "fastly-template-*.tar.gz")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			f.Close()
+			return fmt.Errorf("error downloading template archive: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		archive = f.Name()
+	}
+
+	// The archive is extracted into a directory which doesn't exist yet.
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := archiver.NewTarGz().Unarchive(archive, dir); err != nil {
+		return fmt.Errorf("error extracting template archive: %w", err)
+	}
+	return nil
+}
+
+// archiveRoot returns the directory containing the files of an extracted
+// archive, which is the single top-level directory of the archive if it has
+// one, such as the archives of GitHub repositories do.
+func archiveRoot(dir string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
+
+// copyTemplateFile copies a template file from src to dst, replacing the
+// template variables in text files.
+func copyTemplateFile(src, dst string, variables *strings.Replacer) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	// Files containing a NUL byte are treated as binary, and copied as is.
+	if !bytes.Contains(data, []byte{0}) {
+		data = []byte(variables.Replace(string(data)))
+	}
+
+	return ioutil.WriteFile(dst, data, fi.Mode().Perm())
+}
+
+// templateVariables returns the replacer of the {{name}}, {{service_id}} and
+// {{author}} variables in template files.
+func templateVariables(name, serviceID, author string) *strings.Replacer {
+	return strings.NewReplacer(
+		"{{name}}", name,
+		"{{service_id}}", serviceID,
+		"{{author}}", author,
+	)
+}
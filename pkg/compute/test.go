@@ -0,0 +1,553 @@
+package compute
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/host"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
+	"github.com/fastly/cli/pkg/text"
+)
+
+// TestBinary is a WASI binary built from the tests of a package, whose tests
+// are listed and run by its harness.
+type TestBinary struct {
+	Name    string
+	Path    string
+	Harness TestHarness
+}
+
+// TestCase is a test listed by a test binary. Ignored tests are reported as
+// skipped without being run.
+type TestCase struct {
+	Name    string
+	Ignored bool
+}
+
+// TestHarness abstracts the command line interface of the test harness a test
+// binary is built with. List lists the tests of the binary, using run to run
+// it with the given arguments and return its output. Args returns the
+// arguments which run only the named test, and Skipped reports whether the
+// test skipped itself, given its output.
+type TestHarness interface {
+	List(run func(args ...string) (string, error)) ([]TestCase, error)
+	Args(name string) []string
+	Skipped(name, output string) bool
+}
+
+// libtestHarness is the harness of Rust test binaries.
+type libtestHarness struct{}
+
+// List implements the TestHarness interface.
+func (libtestHarness) List(run func(args ...string) (string, error)) ([]TestCase, error) {
+	all, err := run("--list", "--format", "terse")
+	if err != nil {
+		return nil, err
+	}
+	ignored, err := run("--list", "--ignored", "--format", "terse")
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range parseLibtestList(ignored) {
+		skip[name] = true
+	}
+
+	var cases []TestCase
+	for _, name := range parseLibtestList(all) {
+		cases = append(cases, TestCase{Name: name, Ignored: skip[name]})
+	}
+	return cases, nil
+}
+
+// Args implements the TestHarness interface. Panics abort a Wasm program, so
+// the test is run on the main thread with its output written as it happens.
+func (libtestHarness) Args(name string) []string {
+	return []string{name, "--exact", "--nocapture", "--test-threads", "1"}
+}
+
+// Skipped implements the TestHarness interface. Rust tests can't skip
+// themselves, they can only be ignored.
+func (libtestHarness) Skipped(name, output string) bool {
+	return false
+}
+
+// parseLibtestList returns the names of the tests in the output of a Rust
+// test binary run with --list --format terse, such as
+//
+//	tests::it_works: test
+//	benches::it_is_fast: bench
+func parseLibtestList(output string) []string {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ": test") {
+			names = append(names, strings.TrimSuffix(line, ": test"))
+		}
+	}
+	return names
+}
+
+// goTestHarness is the harness of Go test binaries.
+type goTestHarness struct{}
+
+// List implements the TestHarness interface. Benchmarks and fuzz targets
+// aren't run.
+func (goTestHarness) List(run func(args ...string) (string, error)) ([]TestCase, error) {
+	output, err := run("-test.list", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []TestCase
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Example") {
+			cases = append(cases, TestCase{Name: name})
+		}
+	}
+	return cases, nil
+}
+
+// Args implements the TestHarness interface.
+func (goTestHarness) Args(name string) []string {
+	return []string{"-test.run", fmt.Sprintf("^%s$", regexp.QuoteMeta(name)), "-test.v"}
+}
+
+// Skipped implements the TestHarness interface.
+func (goTestHarness) Skipped(name, output string) bool {
+	return strings.Contains(output, fmt.Sprintf("--- SKIP: %s ", name))
+}
+
+// errTestsUnsupported returns the error for a toolchain which can't build
+// tests.
+func errTestsUnsupported(toolchain string) error {
+	return errors.RemediationError{
+		Inner:       fmt.Errorf("tests can't be built for packages built with %s", toolchain),
+		Remediation: "Tests can be run for packages built with the rust and go toolchains.",
+	}
+}
+
+// testStatus is the outcome of running a test.
+type testStatus string
+
+const (
+	testPassed  testStatus = "PASS"
+	testFailed  testStatus = "FAIL"
+	testSkipped testStatus = "SKIP"
+)
+
+// testResult is the outcome of a test of the named test binary, and its
+// output. Message describes why a failed test failed.
+type testResult struct {
+	Binary   string
+	Name     string
+	Status   testStatus
+	Message  string
+	Duration time.Duration
+	Output   string
+}
+
+// runTestBinary runs each test of the test binary whose name contains filter
+// in a fresh instance of it, writing the name of the test being run to out.
+// A test which runs for longer than the timeout is stopped and fails.
+func runTestBinary(ctx context.Context, bin TestBinary, filter string, timeout time.Duration, out io.Writer) ([]testResult, error) {
+	wasm, err := ioutil.ReadFile(filepath.Clean(bin.Path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading test binary: %w", err)
+	}
+
+	cmd, err := host.NewCommand(ctx, wasm)
+	if err != nil {
+		return nil, fmt.Errorf("error loading test binary %s: %w", bin.Path, err)
+	}
+	defer cmd.Close(ctx)
+
+	program := filepath.Base(bin.Path)
+
+	cases, err := bin.Harness.List(func(args ...string) (string, error) {
+		listCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		code, err := cmd.Run(listCtx, append([]string{program}, args...), &stdout, &stderr)
+		if err == nil && code != 0 {
+			err = fmt.Errorf("exit code %d", code)
+		}
+		if err != nil && stderr.Len() > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tests in %s: %w", bin.Name, err)
+	}
+
+	var results []testResult
+	for _, tc := range cases {
+		if !strings.Contains(tc.Name, filter) {
+			continue
+		}
+
+		result := testResult{Binary: bin.Name, Name: tc.Name, Status: testSkipped}
+		if tc.Ignored {
+			results = append(results, result)
+			continue
+		}
+
+		fmt.Fprintf(out, "Running %s...\n", tc.Name)
+
+		testCtx, cancel := context.WithTimeout(ctx, timeout)
+		var output bytes.Buffer
+		start := time.Now()
+		code, err := cmd.Run(testCtx, append([]string{program}, bin.Harness.Args(tc.Name)...), &output, &output)
+		result.Duration = time.Since(start)
+		result.Output = output.String()
+		cancel()
+
+		switch {
+		case err == context.DeadlineExceeded:
+			result.Status = testFailed
+			result.Message = fmt.Sprintf("timed out after %s", timeout)
+		case err != nil:
+			result.Status = testFailed
+			result.Message = err.Error()
+		case code != 0:
+			result.Status = testFailed
+			result.Message = fmt.Sprintf("exit code %d", code)
+		case bin.Harness.Skipped(tc.Name, result.Output):
+			result.Status = testSkipped
+		default:
+			result.Status = testPassed
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// countTestResults returns the number of tests which passed, failed and were
+// skipped.
+func countTestResults(results []testResult) (passed, failed, skipped int) {
+	for _, r := range results {
+		switch r.Status {
+		case testPassed:
+			passed++
+		case testFailed:
+			failed++
+		case testSkipped:
+			skipped++
+		}
+	}
+	return passed, failed, skipped
+}
+
+// writeTextReport writes the results grouped by test binary, followed by the
+// output of each failed test.
+func writeTextReport(out io.Writer, results []testResult) {
+	var binary string
+	for _, r := range results {
+		if r.Binary != binary {
+			binary = r.Binary
+			text.Break(out)
+			fmt.Fprintln(out, text.Bold(binary))
+		}
+
+		status := string(r.Status)
+		switch r.Status {
+		case testPassed:
+			status = text.BoldGreen(status)
+		case testFailed:
+			status = text.BoldRed(status)
+		case testSkipped:
+			status = text.BoldYellow(status)
+		}
+		if r.Status == testSkipped && r.Duration == 0 {
+			fmt.Fprintf(out, "    %s  %s\n", status, r.Name)
+		} else {
+			fmt.Fprintf(out, "    %s  %s (%.2fs)\n", status, r.Name, r.Duration.Seconds())
+		}
+
+		if r.Status != testFailed {
+			continue
+		}
+		output := strings.TrimRight(r.Output, "\n")
+		if output != "" {
+			output += "\n"
+		}
+		output += r.Message
+		for _, line := range strings.Split(output, "\n") {
+			fmt.Fprintf(out, "        %s\n", line)
+		}
+	}
+}
+
+// junitTestSuites is the root element of a JUnit XML report, which has a test
+// suite for each test binary.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the results as a JUnit XML report, as read by CI
+// systems.
+func writeJUnitReport(out io.Writer, results []testResult) error {
+	var (
+		report    junitTestSuites
+		durations []time.Duration
+		total     time.Duration
+	)
+	for _, r := range results {
+		if len(report.Suites) == 0 || report.Suites[len(report.Suites)-1].Name != r.Binary {
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.Binary})
+			durations = append(durations, 0)
+		}
+		i := len(report.Suites) - 1
+		suite := &report.Suites[i]
+
+		tc := junitTestCase{
+			Name:      r.Name,
+			Classname: r.Binary,
+			Time:      junitTime(r.Duration),
+			SystemOut: r.Output,
+		}
+		switch r.Status {
+		case testFailed:
+			tc.Failure = &junitFailure{Message: r.Message}
+			suite.Failures++
+		case testSkipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		durations[i] += r.Duration
+		total += r.Duration
+	}
+	for i := range report.Suites {
+		report.Suites[i].Time = junitTime(durations[i])
+	}
+
+	_, report.Failures, report.Skipped = countTestResults(results)
+	report.Tests = len(results)
+	report.Time = junitTime(total)
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+// junitTime formats a duration as the seconds of a JUnit time attribute.
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// TestCommand builds the tests of a package and runs them in the same Wasm
+// runtime as compute serve.
+type TestCommand struct {
+	common.Base
+	build   *BuildCommand
+	format  string
+	output  string
+	filter  string
+	pkg     string
+	force   bool
+	timeout time.Duration
+}
+
+// NewTestCommand returns a usable command registered under the parent.
+func NewTestCommand(parent common.Registerer, globals *config.Data, build *BuildCommand) *TestCommand {
+	var c TestCommand
+	c.Globals = globals
+	c.build = build
+	c.CmdClause = parent.Command("test", "Build the tests of a Compute@Edge package and run them locally")
+	c.CmdClause.Flag("filter", "Only run tests whose name contains the filter").StringVar(&c.filter)
+	c.CmdClause.Flag("format", "The format of the test report").Default("text").EnumVar(&c.format, "text", "junit")
+	c.CmdClause.Flag("output", "Path to write the test report to, instead of stdout").StringVar(&c.output)
+	c.CmdClause.Flag("package", "Name of the package in the cargo workspace to test").StringVar(&c.pkg)
+	c.CmdClause.Flag("force", "Skip verification steps and force build").BoolVar(&c.force)
+	c.CmdClause.Flag("timeout", "Fail each test which runs for longer than the timeout").Default("1m").DurationVar(&c.timeout)
+	return &c
+}
+
+// Exec implements the command interface.
+func (c *TestCommand) Exec(in io.Reader, out io.Writer) error {
+	// A JUnit report written to stdout isn't mixed with the progress of the
+	// build, so that it can be redirected to a file.
+	reportOnly := c.format == "junit" && c.output == ""
+
+	var progress text.Progress
+	switch {
+	case reportOnly:
+		progress = text.NewNullProgress()
+	case c.Globals.Verbose():
+		progress = text.NewVerboseProgress(out)
+	default:
+		progress = text.NewQuietProgress(out)
+	}
+
+	results, err := c.runTests(progress)
+	if err != nil {
+		return err
+	}
+
+	report := out
+	if c.output != "" {
+		f, err := os.Create(c.output)
+		if err != nil {
+			return fmt.Errorf("error creating test report: %w", err)
+		}
+		defer f.Close() // #nosec G307
+		report = f
+	}
+
+	switch c.format {
+	case "junit":
+		if err := writeJUnitReport(report, results); err != nil {
+			return fmt.Errorf("error writing test report: %w", err)
+		}
+	default:
+		writeTextReport(report, results)
+	}
+	if c.output != "" {
+		text.Info(out, "Wrote the test report to %s", c.output)
+	}
+
+	passed, failed, skipped := countTestResults(results)
+	if failed > 0 {
+		var first string
+		for _, r := range results {
+			if r.Status == testFailed {
+				first = r.Name
+				break
+			}
+		}
+		return errors.RemediationError{
+			Inner:       fmt.Errorf("%d of %d tests failed", failed, len(results)),
+			Remediation: fmt.Sprintf("To run only the first failed test, run:\n\n\t$ %s", text.Bold(fmt.Sprintf("fastly compute test --filter %s", first))),
+		}
+	}
+
+	if reportOnly {
+		return nil
+	}
+	if len(results) == 0 {
+		if c.filter != "" {
+			text.Warning(out, "No tests matched the filter %s", c.filter)
+		} else {
+			text.Warning(out, "No tests were found")
+		}
+		return nil
+	}
+	text.Success(out, "%d passed, %d skipped", passed, skipped)
+	return nil
+}
+
+// runTests builds the tests of the package and runs them, writing the steps
+// to progress.
+func (c *TestCommand) runTests(progress text.Progress) (results []testResult, err error) {
+	defer func() {
+		if err != nil {
+			progress.Fail() // progress.Done is handled inline
+		}
+	}()
+
+	progress.Step("Verifying package manifest...")
+
+	var m manifest.File
+	if err := m.Read(ManifestFilename); err != nil {
+		return nil, fmt.Errorf("error reading package manifest: %w", err)
+	}
+
+	language, _, err := c.build.resolveLanguage(m, "", c.pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := language.Toolchain.(*Rust); !ok && c.pkg != "" {
+		return nil, fmt.Errorf("--package can only be used when testing with the Rust toolchain")
+	}
+
+	if !c.force {
+		progress.Step(fmt.Sprintf("Verifying local %s toolchain...", language.Name))
+
+		if err := language.Verify(progress); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "fastly-compute-test")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	progress.Step(fmt.Sprintf("Building tests using %s toolchain...", language.Name))
+
+	bins, err := language.BuildTests(progress, dir, c.Globals.Flag.Verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for _, bin := range bins {
+		progress.Step(fmt.Sprintf("Running tests in %s...", bin.Name))
+
+		r, err := runTestBinary(ctx, bin, c.filter, c.timeout, progress)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
+
+	progress.Done()
+	return results, nil
+}
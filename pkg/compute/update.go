@@ -2,9 +2,11 @@ package compute
 
 import (
 	"io"
+	"strconv"
 
 	"github.com/fastly/cli/pkg/api"
 	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/manifest"
 	"github.com/fastly/cli/pkg/config"
 	"github.com/fastly/cli/pkg/errors"
 	"github.com/fastly/cli/pkg/text"
@@ -13,10 +15,11 @@ import (
 // UpdateCommand calls the Fastly API to update packages.
 type UpdateCommand struct {
 	common.Base
-	client    api.HTTPClient
-	serviceID string
-	version   int
-	path      string
+	client   api.HTTPClient
+	manifest manifest.Data
+	version  int
+	path     string
+	upload   UploadOptions
 }
 
 // NewUpdateCommand returns a usable command registered under the parent.
@@ -24,10 +27,14 @@ func NewUpdateCommand(parent common.Registerer, client api.HTTPClient, globals *
 	var c UpdateCommand
 	c.Globals = globals
 	c.client = client
+	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("update", "Update a package on a Fastly Compute@Edge service version")
-	c.CmdClause.Flag("service-id", "Service ID").Short('s').Required().StringVar(&c.serviceID)
+	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of service version").Required().IntVar(&c.version)
 	c.CmdClause.Flag("path", "Path to package").Required().Short('p').StringVar(&c.path)
+	c.CmdClause.Flag("max-package-size", "Maximum size of the package archive in bytes").Default(strconv.Itoa(DefaultMaxPackageSize)).Int64Var(&c.upload.MaxSize)
+	c.CmdClause.Flag("upload-retries", "Number of times to retry uploading the package after a transient failure").Default("3").IntVar(&c.upload.Retries)
 	return &c
 }
 
@@ -46,13 +53,20 @@ func (c *UpdateCommand) Exec(in io.Reader, out io.Writer) (err error) {
 	}
 	endpoint, _ := c.Globals.Endpoint()
 
+	serviceID, serviceIDSource := c.manifest.ServiceID()
+	if serviceIDSource == manifest.SourceUndefined {
+		return errors.ErrNoServiceID
+	}
+
 	progress.Step("Uploading package...")
 	client := NewClient(c.client, endpoint, token)
-	if err := client.UpdatePackage(c.serviceID, c.version, c.path); err != nil {
+	c.upload.Backoff = uploadRetryBackoff
+	c.upload.Progress = progress
+	if err := client.UpdatePackage(serviceID, c.version, c.path, c.upload); err != nil {
 		return err
 	}
 	progress.Done()
 
-	text.Success(out, "Updated package (service %s, version %v)", c.serviceID, c.version)
+	text.Success(out, "Updated package (service %s, version %v)", serviceID, c.version)
 	return nil
 }
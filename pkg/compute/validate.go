@@ -1,33 +1,134 @@
 package compute
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/fastly/cli/pkg/common"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/compute/wasm"
 	"github.com/fastly/cli/pkg/config"
+	"github.com/fastly/cli/pkg/errors"
 	"github.com/fastly/cli/pkg/text"
 	"github.com/mholt/archiver/v3"
+	"github.com/tetratelabs/wazero"
 )
 
+const (
+	// DefaultMaxPackageSize is the default limit on the size of a compressed
+	// package archive, in bytes.
+	DefaultMaxPackageSize = 50 << 20
+	// DefaultMaxWasmSize is the default limit on the size of the Wasm binary
+	// within a package, in bytes.
+	DefaultMaxWasmSize = 100 << 20
+)
+
+// allowedImportModules are the host modules a package may import functions
+// and other definitions from.
+var allowedImportModules = map[string]bool{
+	"wasi_snapshot_preview1": true,
+	"fastly_abi":             true,
+	"fastly_dictionary":      true,
+	"fastly_geo":             true,
+	"fastly_http_body":       true,
+	"fastly_http_req":        true,
+	"fastly_http_resp":       true,
+	"fastly_log":             true,
+	"fastly_uap":             true,
+}
+
+// Problem is a single problem found when validating a package. File is the
+// path within the package of the file the problem is in, or the name of the
+// package archive for problems with the package as a whole. Warnings don't
+// make the package invalid, such as manifest keys added by a newer CLI.
+type Problem struct {
+	File    string `json:"file"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// splitProblems separates the warnings from the problems which make the
+// package invalid.
+func splitProblems(problems []Problem) (errs, warnings []Problem) {
+	for _, p := range problems {
+		if p.Warning {
+			warnings = append(warnings, p)
+		} else {
+			errs = append(errs, p)
+		}
+	}
+	return errs, warnings
+}
+
+// validateLimits are the size limits a package must stay within.
+type validateLimits struct {
+	maxPackageSize int64
+	maxWasmSize    int64
+}
+
+var defaultValidateLimits = validateLimits{
+	maxPackageSize: DefaultMaxPackageSize,
+	maxWasmSize:    DefaultMaxWasmSize,
+}
+
 // validate is a utility function to determine whether a package is valid.
-// It attemptes to unarchive and read a tar.gz file from a specfic path,
-// if successful, it then iterates through (streams) each file in the archive
-// checking the filename against a list of required files. If one of the files
-// doesn't exist it returns an error.
-func validate(path string) error {
+// It validates the package within the size limits, and returns an error
+// listing every problem found, otherwise the warnings.
+func validate(path string, limits validateLimits) ([]Problem, error) {
+	problems, err := validatePackage(path, limits)
+	if err != nil {
+		return nil, err
+	}
+	errs, warnings := splitProblems(problems)
+	if len(errs) > 0 {
+		return nil, problemsError(errs, validateRemediation)
+	}
+	return warnings, nil
+}
+
+// printWarnings writes a warning for each of the problems.
+func printWarnings(out io.Writer, warnings []Problem) {
+	for _, p := range warnings {
+		text.Warning(out, "%s: %s", p.File, p.Message)
+	}
+}
+
+// validatePackage attemptes to unarchive and read a tar.gz file from a
+// specfic path, if successful, it then iterates through (streams) each file in
+// the archive checking that the required fastly.toml and main.wasm files
+// exist and are valid. If the package contains a metadata file, the hash of
+// every file in the archive is also checked against it.
+//
+// Problems with the package are returned rather than the first being treated
+// as an error, which is reserved for failing to read the package at all.
+func validatePackage(path string, limits validateLimits) ([]Problem, error) {
 	file, err := os.Open(filepath.Clean(path))
 	if err != nil {
-		return fmt.Errorf("error reading package: %w", err)
+		return nil, fmt.Errorf("error reading package: %w", err)
 	}
 	defer file.Close() // #nosec G307
 
+	var problems []Problem
+	archiveName := filepath.Base(path)
+
+	if fi, err := file.Stat(); err == nil && fi.Size() > limits.maxPackageSize {
+		problems = append(problems, Problem{File: archiveName, Message: fmt.Sprintf("package is %d bytes, which exceeds the limit of %d bytes", fi.Size(), limits.maxPackageSize)})
+	}
+
 	tar := archiver.NewTarGz()
 	err = tar.Open(file, 0)
 	if err != nil {
-		return fmt.Errorf("error unarchiving package: %w", err)
+		return nil, fmt.Errorf("error unarchiving package: %w", err)
 	}
 	defer tar.Close()
 
@@ -36,13 +137,21 @@ func validate(path string) error {
 		"main.wasm":   false,
 	}
 
+	var (
+		metadata     *PackageMetadata
+		manifestData []byte
+		wasmPath     string
+		wasmSize     int64
+	)
+	hashes := make(map[string]string)
+
 	for {
 		f, err := tar.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("error reading package: %w", err)
+			return nil, fmt.Errorf("error reading package: %w", err)
 		}
 
 		for k := range files {
@@ -51,25 +160,219 @@ func validate(path string) error {
 			}
 		}
 
+		if name, ok := packageFilePath(f); ok {
+			if name == PackageMetadataFilename {
+				metadata = new(PackageMetadata)
+				if err := json.NewDecoder(f).Decode(metadata); err != nil {
+					problems = append(problems, Problem{File: name, Message: fmt.Sprintf("error reading package metadata: %v", err)})
+					metadata = nil
+				}
+			} else {
+				var r io.Reader = f
+				h := sha256.New()
+				switch {
+				case name == ManifestFilename && manifestData == nil:
+					data, err := ioutil.ReadAll(io.TeeReader(r, h))
+					if err != nil {
+						return nil, fmt.Errorf("error reading package: %w", err)
+					}
+					manifestData = data
+				case f.Name() == packageWasmFilename && wasmPath == "":
+					wasmPath, wasmSize = name, f.Size()
+				}
+				if _, err := io.Copy(h, r); err != nil {
+					return nil, fmt.Errorf("error reading package: %w", err)
+				}
+				hashes[name] = hex.EncodeToString(h.Sum(nil))
+			}
+		}
+
 		err = f.Close()
 		if err != nil {
-			return fmt.Errorf("error closing package: %w", err)
+			return nil, fmt.Errorf("error closing package: %w", err)
 		}
 	}
 
-	for k, found := range files {
-		if !found {
-			return fmt.Errorf("error validating package: package must contain a %s file", k)
+	for _, k := range []string{"fastly.toml", "main.wasm"} {
+		if !files[k] {
+			problems = append(problems, Problem{File: archiveName, Message: fmt.Sprintf("package must contain a %s file", k)})
 		}
 	}
 
-	return nil
+	if manifestData != nil {
+		problems = append(problems, validateManifest(ManifestFilename, manifestData)...)
+	}
+
+	// The Wasm binary is read once the archive has been checked, unless
+	// it's too large to be deployed anyway.
+	if wasmPath != "" {
+		if wasmSize > limits.maxWasmSize {
+			problems = append(problems, Problem{File: wasmPath, Message: fmt.Sprintf("Wasm binary is %d bytes, which exceeds the limit of %d bytes", wasmSize, limits.maxWasmSize)})
+		} else {
+			_, data, err := readPackageWasm(path)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, validateWasm(wasmPath, data)...)
+		}
+	}
+
+	if metadata != nil {
+		problems = append(problems, validateHashes(*metadata, hashes)...)
+	}
+
+	return problems, nil
+}
+
+// validateHashes checks the hashes of the files in a package against those
+// recorded in its metadata.
+func validateHashes(metadata PackageMetadata, hashes map[string]string) []Problem {
+	var problems []Problem
+
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		want, ok := metadata.Files[name]
+		if !ok {
+			problems = append(problems, Problem{File: name, Message: "file is not listed in the package metadata"})
+		} else if want != hashes[name] {
+			problems = append(problems, Problem{File: name, Message: "hash does not match the package metadata"})
+		}
+	}
+
+	names = names[:0]
+	for name := range metadata.Files {
+		if _, ok := hashes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, Problem{File: name, Message: "file is listed in the package metadata but missing from the package"})
+	}
+
+	if metadata.Hash != metadata.packageHash() {
+		problems = append(problems, Problem{File: PackageMetadataFilename, Message: "package hash does not match the package metadata"})
+	}
+
+	return problems
+}
+
+// validateManifest checks the package manifest is valid TOML which matches
+// the manifest schema, and that its values are usable.
+func validateManifest(name string, data []byte) []Problem {
+	var m manifest.File
+	md, err := manifest.Decode(string(data), &m)
+	if err != nil {
+		return []Problem{{File: name, Message: fmt.Sprintf("invalid manifest: %v", err)}}
+	}
+
+	var problems []Problem
+	for _, key := range md.Undecoded() {
+		// The language table is checked by the manifest itself.
+		if key[0] == "language" {
+			continue
+		}
+		problems = append(problems, Problem{File: name, Message: fmt.Sprintf("unknown key %s", strconv.Quote(key.String())), Warning: true})
+	}
+
+	if strings.TrimSpace(m.Name) == "" {
+		problems = append(problems, Problem{File: name, Message: "name is required"})
+	}
+
+	if m.Version < 0 {
+		problems = append(problems, Problem{File: name, Message: "version must not be negative"})
+	}
+
+	customBuild := m.Scripts != nil && m.Scripts.Build != ""
+	if m.Language != "" && !customBuild {
+		if _, ok := getLanguage(newLanguages(toolchainOptions{}), m.Language); !ok {
+			problems = append(problems, Problem{File: name, Message: fmt.Sprintf("unsupported language %s", m.Language)})
+		}
+	}
+
+	if _, err := m.RustSettings(); err != nil {
+		problems = append(problems, Problem{File: name, Message: err.Error()})
+	}
+
+	if _, err := localBackends(m); err != nil {
+		problems = append(problems, Problem{File: name, Message: err.Error()})
+	}
+
+	return problems
 }
 
+// validateWasm checks the Wasm binary is a well-formed module, which exports
+// a _start function and only imports from the allowed host modules.
+func validateWasm(name string, data []byte) []Problem {
+	m, err := wasm.Parse(data)
+	if err != nil {
+		return []Problem{{File: name, Message: fmt.Sprintf("malformed Wasm module: %v", err)}}
+	}
+
+	var problems []Problem
+
+	// Compiling the module validates the function bodies, which the parser
+	// doesn't decode.
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	if compiled, err := r.CompileModule(ctx, data); err != nil {
+		problems = append(problems, Problem{File: name, Message: fmt.Sprintf("invalid Wasm module: %v", err)})
+	} else {
+		compiled.Close(ctx)
+	}
+	r.Close(ctx)
+
+	var start bool
+	for _, exp := range m.Exports {
+		if exp.Name == "_start" && exp.Kind == wasm.KindFunction {
+			start = true
+		}
+	}
+	if !start {
+		problems = append(problems, Problem{File: name, Message: "module does not export a _start function"})
+	}
+
+	seen := make(map[string]bool)
+	for _, imp := range m.Imports {
+		if allowedImportModules[imp.Module] || seen[imp.Module] {
+			continue
+		}
+		seen[imp.Module] = true
+		problems = append(problems, Problem{File: name, Message: fmt.Sprintf("module imports %s from unsupported module %s", imp.Name, strconv.Quote(imp.Module))})
+	}
+
+	return problems
+}
+
+// problemsError returns an error listing the problems found in a package,
+// with a remediation suggesting how the package can be fixed.
+func problemsError(problems []Problem, remediation string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "error validating package:")
+	for _, p := range problems {
+		fmt.Fprintf(&b, "\n\t%s: %s", p.File, p.Message)
+	}
+	return errors.RemediationError{
+		Inner:       fmt.Errorf("%s", b.String()),
+		Remediation: remediation,
+	}
+}
+
+// validateRemediation suggests rebuilding a package which has problems.
+var validateRemediation = fmt.Sprintf("To fix this error, fix the problems found and rebuild the package:\n\n\t$ %s", text.Bold("fastly compute build"))
+
 // ValidateCommand validates a package archive.
 type ValidateCommand struct {
 	common.Base
-	path string
+	path   string
+	format string
+	strict bool
+	limits validateLimits
 }
 
 // NewValidateCommand returns a usable command registered under the parent.
@@ -78,6 +381,10 @@ func NewValidateCommand(parent common.Registerer, globals *config.Data) *Validat
 	c.Globals = globals
 	c.CmdClause = parent.Command("validate", "Validate a Compute@Edge package")
 	c.CmdClause.Flag("path", "Path to package").Required().Short('p').StringVar(&c.path)
+	c.CmdClause.Flag("max-package-size", "Maximum size of the package archive in bytes").Default(strconv.Itoa(DefaultMaxPackageSize)).Int64Var(&c.limits.maxPackageSize)
+	c.CmdClause.Flag("max-wasm-size", "Maximum size of the Wasm binary in bytes").Default(strconv.Itoa(DefaultMaxWasmSize)).Int64Var(&c.limits.maxWasmSize)
+	c.CmdClause.Flag("format", "Output format (json)").EnumVar(&c.format, "json")
+	c.CmdClause.Flag("strict", "Treat warnings, such as unknown manifest keys, as problems which make the package invalid").BoolVar(&c.strict)
 	return &c
 }
 
@@ -88,10 +395,44 @@ func (c *ValidateCommand) Exec(in io.Reader, out io.Writer) error {
 		return fmt.Errorf("error reading file path: %w", err)
 	}
 
-	if err := validate(p); err != nil {
+	problems, err := validatePackage(p, c.limits)
+	if err != nil {
 		return err
 	}
 
-	text.Success(out, "Validated package %s", p)
+	// Strict validation treats the warnings as problems too.
+	errs, warnings := splitProblems(problems)
+	if c.strict {
+		errs, warnings = problems, nil
+	}
+
+	switch c.format {
+	case "json":
+		result := struct {
+			Path     string    `json:"path"`
+			Valid    bool      `json:"valid"`
+			Problems []Problem `json:"problems"`
+		}{p, len(errs) == 0, problems}
+		if result.Problems == nil {
+			result.Problems = []Problem{}
+		}
+		if err := json.NewEncoder(out).Encode(result); err != nil {
+			return fmt.Errorf("error writing validation result: %w", err)
+		}
+		if len(errs) > 0 {
+			return errors.RemediationError{
+				Inner:       fmt.Errorf("error validating package: found %d problems", len(errs)),
+				Remediation: validateRemediation,
+			}
+		}
+
+	default:
+		if len(errs) > 0 {
+			return problemsError(errs, validateRemediation)
+		}
+		printWarnings(out, warnings)
+		text.Success(out, "Validated package %s", p)
+	}
+
 	return nil
 }
@@ -0,0 +1,222 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fastly/cli/pkg/api"
+	"github.com/fastly/cli/pkg/version"
+	"github.com/fastly/go-fastly/fastly"
+)
+
+const (
+	// verifyProbeInterval is how often the verification URL is requested
+	// while verifying a deployment.
+	verifyProbeInterval = 5 * time.Second
+
+	// verifyMinRequests is how many requests the realtime stats must report
+	// before the error rate of a service is judged, so that a single error
+	// while there is little traffic doesn't fail a deployment.
+	verifyMinRequests = 20
+
+	// verifyMaxBodySize is how much of the body of the verification URL is
+	// read when checking its content.
+	verifyMaxBodySize = 1 << 20
+)
+
+// verifyOptions configures the verification of a deployment.
+type verifyOptions struct {
+	url           string
+	status        int
+	body          string
+	duration      time.Duration
+	delay         time.Duration
+	maxErrorRate  float64
+	probeInterval time.Duration
+}
+
+// verifyReport is the outcome of verifying a deployment. Failures is empty if
+// the deployment passed every check.
+type verifyReport struct {
+	probes       int
+	failedProbes int
+	requests     uint64
+	errors       uint64
+	failures     []string
+}
+
+func (r verifyReport) failed() bool {
+	return len(r.failures) > 0
+}
+
+func (r verifyReport) errorRate() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.errors) / float64(r.requests)
+}
+
+// String returns the report as indented lines, listing each failure followed
+// by a summary of the checks made.
+func (r verifyReport) String() string {
+	var b strings.Builder
+	for _, f := range r.failures {
+		fmt.Fprintf(&b, "\t- %s\n", f)
+	}
+	fmt.Fprintf(&b, "\tProbes: %d (%d failed)\n", r.probes, r.failedProbes)
+	fmt.Fprintf(&b, "\tRequests: %d, 5xx responses: %d (%.1f%%)", r.requests, r.errors, r.errorRate()*100)
+	return b.String()
+}
+
+// verifyDeployment waits for the delay, so that the activated version has
+// time to reach the edge, then requests the verification URL every probe
+// interval and watches the error rate of the service in realtime stats until
+// the duration has passed, or until a check fails. The status and body of every response
+// must be as expected, and the ratio of 5xx responses must not exceed the
+// maximum error rate.
+func verifyDeployment(client api.HTTPClient, rts api.RealtimeStatsInterface, serviceID string, opts verifyOptions, progress io.Writer) verifyReport {
+	var report verifyReport
+
+	if opts.delay > 0 {
+		fmt.Fprintf(progress, "Waiting %s before the first probe\n", opts.delay)
+		time.Sleep(opts.delay)
+	}
+
+	watcher := newErrorRateWatcher(rts, serviceID)
+	defer watcher.stop()
+
+	interval := opts.probeInterval
+	if interval == 0 {
+		interval = verifyProbeInterval
+	}
+	deadline := time.Now().Add(opts.duration)
+
+	for {
+		report.probes++
+		if err := probe(client, opts); err != nil {
+			report.failedProbes++
+			report.failures = append(report.failures, err.Error())
+		} else {
+			fmt.Fprintf(progress, "Probe %d of %s passed\n", report.probes, opts.url)
+		}
+
+		report.requests, report.errors = watcher.totals()
+		if report.requests >= verifyMinRequests && report.errorRate() > opts.maxErrorRate {
+			report.failures = append(report.failures, fmt.Sprintf("5xx error rate %.1f%% exceeds the maximum of %.1f%%", report.errorRate()*100, opts.maxErrorRate*100))
+		}
+
+		if report.failed() || !time.Now().Add(interval).Before(deadline) {
+			return report
+		}
+		time.Sleep(interval)
+	}
+}
+
+// probe requests the verification URL, and checks the status and body of the
+// response are as expected.
+func probe(client api.HTTPClient, opts verifyOptions) error {
+	req, err := http.NewRequest("GET", opts.url, nil)
+	if err != nil {
+		return fmt.Errorf("error constructing request to %s: %w", opts.url, err)
+	}
+	req.Header.Set("User-Agent", version.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error requesting %s: %w", opts.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != opts.status {
+		return fmt.Errorf("%s responded with status %d, expected %d", opts.url, resp.StatusCode, opts.status)
+	}
+
+	if opts.body != "" {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, verifyMaxBodySize))
+		if err != nil {
+			return fmt.Errorf("error reading response from %s: %w", opts.url, err)
+		}
+		if !strings.Contains(string(body), opts.body) {
+			return fmt.Errorf("%s responded without %q in the body", opts.url, opts.body)
+		}
+	}
+
+	return nil
+}
+
+// realtimeErrorStats models the parts of a realtime stats response used to
+// watch the error rate of a service.
+type realtimeErrorStats struct {
+	Timestamp uint64 `json:"timestamp"`
+	Data      []struct {
+		Aggregated struct {
+			Requests  uint64 `json:"requests"`
+			Status5xx uint64 `json:"status_5xx"`
+		} `json:"aggregated"`
+	} `json:"data"`
+}
+
+// errorRateWatcher totals the requests and 5xx responses of a service reported
+// by realtime stats, from when it is created until it is stopped.
+type errorRateWatcher struct {
+	mtx      sync.Mutex
+	requests uint64
+	errors   uint64
+	done     chan struct{}
+}
+
+func newErrorRateWatcher(rts api.RealtimeStatsInterface, serviceID string) *errorRateWatcher {
+	w := &errorRateWatcher{done: make(chan struct{})}
+	go w.watch(rts, serviceID)
+	return w
+}
+
+func (w *errorRateWatcher) watch(rts api.RealtimeStatsInterface, serviceID string) {
+	var timestamp uint64
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		var stats realtimeErrorStats
+		err := rts.GetRealtimeStatsJSON(&fastly.GetRealtimeStatsInput{
+			Service:   serviceID,
+			Timestamp: timestamp,
+		}, &stats)
+		if err != nil {
+			// Stats are best effort, as probing the URL still verifies the
+			// deployment, but failed requests are retried less eagerly.
+			time.Sleep(time.Second)
+			continue
+		}
+
+		// The first response is the latest second of stats, which was
+		// recorded before verification started.
+		if timestamp != 0 {
+			w.mtx.Lock()
+			for _, block := range stats.Data {
+				w.requests += block.Aggregated.Requests
+				w.errors += block.Aggregated.Status5xx
+			}
+			w.mtx.Unlock()
+		}
+		timestamp = stats.Timestamp
+	}
+}
+
+func (w *errorRateWatcher) totals() (requests, errors uint64) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.requests, w.errors
+}
+
+func (w *errorRateWatcher) stop() {
+	close(w.done)
+}
@@ -0,0 +1,578 @@
+// Package wasm decodes the structure of WebAssembly binary modules, such as
+// their sections, imports, exports and functions, without executing them.
+package wasm
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoDebugInfo is returned by Module.DWARF if the module has no DWARF debug
+// information, for example because it was stripped.
+var ErrNoDebugInfo = errors.New("no DWARF debug information")
+
+// magic and version make up the preamble of a Wasm binary module.
+var (
+	magic   = []byte{0x00, 0x61, 0x73, 0x6d}
+	version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// SectionID identifies the kind of a section.
+type SectionID byte
+
+// The sections defined by the WebAssembly core specification.
+const (
+	SectionCustom SectionID = iota
+	SectionType
+	SectionImport
+	SectionFunction
+	SectionTable
+	SectionMemory
+	SectionGlobal
+	SectionExport
+	SectionStart
+	SectionElement
+	SectionCode
+	SectionData
+	SectionDataCount
+)
+
+var sectionNames = []string{
+	"custom",
+	"type",
+	"import",
+	"function",
+	"table",
+	"memory",
+	"global",
+	"export",
+	"start",
+	"element",
+	"code",
+	"data",
+	"datacount",
+}
+
+// String returns the name of the section kind.
+func (id SectionID) String() string {
+	if int(id) < len(sectionNames) {
+		return sectionNames[id]
+	}
+	return fmt.Sprintf("unknown(%d)", byte(id))
+}
+
+// sectionOrder is the position each known non-custom section must appear in.
+// The data count section precedes the code section.
+var sectionOrder = map[SectionID]int{
+	SectionType:      1,
+	SectionImport:    2,
+	SectionFunction:  3,
+	SectionTable:     4,
+	SectionMemory:    5,
+	SectionGlobal:    6,
+	SectionExport:    7,
+	SectionStart:     8,
+	SectionElement:   9,
+	SectionDataCount: 10,
+	SectionCode:      11,
+	SectionData:      12,
+}
+
+// ExternalKind is the kind of an imported or exported definition.
+type ExternalKind byte
+
+// The kinds of definition which may be imported or exported.
+const (
+	KindFunction ExternalKind = iota
+	KindTable
+	KindMemory
+	KindGlobal
+)
+
+// String returns the name of the kind.
+func (k ExternalKind) String() string {
+	switch k {
+	case KindFunction:
+		return "func"
+	case KindTable:
+		return "table"
+	case KindMemory:
+		return "memory"
+	case KindGlobal:
+		return "global"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(k))
+}
+
+// Section is a section of a module. Offset and Size cover the whole section,
+// including its id and size header. Data is the section contents, which for
+// custom sections follows the section name.
+type Section struct {
+	ID     SectionID
+	Name   string
+	Offset int
+	Size   int
+	Data   []byte
+}
+
+// Import is a definition imported by a module.
+type Import struct {
+	Module string
+	Name   string
+	Kind   ExternalKind
+}
+
+// Export is a definition exported by a module.
+type Export struct {
+	Name  string
+	Kind  ExternalKind
+	Index uint32
+}
+
+// Function is a function defined, rather than imported, by a module. Index is
+// its position in the function index space, which includes imported
+// functions. Offset and Size cover the function body within the code section,
+// and Name is taken from the name section, if present.
+type Function struct {
+	Index  uint32
+	Name   string
+	Offset int
+	Size   int
+}
+
+// Module is the decoded structure of a Wasm binary module.
+type Module struct {
+	Sections  []Section
+	Imports   []Import
+	Exports   []Export
+	Functions []Function
+}
+
+// ImportedFunctions returns the number of functions imported by the module.
+func (m *Module) ImportedFunctions() int {
+	var n int
+	for _, imp := range m.Imports {
+		if imp.Kind == KindFunction {
+			n++
+		}
+	}
+	return n
+}
+
+// CustomSection returns the first custom section with the given name.
+func (m *Module) CustomSection(name string) (Section, bool) {
+	for _, s := range m.Sections {
+		if s.ID == SectionCustom && s.Name == name {
+			return s, true
+		}
+	}
+	return Section{}, false
+}
+
+// CodeOffset returns the offset within the binary of the contents of the
+// code section, which DWARF addresses are relative to.
+func (m *Module) CodeOffset() (int, bool) {
+	for _, s := range m.Sections {
+		if s.ID == SectionCode {
+			return s.Offset + s.Size - len(s.Data), true
+		}
+	}
+	return 0, false
+}
+
+// FunctionAt returns the function whose body contains the offset within the
+// binary.
+func (m *Module) FunctionAt(offset int) (Function, bool) {
+	for _, fn := range m.Functions {
+		if offset >= fn.Offset && offset < fn.Offset+fn.Size {
+			return fn, true
+		}
+	}
+	return Function{}, false
+}
+
+// DWARF returns the DWARF debug information held in the .debug_* custom
+// sections of the module. Its addresses are offsets within the contents of
+// the code section, as returned by CodeOffset.
+func (m *Module) DWARF() (*dwarf.Data, error) {
+	sections := make(map[string][]byte)
+	for _, s := range m.Sections {
+		if s.ID == SectionCustom && strings.HasPrefix(s.Name, ".debug_") {
+			if _, ok := sections[s.Name]; !ok {
+				sections[s.Name] = s.Data
+			}
+		}
+	}
+	if sections[".debug_info"] == nil {
+		return nil, ErrNoDebugInfo
+	}
+
+	d, err := dwarf.New(
+		sections[".debug_abbrev"],
+		sections[".debug_aranges"],
+		sections[".debug_frame"],
+		sections[".debug_info"],
+		sections[".debug_line"],
+		sections[".debug_pubnames"],
+		sections[".debug_ranges"],
+		sections[".debug_str"],
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// DWARF 5 moves some data into sections which are added separately.
+	for _, name := range []string{".debug_addr", ".debug_line_str", ".debug_str_offsets", ".debug_rnglists"} {
+		if b, ok := sections[name]; ok {
+			if err := d.AddSection(name, b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// Parse decodes a Wasm binary module. It checks the module is well-formed to
+// the extent of its section structure and the sections it decodes, but not
+// that its function bodies are valid.
+func Parse(b []byte) (*Module, error) {
+	if len(b) < 8 || !bytes.Equal(b[:4], magic) {
+		return nil, fmt.Errorf("missing Wasm magic number")
+	}
+	if !bytes.Equal(b[4:8], version) {
+		return nil, fmt.Errorf("unsupported Wasm version %x", b[4:8])
+	}
+
+	var (
+		m         Module
+		last      int
+		funcCount int
+		hasCode   bool
+	)
+
+	r := &reader{b: b, off: 8}
+	for r.len() > 0 {
+		offset := r.off
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, fmt.Errorf("%s section at offset %#x: %w", SectionID(id), offset, err)
+		}
+		data, err := r.bytes(int(size))
+		if err != nil {
+			return nil, fmt.Errorf("%s section at offset %#x: size exceeds module", SectionID(id), offset)
+		}
+
+		s := Section{ID: SectionID(id), Offset: offset, Size: r.off - offset, Data: data}
+		sr := &reader{b: b[:r.off], off: r.off - len(data)}
+
+		if s.ID == SectionCustom {
+			if s.Name, err = sr.name(); err != nil {
+				return nil, fmt.Errorf("custom section at offset %#x: %w", offset, err)
+			}
+			s.Data = b[sr.off:r.off]
+			m.Sections = append(m.Sections, s)
+			continue
+		}
+
+		order, ok := sectionOrder[s.ID]
+		if !ok {
+			return nil, fmt.Errorf("unknown section id %d at offset %#x", id, offset)
+		}
+		if order <= last {
+			return nil, fmt.Errorf("%s section at offset %#x is out of order or duplicated", s.ID, offset)
+		}
+		last = order
+
+		switch s.ID {
+		case SectionImport:
+			err = parseImports(sr, &m)
+		case SectionFunction:
+			var n uint32
+			n, err = sr.u32()
+			for i := uint32(0); err == nil && i < n; i++ {
+				_, err = sr.u32()
+			}
+			funcCount = int(n)
+		case SectionExport:
+			err = parseExports(sr, &m)
+		case SectionCode:
+			err = parseCode(sr, &m, funcCount)
+			hasCode = true
+		default:
+			sr.off = r.off
+		}
+		if err == nil && sr.len() != 0 {
+			err = fmt.Errorf("unexpected %d bytes at end of section", sr.len())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s section at offset %#x: %w", s.ID, offset, err)
+		}
+
+		m.Sections = append(m.Sections, s)
+	}
+
+	if funcCount > 0 && !hasCode {
+		return nil, fmt.Errorf("function section declares %d functions but there is no code section", funcCount)
+	}
+
+	// The name section is informational, so a malformed one is ignored
+	// rather than rejecting the module.
+	if s, ok := m.CustomSection("name"); ok {
+		_ = parseNames(&reader{b: s.Data}, &m)
+	}
+
+	return &m, nil
+}
+
+// Strip returns a copy of a Wasm binary module without its custom sections,
+// such as DWARF debug information and the name section, which aren't needed
+// to run it.
+func Strip(b []byte) ([]byte, error) {
+	m, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	stripped := make([]byte, 0, len(b))
+	stripped = append(stripped, b[:8]...)
+	for _, s := range m.Sections {
+		if s.ID == SectionCustom {
+			continue
+		}
+		stripped = append(stripped, b[s.Offset:s.Offset+s.Size]...)
+	}
+	return stripped, nil
+}
+
+func parseImports(r *reader, m *Module) error {
+	n, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		var imp Import
+		if imp.Module, err = r.name(); err != nil {
+			return err
+		}
+		if imp.Name, err = r.name(); err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		imp.Kind = ExternalKind(kind)
+
+		switch imp.Kind {
+		case KindFunction:
+			_, err = r.u32()
+		case KindTable:
+			if _, err = r.byte(); err == nil {
+				err = r.limits()
+			}
+		case KindMemory:
+			err = r.limits()
+		case KindGlobal:
+			_, err = r.bytes(2)
+		default:
+			err = fmt.Errorf("import %s.%s has unknown kind %d", imp.Module, imp.Name, kind)
+		}
+		if err != nil {
+			return err
+		}
+
+		m.Imports = append(m.Imports, imp)
+	}
+	return nil
+}
+
+func parseExports(r *reader, m *Module) error {
+	n, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		var exp Export
+		if exp.Name, err = r.name(); err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		if kind > byte(KindGlobal) {
+			return fmt.Errorf("export %s has unknown kind %d", exp.Name, kind)
+		}
+		exp.Kind = ExternalKind(kind)
+		if exp.Index, err = r.u32(); err != nil {
+			return err
+		}
+		m.Exports = append(m.Exports, exp)
+	}
+	return nil
+}
+
+func parseCode(r *reader, m *Module, funcCount int) error {
+	n, err := r.u32()
+	if err != nil {
+		return err
+	}
+	if int(n) != funcCount {
+		return fmt.Errorf("%d function bodies for %d declared functions", n, funcCount)
+	}
+
+	imported := uint32(m.ImportedFunctions())
+	for i := uint32(0); i < n; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		offset := r.off
+		if _, err := r.bytes(int(size)); err != nil {
+			return fmt.Errorf("function body %d exceeds section", i)
+		}
+		m.Functions = append(m.Functions, Function{
+			Index:  imported + i,
+			Offset: offset,
+			Size:   int(size),
+		})
+	}
+	return nil
+}
+
+// parseNames reads the function names subsection of the name section.
+func parseNames(r *reader, m *Module) error {
+	for r.len() > 0 {
+		id, err := r.byte()
+		if err != nil {
+			return err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		data, err := r.bytes(int(size))
+		if err != nil {
+			return err
+		}
+		if id != 1 {
+			continue
+		}
+
+		sr := &reader{b: data}
+		n, err := sr.u32()
+		if err != nil {
+			return err
+		}
+		// Each name is at least two bytes, an index and a length, so a
+		// count which can't fit in the subsection is malformed.
+		if int64(n) > int64(sr.len()/2) {
+			return fmt.Errorf("%d function names exceed the name subsection", n)
+		}
+		names := make(map[uint32]string, n)
+		for i := uint32(0); i < n; i++ {
+			idx, err := sr.u32()
+			if err != nil {
+				return err
+			}
+			name, err := sr.name()
+			if err != nil {
+				return err
+			}
+			names[idx] = name
+		}
+		for i := range m.Functions {
+			m.Functions[i].Name = names[m.Functions[i].Index]
+		}
+	}
+	return nil
+}
+
+// reader decodes values from a Wasm binary, tracking the offset within it.
+type reader struct {
+	b   []byte
+	off int
+}
+
+func (r *reader) len() int {
+	return len(r.b) - r.off
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.len() < 1 {
+		return 0, fmt.Errorf("unexpected end of data at offset %#x", r.off)
+	}
+	c := r.b[r.off]
+	r.off++
+	return c, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.len() < n {
+		return nil, fmt.Errorf("unexpected end of data at offset %#x", r.off)
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+// u32 reads an unsigned LEB128 encoded 32-bit integer.
+func (r *reader) u32() (uint32, error) {
+	var v uint32
+	for shift := uint(0); shift < 35; shift += 7 {
+		c, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift == 28 && c > 0x0f {
+			return 0, fmt.Errorf("integer too large at offset %#x", r.off-1)
+		}
+		v |= uint32(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("integer too large at offset %#x", r.off-1)
+}
+
+// name reads a length prefixed UTF-8 string.
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("invalid UTF-8 name at offset %#x", r.off-len(b))
+	}
+	return string(b), nil
+}
+
+// limits reads the limits of a table or memory.
+func (r *reader) limits() error {
+	flags, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if flags > 3 {
+		return fmt.Errorf("invalid limits flags %#x", flags)
+	}
+	if _, err := r.u32(); err != nil {
+		return err
+	}
+	if flags&1 != 0 {
+		_, err = r.u32()
+	}
+	return err
+}
@@ -0,0 +1,240 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/fastly/cli/pkg/testutil"
+)
+
+func TestParse(t *testing.T) {
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+	// A module importing fastly_abi.init, defining two functions and
+	// exporting the second as _start, with a name section naming both.
+	module := cat(
+		preamble,
+		section(1, vec(2, []byte{0x60, 0x01, 0x7f, 0x01, 0x7f}, []byte{0x60, 0x00, 0x00})),
+		section(2, vec(1, cat(name("fastly_abi"), name("init"), []byte{0x00, 0x00}))),
+		section(3, vec(2, []byte{0x01}, []byte{0x01})),
+		section(5, vec(1, []byte{0x00, 0x01})),
+		section(7, vec(2, cat(name("_start"), []byte{0x00, 0x02}), cat(name("memory"), []byte{0x02, 0x00}))),
+		section(10, vec(2, []byte{0x02, 0x00, 0x0b}, []byte{0x04, 0x00, 0x01, 0x01, 0x0b})),
+		section(0, cat(name("name"), []byte{0x01}, name(string(vec(2, cat([]byte{0x01}, name("helper")), cat([]byte{0x02}, name("_start"))))))),
+		section(0, cat(name(".debug_info"), []byte{0xde, 0xad})),
+	)
+
+	for _, testcase := range []struct {
+		name          string
+		wasm          []byte
+		wantError     string
+		wantSections  []string
+		wantImports   []Import
+		wantExports   []Export
+		wantFunctions []Function
+	}{
+		{
+			name:         "module",
+			wasm:         module,
+			wantSections: []string{"type", "import", "function", "memory", "export", "code", "custom:name", "custom:.debug_info"},
+			wantImports:  []Import{{Module: "fastly_abi", Name: "init", Kind: KindFunction}},
+			wantExports: []Export{
+				{Name: "_start", Kind: KindFunction, Index: 2},
+				{Name: "memory", Kind: KindMemory, Index: 0},
+			},
+			wantFunctions: []Function{
+				{Index: 1, Name: "helper", Offset: 75, Size: 2},
+				{Index: 2, Name: "_start", Offset: 78, Size: 4},
+			},
+		},
+		{
+			// The name count is used to size the names, so a count which
+			// the truncated subsection can't hold must be rejected rather
+			// than allocated, and the malformed name section is ignored.
+			name:          "huge name count",
+			wasm:          cat(preamble, section(0, cat(name("name"), []byte{0x01}, name(string(uleb(0xfffffff0)))))),
+			wantSections:  []string{"custom:name"},
+			wantFunctions: nil,
+		},
+		{
+			name:      "empty",
+			wasm:      nil,
+			wantError: "missing Wasm magic number",
+		},
+		{
+			name:      "wrong version",
+			wasm:      []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+			wantError: "unsupported Wasm version",
+		},
+		{
+			name:      "truncated section",
+			wasm:      cat(preamble, []byte{0x01, 0x10, 0x00}),
+			wantError: "type section at offset 0x8: size exceeds module",
+		},
+		{
+			name:      "unknown section",
+			wasm:      cat(preamble, section(13, nil)),
+			wantError: "unknown section id 13",
+		},
+		{
+			name:      "sections out of order",
+			wasm:      cat(preamble, section(7, vec(0)), section(1, vec(0))),
+			wantError: "type section at offset 0xb is out of order or duplicated",
+		},
+		{
+			name:      "missing code section",
+			wasm:      cat(preamble, section(3, vec(1, []byte{0x00}))),
+			wantError: "function section declares 1 functions but there is no code section",
+		},
+		{
+			name:      "function count mismatch",
+			wasm:      cat(preamble, section(3, vec(1, []byte{0x00})), section(10, vec(0))),
+			wantError: "0 function bodies for 1 declared functions",
+		},
+		{
+			name:      "trailing section bytes",
+			wasm:      cat(preamble, section(7, cat(vec(0), []byte{0x00}))),
+			wantError: "unexpected 1 bytes at end of section",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			m, err := Parse(testcase.wasm)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			if err != nil {
+				return
+			}
+
+			var sections []string
+			for _, s := range m.Sections {
+				if s.ID == SectionCustom {
+					sections = append(sections, "custom:"+s.Name)
+				} else {
+					sections = append(sections, s.ID.String())
+				}
+			}
+			testutil.AssertEqual(t, testcase.wantSections, sections)
+			testutil.AssertEqual(t, testcase.wantImports, m.Imports)
+			testutil.AssertEqual(t, testcase.wantExports, m.Exports)
+			testutil.AssertEqual(t, testcase.wantFunctions, m.Functions)
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	types := section(1, vec(1, []byte{0x60, 0x00, 0x00}))
+	functions := section(3, vec(1, []byte{0x00}))
+	code := section(10, vec(1, []byte{0x02, 0x00, 0x0b}))
+
+	for _, testcase := range []struct {
+		name      string
+		wasm      []byte
+		wantError string
+		want      []byte
+	}{
+		{
+			name: "custom sections",
+			wasm: cat(
+				preamble,
+				section(0, cat(name("producers"), []byte{0x00})),
+				types,
+				functions,
+				code,
+				section(0, cat(name("name"), []byte{0x01, 0x00})),
+				section(0, cat(name(".debug_info"), []byte{0xde, 0xad})),
+			),
+			want: cat(preamble, types, functions, code),
+		},
+		{
+			name: "no custom sections",
+			wasm: cat(preamble, types, functions, code),
+			want: cat(preamble, types, functions, code),
+		},
+		{
+			name:      "malformed",
+			wasm:      cat(preamble, []byte{0x01, 0x10, 0x00}),
+			wantError: "size exceeds module",
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			stripped, err := Strip(testcase.wasm)
+			testutil.AssertErrorContains(t, err, testcase.wantError)
+			testutil.AssertEqual(t, testcase.want, stripped)
+		})
+	}
+}
+
+func TestFunctionAt(t *testing.T) {
+	m, err := Parse(cat(
+		[]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00},
+		section(1, vec(1, []byte{0x60, 0x00, 0x00})),
+		section(3, vec(2, []byte{0x00}, []byte{0x00})),
+		section(10, vec(2, []byte{0x02, 0x00, 0x0b}, []byte{0x03, 0x00, 0x01, 0x0b})),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, ok := m.CodeOffset()
+	testutil.AssertBool(t, true, ok)
+	testutil.AssertEqual(t, 21, code)
+
+	for _, testcase := range []struct {
+		offset    int
+		wantIndex uint32
+		wantOK    bool
+	}{
+		{offset: 22, wantOK: false},
+		{offset: 23, wantIndex: 0, wantOK: true},
+		{offset: 24, wantIndex: 0, wantOK: true},
+		{offset: 25, wantOK: false},
+		{offset: 26, wantIndex: 1, wantOK: true},
+		{offset: 28, wantIndex: 1, wantOK: true},
+		{offset: 29, wantOK: false},
+	} {
+		fn, ok := m.FunctionAt(testcase.offset)
+		testutil.AssertBool(t, testcase.wantOK, ok)
+		testutil.AssertEqual(t, testcase.wantIndex, fn.Index)
+	}
+
+	_, err = m.DWARF()
+	testutil.AssertBool(t, true, err == ErrNoDebugInfo)
+}
+
+func uleb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func name(s string) []byte {
+	return cat(uleb(uint32(len(s))), []byte(s))
+}
+
+func vec(n int, items ...[]byte) []byte {
+	return cat(uleb(uint32(n)), cat(items...))
+}
+
+func section(id byte, content []byte) []byte {
+	return cat([]byte{id}, uleb(uint32(len(content))), content)
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestParseNamesHugeCount(t *testing.T) {
+	m := &Module{Functions: []Function{{Index: 0}}}
+	err := parseNames(&reader{b: cat([]byte{0x01}, name(string(uleb(0xfffffff0))))}, m)
+	testutil.AssertErrorContains(t, err, "4294967280 function names exceed the name subsection")
+	testutil.AssertString(t, "", m.Functions[0].Name)
+}
@@ -0,0 +1,209 @@
+package compute
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fastly/cli/pkg/compute/ignore"
+	"github.com/fastly/cli/pkg/compute/manifest"
+	"github.com/fastly/cli/pkg/text"
+)
+
+const (
+	// watchPollInterval is how often watched files are checked for changes.
+	// Polling is used rather than OS specific file watchers so that watching
+	// behaves the same everywhere, including in containers.
+	watchPollInterval = 500 * time.Millisecond
+
+	// watchDebounce is how long watched files must be unchanged after a
+	// change before the package is rebuilt, so that a burst of saves only
+	// causes a single rebuild.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// fileState is the state of a watched file which is compared to detect
+// changes.
+type fileState struct {
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// watchFiles builds the package and then rebuilds it whenever the files it is
+// built from change, until interrupted.
+func (c *BuildCommand) watchFiles(in io.Reader, out io.Writer) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	return c.watchUntil(sig, in, out)
+}
+
+// watchUntil builds the package and then rebuilds it whenever the files it is
+// built from change, until a value is received from stop.
+func (c *BuildCommand) watchUntil(stop <-chan os.Signal, in io.Reader, out io.Writer) error {
+	var m manifest.File
+	if err := m.Read(ManifestFilename); err != nil {
+		return fmt.Errorf("error reading package manifest: %w", err)
+	}
+
+	language, _, err := c.resolveLanguage(m, c.bin, c.pkg)
+	if err != nil {
+		return err
+	}
+
+	paths := watchPaths(language)
+	if _, err := snapshotFiles(paths); err != nil {
+		return err
+	}
+
+	text.Output(out, "Watching %s for changes. Press ^C to stop.", strings.Join(paths, ", "))
+	text.Break(out)
+
+	files := c.rebuild(in, out, paths)
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	var lastChange time.Time
+	for {
+		select {
+		case <-stop:
+			text.Break(out)
+			text.Success(out, "Stopped watching for changes")
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshotFiles(paths)
+		if err != nil {
+			text.Warning(out, "%v", err)
+			continue
+		}
+
+		if !sameFiles(files, current) {
+			files = current
+			lastChange = time.Now()
+			continue
+		}
+
+		if !lastChange.IsZero() && time.Since(lastChange) >= watchDebounce {
+			lastChange = time.Time{}
+			files = c.rebuild(in, out, paths)
+		}
+	}
+}
+
+// rebuild builds the package, writing a single line reporting whether the
+// build passed or failed rather than the progress of each step. In verbose
+// mode the full build output is also written. It returns a snapshot of the
+// watched paths taken once the build is done, so that files written by the
+// build itself, such as those generated by a pre_build script, aren't taken
+// as changes which need another rebuild.
+func (c *BuildCommand) rebuild(in io.Reader, out io.Writer, paths []string) map[string]fileState {
+	b := *c
+	b.watch = false
+
+	var w io.Writer = ioutil.Discard
+	if c.Globals.Verbose() {
+		w = out
+	}
+
+	start := time.Now()
+	err := b.Exec(in, w)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		fmt.Fprintf(out, "%s [%s] Build failed after %s: %v\n", text.BoldRed("✗"), start.Format("15:04:05"), elapsed, err)
+	} else {
+		fmt.Fprintf(out, "%s [%s] Build succeeded in %s\n", text.BoldGreen("✓"), start.Format("15:04:05"), elapsed)
+	}
+
+	files, err := snapshotFiles(paths)
+	if err != nil {
+		text.Warning(out, "%v", err)
+	}
+	return files
+}
+
+// watchPaths returns the files and directories which are watched for changes
+// when building a package of the language. Files within the source directory
+// are watched by walking it, so aren't listed separately, except for the
+// .fastlyignore file which the walk skips as a hidden file.
+func watchPaths(language *Language) []string {
+	src := filepath.Clean(language.SourceDirectory)
+	var paths []string
+	for _, p := range append([]string{ManifestFilename, IgnoreFilePath}, language.IncludeFiles...) {
+		if p == IgnoreFilePath || !withinDirectory(src, p) {
+			paths = append(paths, p)
+		}
+	}
+	return append(paths, src)
+}
+
+// withinDirectory reports whether a path relative to the package root is
+// within the directory, which is also relative to the package root.
+func withinDirectory(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// snapshotFiles returns the state of every file within the given paths which
+// isn't ignored by the .fastlyignore file or part of the build output. The
+// given paths themselves are always included, and are skipped if they don't
+// exist.
+func snapshotFiles(paths []string) (map[string]fileState, error) {
+	matcher, err := ignore.ReadFile(IgnoreFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s file: %w", IgnoreFilePath, err)
+	}
+
+	files := make(map[string]fileState)
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if path == root && os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if path != root && (isBuildOutput(path) || matcher.Match(path, info.IsDir())) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !info.IsDir() {
+				files[path] = fileState{info.Size(), info.Mode(), info.ModTime()}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error watching %s: %w", root, err)
+		}
+	}
+
+	return files, nil
+}
+
+// sameFiles reports whether two snapshots of watched files are the same.
+func sameFiles(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, sa := range a {
+		sb, ok := b[path]
+		if !ok || sa.size != sb.size || sa.mode != sb.mode || !sa.modTime.Equal(sb.modTime) {
+			return false
+		}
+	}
+	return true
+}
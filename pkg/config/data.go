@@ -97,6 +97,20 @@ func (d *Data) Endpoint() (string, Source) {
 	return DefaultEndpoint, SourceDefault // this method should not fail
 }
 
+// CratesEndpoint yields the base URL of the crates.io API used to look up
+// Rust crate versions, which may be an internal mirror.
+func (d *Data) CratesEndpoint() (string, Source) {
+	if d.Env.CratesEndpoint != "" {
+		return d.Env.CratesEndpoint, SourceEnvironment
+	}
+
+	if d.File.CratesEndpoint != DefaultCratesEndpoint && d.File.CratesEndpoint != "" {
+		return d.File.CratesEndpoint, SourceFile
+	}
+
+	return DefaultCratesEndpoint, SourceDefault // this method should not fail
+}
+
 // FilePath is the location of the fastly CLI application config file.
 var FilePath = func() string {
 	if dir, err := os.UserConfigDir(); err == nil {
@@ -111,6 +125,9 @@ var FilePath = func() string {
 // DefaultEndpoint is the default Fastly API endpoint.
 const DefaultEndpoint = "https://api.fastly.com"
 
+// DefaultCratesEndpoint is the default crates.io API endpoint.
+const DefaultCratesEndpoint = "https://crates.io"
+
 // File represents all of the configuration parameters that can end up in the
 // config file. At some point, it may expand to include e.g. user profiles.
 type File struct {
@@ -118,6 +135,8 @@ type File struct {
 	Email            string `toml:"email"`
 	Endpoint         string `toml:"endpoint"`
 	LastVersionCheck string `toml:"last_version_check"`
+	CratesEndpoint   string `toml:"crates_endpoint,omitempty"`
+	Offline          bool   `toml:"offline,omitempty"`
 }
 
 // Read the File and populate its fields from the filename on disk.
@@ -144,8 +163,9 @@ func (f *File) Write(filename string) error {
 // Environment represents all of the configuration parmaeters that can come from
 // environment variables.
 type Environment struct {
-	Token    string
-	Endpoint string
+	Token          string
+	Endpoint       string
+	CratesEndpoint string
 }
 
 const (
@@ -158,12 +178,17 @@ const (
 
 	// EnvVarEndpoint is the env var we look in for the API endpoint.
 	EnvVarEndpoint = "FASTLY_API_ENDPOINT"
+
+	// EnvVarCratesEndpoint is the env var we look in for the crates.io API
+	// endpoint.
+	EnvVarCratesEndpoint = "FASTLY_CRATES_ENDPOINT"
 )
 
 // Read populates the fields from the provided environment.
 func (e *Environment) Read(env map[string]string) {
 	e.Token = env[EnvVarToken]
 	e.Endpoint = env[EnvVarEndpoint]
+	e.CratesEndpoint = env[EnvVarCratesEndpoint]
 }
 
 // Flag represents all of the configuration parameters that can be set with
@@ -27,6 +27,7 @@ func NewCreateCommand(parent common.Registerer, globals *config.Data) *CreateCom
 	c.CmdClause.Flag("name", "Domain name").Short('n').Required().StringVar(&c.Input.Name)
 	c.CmdClause.Flag("comment", "A descriptive note").StringVar(&c.Input.Comment)
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of service version").Required().IntVar(&c.Input.Version)
 	return &c
 }
@@ -26,6 +26,7 @@ func NewDeleteCommand(parent common.Registerer, globals *config.Data) *DeleteCom
 	c.CmdClause = parent.Command("delete", "Delete a domain on a Fastly service version").Alias("remove")
 	c.CmdClause.Flag("name", "Domain name").Short('n').Required().StringVar(&c.Input.Name)
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of service version").Required().IntVar(&c.Input.Version)
 	return &c
 }
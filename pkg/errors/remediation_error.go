@@ -76,7 +76,9 @@ var BugRemediation = strings.Join([]string{
 }, " ")
 
 // ServiceIDRemediation suggests provide a service ID via --service-id flag or
-// package manifest.
+// package manifest, including the environment selected with --env.
 var ServiceIDRemediation = strings.Join([]string{
-	"Please provide one via the --service-id flag or within your package manifest",
+	"Please provide one via the --service-id flag or within your package manifest.",
+	"When an environment is selected with the --env flag, the service ID is read",
+	"from its [env.<name>] section of the manifest.",
 }, " ")
@@ -27,6 +27,7 @@ func NewUpdateCommand(parent common.Registerer, globals *config.Data) *UpdateCom
 	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("update", "Update a Fastly service")
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("name", "Service name").Short('n').StringVar(&c.updateInput.Name)
 	c.CmdClause.Flag("comment", "Human-readable comment").StringVar(&c.updateInput.Comment)
 	return &c
@@ -25,6 +25,7 @@ func NewCloneCommand(parent common.Registerer, globals *config.Data) *CloneComma
 	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("clone", "Clone a Fastly service version")
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of version you wish to clone").Required().IntVar(&c.Input.Version)
 	return &c
 }
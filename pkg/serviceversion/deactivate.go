@@ -25,6 +25,7 @@ func NewDeactivateCommand(parent common.Registerer, globals *config.Data) *Deact
 	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("deactivate", "Deactivate a Fastly service version")
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	c.CmdClause.Flag("version", "Number of version you wish to deactivate").Required().IntVar(&c.Input.Version)
 	return &c
 }
@@ -26,6 +26,7 @@ func NewListCommand(parent common.Registerer, globals *config.Data) *ListCommand
 	c.manifest.File.Read(manifest.Filename)
 	c.CmdClause = parent.Command("list", "List Fastly service versions")
 	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 	return &c
 }
 
@@ -44,9 +45,9 @@ func (c *ListCommand) Exec(in io.Reader, out io.Writer) error {
 
 	if !c.Globals.Verbose() {
 		tw := text.NewTable(out)
-		tw.AddHeader("NUMBER", "ACTIVE", "LAST EDITED (UTC)")
+		tw.AddHeader("NUMBER", "ACTIVE", "LAST EDITED (UTC)", "COMMENT")
 		for _, version := range versions {
-			tw.AddLine(version.Number, version.Active, version.UpdatedAt.UTC().Format(common.TimeFormat))
+			tw.AddLine(version.Number, version.Active, version.UpdatedAt.UTC().Format(common.TimeFormat), version.Comment)
 		}
 		tw.Print()
 		return nil
@@ -330,9 +330,9 @@ func listVersionsError(i *fastly.ListVersionsInput) ([]*fastly.Version, error) {
 }
 
 var listVersionsShortOutput = strings.TrimSpace(`
-NUMBER  ACTIVE  LAST EDITED (UTC)
-1       false   2010-11-15 19:01
-2       true    2015-03-14 12:59
+NUMBER  ACTIVE  LAST EDITED (UTC)  COMMENT
+1       false   2010-11-15 19:01   a
+2       true    2015-03-14 12:59   c
 `) + "\n"
 
 var listVersionsVerboseOutput = strings.TrimSpace(`
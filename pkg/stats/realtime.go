@@ -25,9 +25,11 @@ type RealtimeCommand struct {
 func NewRealtimeCommand(parent common.Registerer, globals *config.Data) *RealtimeCommand {
 	var c RealtimeCommand
 	c.Globals = globals
+	c.manifest.File.Read(manifest.Filename)
 
 	c.CmdClause = parent.Command("realtime", "View realtime stats for a Fastly service")
-	c.CmdClause.Flag("service-id", "Service ID").Short('s').Required().StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("service-id", "Service ID").Short('s').StringVar(&c.manifest.Flag.ServiceID)
+	c.CmdClause.Flag("env", "Package manifest environment to use").StringVar(&c.manifest.Flag.Env)
 
 	c.CmdClause.Flag("format", "Output format (json)").EnumVar(&c.formatFlag, "json")
 
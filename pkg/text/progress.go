@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -264,3 +266,168 @@ func (p *NullProgress) Done() {}
 
 // Fail implements the Progress interface. It's a no-op.
 func (p *NullProgress) Fail() {}
+
+//
+//
+//
+
+// JSONProgress is an implementation of Progress for machines, such as CI
+// systems, rather than people. It writes each step as newline-delimited JSON
+// events to the provided Writer. Output written via Write during a step is
+// captured and reported when the step finishes or fails, and lines written
+// outside of a step, such as the messages a command prints once it's done,
+// are reported as message events.
+type JSONProgress struct {
+	mtx     sync.Mutex
+	encoder *json.Encoder
+
+	start     time.Time              // when the progress began
+	step      string                 // title of current step
+	stepStart time.Time              // when the current step began
+	output    bytes.Buffer           // receives Write calls during a step
+	message   bytes.Buffer           // receives Write calls outside of a step
+	artifacts map[string]interface{} // reported with the final event
+	finished  bool                   // Done or Fail has been called
+}
+
+// JSONEvent is a single event written by JSONProgress. Event is one of
+// step_started, step_finished, step_failed, message, done or failed.
+type JSONEvent struct {
+	Event      string                 `json:"event"`
+	Time       time.Time              `json:"time"`
+	Step       string                 `json:"step,omitempty"`
+	DurationMS *int64                 `json:"duration_ms,omitempty"`
+	Output     string                 `json:"output,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Artifacts  map[string]interface{} `json:"artifacts,omitempty"`
+}
+
+// ansiEscapeRegEx matches the escape sequences used to color text, which are
+// removed from message events.
+var ansiEscapeRegEx = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// NewJSONProgress returns a JSONProgress outputting to the writer.
+func NewJSONProgress(output io.Writer) *JSONProgress {
+	return &JSONProgress{
+		encoder:   json.NewEncoder(output),
+		start:     time.Now(),
+		artifacts: map[string]interface{}{},
+	}
+}
+
+func (p *JSONProgress) emit(e JSONEvent) {
+	e.Time = time.Now().UTC()
+	p.encoder.Encode(e) // #nosec G104
+}
+
+func (p *JSONProgress) endStep(event string) {
+	if p.step == "" {
+		return
+	}
+	d := time.Since(p.stepStart).Milliseconds()
+	p.emit(JSONEvent{
+		Event:      event,
+		Step:       p.step,
+		DurationMS: &d,
+		Output:     p.output.String(),
+	})
+	p.step = ""
+	p.output.Reset()
+}
+
+func (p *JSONProgress) end(event string) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	d := time.Since(p.start).Milliseconds()
+	p.emit(JSONEvent{
+		Event:      event,
+		DurationMS: &d,
+		Artifacts:  p.artifacts,
+	})
+}
+
+// Tick implements the Progress interface. It's a no-op.
+func (p *JSONProgress) Tick(r rune) {}
+
+// Write implements the Progress interface, capturing the output of the
+// current step, or emitting each full line as a message event if there is no
+// current step.
+func (p *JSONProgress) Write(buf []byte) (int, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.step != "" {
+		return p.output.Write(buf)
+	}
+
+	p.message.Write(buf)
+	for {
+		line, err := p.message.ReadString('\n')
+		if err != nil {
+			// Keep the partial line until it's completed.
+			p.message.Reset()
+			p.message.WriteString(line)
+			break
+		}
+		line = strings.TrimSpace(ansiEscapeRegEx.ReplaceAllString(line, ""))
+		if line != "" {
+			p.emit(JSONEvent{Event: "message", Message: line})
+		}
+	}
+	return len(buf), nil
+}
+
+// Step implements the Progress interface.
+func (p *JSONProgress) Step(msg string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.endStep("step_finished")
+
+	p.step = strings.TrimSpace(msg)
+	p.stepStart = time.Now()
+	p.emit(JSONEvent{Event: "step_started", Step: p.step})
+}
+
+// Done implements the Progress interface.
+func (p *JSONProgress) Done() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.endStep("step_finished")
+	p.end("done")
+}
+
+// Fail implements the Progress interface.
+func (p *JSONProgress) Fail() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.endStep("step_failed")
+	p.end("failed")
+}
+
+// Artifact implements the ArtifactReporter interface, recording an artifact
+// to be reported by the final done or failed event.
+func (p *JSONProgress) Artifact(name string, value interface{}) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.artifacts[name] = value
+}
+
+// ArtifactReporter is implemented by Progress types which report the
+// artifacts produced by a command, such as the path of a built package.
+type ArtifactReporter interface {
+	Artifact(name string, value interface{})
+}
+
+// Artifact reports the named artifact to the Progress, if it reports
+// artifacts. Callers should report artifacts before calling Done or Fail.
+func Artifact(p Progress, name string, value interface{}) {
+	if r, ok := p.(ArtifactReporter); ok {
+		r.Artifact(name, value)
+	}
+}
@@ -1,12 +1,15 @@
 package text_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/fastly/cli/pkg/testutil"
 	"github.com/fastly/cli/pkg/text"
 )
 
@@ -23,6 +26,10 @@ func TestProgress(t *testing.T) {
 			name:        "verbose",
 			constructor: func(w io.Writer) text.Progress { return text.NewVerboseProgress(w) },
 		},
+		{
+			name:        "json",
+			constructor: func(w io.Writer) text.Progress { return text.NewJSONProgress(w) },
+		},
 	} {
 		t.Run(testcase.name, func(t *testing.T) {
 			p := testcase.constructor(os.Stdout)
@@ -47,6 +54,73 @@ func TestProgress(t *testing.T) {
 	}
 }
 
+func TestJSONProgress(t *testing.T) {
+	for _, testcase := range []struct {
+		name   string
+		steps  func(p text.Progress)
+		events []text.JSONEvent
+	}{
+		{
+			name: "done",
+			steps: func(p text.Progress) {
+				fmt.Fprintf(p, "Alpha\n")
+				p.Step("Step one...")
+				fmt.Fprintf(p, "Beta\n")
+				fmt.Fprintf(p, "Delta")
+				p.Step("Step two...")
+				text.Artifact(p, "package", "pkg/app.tar.gz")
+				p.Done()
+				text.Success(p, "Built package")
+			},
+			events: []text.JSONEvent{
+				{Event: "message", Message: "Alpha"},
+				{Event: "step_started", Step: "Step one..."},
+				{Event: "step_finished", Step: "Step one...", Output: "Beta\nDelta"},
+				{Event: "step_started", Step: "Step two..."},
+				{Event: "step_finished", Step: "Step two..."},
+				{Event: "done", Artifacts: map[string]interface{}{"package": "pkg/app.tar.gz"}},
+				{Event: "message", Message: "SUCCESS: Built package"},
+			},
+		},
+		{
+			name: "failed",
+			steps: func(p text.Progress) {
+				p.Step("Step one...")
+				fmt.Fprintf(p, "Kappa\n")
+				p.Fail()
+				p.Fail()
+			},
+			events: []text.JSONEvent{
+				{Event: "step_started", Step: "Step one..."},
+				{Event: "step_failed", Step: "Step one...", Output: "Kappa\n"},
+				{Event: "failed"},
+			},
+		},
+	} {
+		t.Run(testcase.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			testcase.steps(text.NewJSONProgress(&buf))
+
+			var events []text.JSONEvent
+			decoder := json.NewDecoder(&buf)
+			for decoder.More() {
+				var e text.JSONEvent
+				if err := decoder.Decode(&e); err != nil {
+					t.Fatal(err)
+				}
+				if e.Time.IsZero() {
+					t.Errorf("%s event has no time", e.Event)
+				}
+				if hasDuration := e.DurationMS != nil; hasDuration != (e.Event != "step_started" && e.Event != "message") {
+					t.Errorf("%s event: want duration %t, have %t", e.Event, !hasDuration, hasDuration)
+				}
+				events = append(events, text.JSONEvent{Event: e.Event, Step: e.Step, Output: e.Output, Message: e.Message, Artifacts: e.Artifacts})
+			}
+			testutil.AssertEqual(t, testcase.events, events)
+		})
+	}
+}
+
 func TestLastFullLine(t *testing.T) {
 	for _, testcase := range []struct {
 		name  string
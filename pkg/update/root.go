@@ -17,14 +17,16 @@ import (
 // It should be installed under the primary root command.
 type RootCommand struct {
 	common.Base
-	versioner Versioner
-	client    api.HTTPClient
+	versioner  Versioner
+	client     api.HTTPClient
+	jsonEvents bool
 }
 
 // NewRootCommand returns a new command registered in the parent.
 func NewRootCommand(parent common.Registerer, v Versioner, client api.HTTPClient) *RootCommand {
 	var c RootCommand
 	c.CmdClause = parent.Command("update", "Update the CLI to the latest version")
+	c.CmdClause.Flag("json-events", "Write progress as newline-delimited JSON events, for CI systems, instead of text").BoolVar(&c.jsonEvents)
 	c.versioner = v
 	c.client = client
 	return &c
@@ -32,10 +34,21 @@ func NewRootCommand(parent common.Registerer, v Versioner, client api.HTTPClient
 
 // Exec implements the command interface.
 func (c *RootCommand) Exec(in io.Reader, out io.Writer) error {
-	progress := text.NewQuietProgress(out)
+	// JSON events replace all of the text output, so the versions and the
+	// messages written once the update is done are written as events too.
+	var progress text.Progress
+	if c.jsonEvents {
+		progress = text.NewJSONProgress(out)
+		out = progress
+	} else {
+		progress = text.NewQuietProgress(out)
+	}
 
 	current, latest, shouldUpdate, err := Check(context.Background(), version.AppVersion, c.versioner)
 	if err != nil {
+		if c.jsonEvents {
+			progress.Fail()
+		}
 		return fmt.Errorf("error checking for latest version: %w", err)
 	}
 
@@ -43,6 +56,10 @@ func (c *RootCommand) Exec(in io.Reader, out io.Writer) error {
 	text.Output(out, "Latest version: %s", latest)
 	if !shouldUpdate {
 		text.Output(out, "No update required.")
+		if c.jsonEvents {
+			text.Artifact(progress, "version", current.String())
+			progress.Done()
+		}
 		return nil
 	}
 
@@ -74,6 +91,8 @@ func (c *RootCommand) Exec(in io.Reader, out io.Writer) error {
 		}
 	}
 
+	text.Artifact(progress, "path", currentPath)
+	text.Artifact(progress, "version", latest.String())
 	progress.Done()
 
 	text.Success(out, "Updated %s to %s.", currentPath, latest)